@@ -0,0 +1,171 @@
+package accounts
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"map-memories-api/blobs"
+	"map-memories-api/database"
+	"map-memories-api/jobs"
+	"map-memories-api/models"
+	"map-memories-api/resumable"
+
+	"gorm.io/gorm"
+)
+
+// DueForPurge returns the users whose deletion grace period has ended
+func DueForPurge(now time.Time) ([]models.User, error) {
+	var users []models.User
+	err := database.DB.Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", now).
+		Order("deletion_scheduled_at ASC").Find(&users).Error
+	return users, err
+}
+
+// PurgeDue purges every account whose deletion grace period has ended and returns how
+// many were purged
+func PurgeDue(now time.Time) (int, error) {
+	users, err := DueForPurge(now)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for i := range users {
+		if err := Purge(&users[i]); err != nil {
+			log.Printf("Failed to purge user %d: %v", users[i].ID, err)
+			continue
+		}
+		purged++
+	}
+
+	return purged, nil
+}
+
+// Purge removes everything a user owns and anonymizes the account row. Memories, their
+// media (including the files on disk), unfinished uploads, likes, sessions, login
+// history and account tokens are deleted;
+// locations the user created stay available to everyone but lose their owner.
+func Purge(user *models.User) error {
+	var memoryIDs []uint
+	if err := database.DB.Unscoped().Model(&models.Memory{}).
+		Where("user_id = ?", user.ID).Pluck("id", &memoryIDs).Error; err != nil {
+		return err
+	}
+
+	var mediaFiles []models.Media
+	if len(memoryIDs) > 0 {
+		if err := database.DB.Select("id", "storage_key", "file_path", "blob_id", "poster_key").
+			Where("memory_id IN ?", memoryIDs).Find(&mediaFiles).Error; err != nil {
+			return err
+		}
+	}
+
+	var uploads []models.UploadSession
+	if err := database.DB.Select("id", "uuid").Where("user_id = ?", user.ID).Find(&uploads).Error; err != nil {
+		return err
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UploadSession{}).Error; err != nil {
+			return err
+		}
+		if len(memoryIDs) > 0 {
+			if err := tx.Where("memory_id IN ?", memoryIDs).Delete(&models.MemoryLike{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("memory_id IN ?", memoryIDs).Delete(&models.Media{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Where("id IN ?", memoryIDs).Delete(&models.Memory{}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.MemoryLike{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserSession{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserIdentity{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.LoginEvent{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.APIToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND status = ?", user.ID, models.SuggestionStatusPending).
+			Delete(&models.LocationEditSuggestion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Location{}).Where("created_by = ?", user.ID).
+			Update("created_by", nil).Error; err != nil {
+			return err
+		}
+
+		// Keep the row so history (audit logs, reviewed suggestions) still resolves,
+		// but strip everything that identifies the person
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"username":              "deleted_" + user.UUID.String(),
+			"email":                 user.UUID.String() + "@deleted.invalid",
+			"password_hash":         "",
+			"full_name":             "",
+			"avatar_url":            "",
+			"bio":                   "",
+			"pending_email":         "",
+			"email_verified_at":     nil,
+			"deletion_scheduled_at": nil,
+			"two_factor_secret":     "",
+			"two_factor_enabled_at": nil,
+			"profile_visibility":    models.ProfileVisibilityPrivate,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(user).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// Files are removed once the database no longer references them
+	for i := range mediaFiles {
+		if err := blobs.ReleaseMedia(context.Background(), &mediaFiles[i]); err != nil {
+			log.Printf("Failed to delete media file %d of user %d: %v", mediaFiles[i].ID, user.ID, err)
+		}
+	}
+
+	for _, upload := range uploads {
+		if err := resumable.Remove(upload.UUID); err != nil {
+			log.Printf("Failed to remove staged upload %s of user %d: %v", upload.UUID, user.ID, err)
+		}
+	}
+
+	log.Printf("Purged user %d (%d memories, %d media files)", user.ID, len(memoryIDs), len(mediaFiles))
+	return nil
+}
+
+// PurgeJob is the job type of the periodic purge of due accounts
+const PurgeJob = "accounts.purge"
+
+// ScheduleJobs registers the purge of due accounts as a job that runs every interval
+func ScheduleJobs(interval time.Duration) {
+	jobs.Register(PurgeJob, func(ctx context.Context, _ json.RawMessage) error {
+		purged, err := PurgeDue(time.Now())
+		if purged > 0 {
+			log.Printf("Purged %d accounts", purged)
+		}
+		return err
+	})
+	jobs.Every(PurgeJob, interval)
+}
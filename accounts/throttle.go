@@ -0,0 +1,113 @@
+package accounts
+
+import (
+	"strings"
+	"time"
+
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxLockoutDoublings bounds the exponent so the lockout cannot overflow before it is capped
+const maxLockoutDoublings = 20
+
+// AccountThrottleKey is the throttle key for failed logins to an email address
+func AccountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// IPThrottleKey is the throttle key for failed logins from a client address
+func IPThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginLockout returns how long logins for the email from the address are still locked
+// out, or zero when they are allowed. An empty email only checks the address.
+func LoginLockout(email, ip string, now time.Time) (time.Duration, error) {
+	keys := []string{IPThrottleKey(ip)}
+	if email != "" {
+		keys = append(keys, AccountThrottleKey(email))
+	}
+
+	var throttles []models.LoginThrottle
+	if err := database.DB.Where("throttle_key IN ? AND locked_until > ?", keys, now).
+		Find(&throttles).Error; err != nil {
+		return 0, err
+	}
+
+	var lockout time.Duration
+	for _, throttle := range throttles {
+		if remaining := throttle.LockedUntil.Sub(now); remaining > lockout {
+			lockout = remaining
+		}
+	}
+	return lockout, nil
+}
+
+// RecordLoginFailure counts a failed login against the email and the address, locking
+// either out once it exceeds its limit. An empty email only counts against the address.
+func RecordLoginFailure(email, ip string, now time.Time) error {
+	cfg := config.AppConfig.LoginThrottle
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := recordFailure(tx, IPThrottleKey(ip), cfg.IPMaxAttempts, now); err != nil {
+			return err
+		}
+		if email == "" {
+			return nil
+		}
+		return recordFailure(tx, AccountThrottleKey(email), cfg.AccountMaxAttempts, now)
+	})
+}
+
+// RecordLoginSuccess clears the failed attempts of the email. The address keeps its
+// count so signing in to one account does not unlock guessing at others.
+func RecordLoginSuccess(email string) error {
+	return database.DB.Where("throttle_key = ?", AccountThrottleKey(email)).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+// recordFailure increments one counter under a row lock
+func recordFailure(tx *gorm.DB, key string, maxAttempts int, now time.Time) error {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.LoginThrottle{ThrottleKey: key, LastFailureAt: now}).Error; err != nil {
+		return err
+	}
+
+	var throttle models.LoginThrottle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("throttle_key = ?", key).First(&throttle).Error; err != nil {
+		return err
+	}
+
+	// Failures older than the window are forgotten once any lockout has run out
+	cfg := config.AppConfig.LoginThrottle
+	locked := throttle.LockedUntil != nil && throttle.LockedUntil.After(now)
+	if !locked && now.Sub(throttle.LastFailureAt) > cfg.FailureWindow {
+		throttle.Failures = 0
+	}
+
+	throttle.Failures++
+	throttle.LastFailureAt = now
+	if maxAttempts > 0 && throttle.Failures >= maxAttempts {
+		lockedUntil := now.Add(lockoutDuration(throttle.Failures-maxAttempts, cfg))
+		throttle.LockedUntil = &lockedUntil
+	}
+
+	return tx.Save(&throttle).Error
+}
+
+// lockoutDuration doubles the base lockout for every failure past the limit
+func lockoutDuration(excess int, cfg config.LoginThrottleConfig) time.Duration {
+	if excess > maxLockoutDoublings {
+		excess = maxLockoutDoublings
+	}
+	lockout := cfg.BaseLockout << uint(excess)
+	if cfg.MaxLockout > 0 && lockout > cfg.MaxLockout {
+		lockout = cfg.MaxLockout
+	}
+	return lockout
+}
@@ -0,0 +1,218 @@
+package blobs
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"map-memories-api/database"
+	"map-memories-api/mediainfo"
+	"map-memories-api/models"
+	"map-memories-api/storage"
+	"map-memories-api/utils"
+
+	"gorm.io/gorm"
+)
+
+// Store takes a reference on the blob holding the content of a validated upload, storing
+// the content first when no blob has it yet. The returned file info points at the blob;
+// existed tells whether the content was stored already. Every Store has to be matched
+// by a Release once the Media row referencing the blob is gone (or was never created).
+func Store(ctx context.Context, upload *utils.PreparedUpload) (info *utils.FileInfo, existed bool, err error) {
+	var blob models.MediaBlob
+	err = database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockChecksum(tx, upload.Checksum); err != nil {
+			return err
+		}
+
+		err := tx.Where("checksum = ?", upload.Checksum).First(&blob).Error
+		if err == nil {
+			existed = true
+			blob.RefCount++
+			// updated_at tells RemoveUnreferenced that a Media row is about to use the blob
+			return tx.Model(&blob).UpdateColumns(map[string]interface{}{
+				"ref_count":  gorm.Expr("ref_count + 1"),
+				"updated_at": time.Now(),
+			}).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// The object is written while the checksum is locked, so a concurrent Release
+		// of the same content cannot delete it before the row exists
+		blob = models.MediaBlob{
+			Checksum:   upload.Checksum,
+			StorageKey: storage.BlobKey(upload.Checksum),
+			Size:       upload.FileSize,
+			MimeType:   upload.MimeType,
+			RefCount:   1,
+		}
+		content, err := upload.Content()
+		if err != nil {
+			return err
+		}
+		if err := storage.Default.Put(ctx, blob.StorageKey, content, blob.Size, blob.MimeType); err != nil {
+			return err
+		}
+		if err := tx.Create(&blob).Error; err != nil {
+			storage.Default.Delete(ctx, blob.StorageKey)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	fileInfo := upload.FileInfo
+	fileInfo.Filename = utils.GenerateUniqueFilename("upload" + mediainfo.Extension(upload.MimeType))
+	fileInfo.StorageKey = blob.StorageKey
+	fileInfo.BlobID = &blob.ID
+	return &fileInfo, existed, nil
+}
+
+// Release drops a reference on a blob and deletes it once no Media row uses it
+func Release(ctx context.Context, blobID uint) error {
+	var blob models.MediaBlob
+	if err := database.DB.WithContext(ctx).Select("id", "checksum").First(&blob, blobID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	removed := false
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockChecksum(tx, blob.Checksum); err != nil {
+			return err
+		}
+		if err := tx.First(&blob, blobID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		if blob.RefCount > 1 {
+			return tx.Model(&blob).UpdateColumn("ref_count", gorm.Expr("ref_count - 1")).Error
+		}
+		removed = true
+		return tx.Delete(&blob).Error
+	})
+	if err != nil || !removed {
+		return err
+	}
+
+	return deleteUnreferenced(ctx, blob)
+}
+
+// ReleaseMedia gives up the stored file of a deleted Media row: its blob reference, or
+// the file itself for uploads that predate deduplication
+func ReleaseMedia(ctx context.Context, media *models.Media) error {
+	if media.BlobID != nil {
+		return Release(ctx, *media.BlobID)
+	}
+	return utils.DeleteMediaFile(ctx, media)
+}
+
+// FixRefCount raises the reference count of a blob that is lower than the number of
+// Media rows using it, so deleting one of them cannot remove content the others still
+// need. Higher counts are left alone: they include references taken by uploads whose
+// Media row does not exist yet and by deletions about to release theirs.
+func FixRefCount(ctx context.Context, blobID uint) error {
+	var blob models.MediaBlob
+	if err := database.DB.WithContext(ctx).Select("id", "checksum").First(&blob, blobID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockChecksum(tx, blob.Checksum); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Media{}).Where("blob_id = ?", blobID).Count(&count).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.MediaBlob{}).Where("id = ? AND ref_count < ?", blobID, count).
+			UpdateColumn("ref_count", count).Error
+	})
+}
+
+// RemoveUnreferenced deletes a blob no Media row uses, along with its stored object,
+// when it was created and last taken before cutoff. Uploads take the reference before
+// creating their Media row, so younger blobs may still get one. It reports whether the
+// blob was removed.
+func RemoveUnreferenced(ctx context.Context, blobID uint, cutoff time.Time) (bool, error) {
+	var blob models.MediaBlob
+	if err := database.DB.WithContext(ctx).First(&blob, blobID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	removed := false
+	err := database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockChecksum(tx, blob.Checksum); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Media{}).Where("blob_id = ?", blobID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		result := tx.Where("id = ? AND updated_at < ?", blobID, cutoff).Delete(&models.MediaBlob{})
+		removed = result.RowsAffected > 0
+		return result.Error
+	})
+	if err != nil || !removed {
+		return false, err
+	}
+
+	return true, deleteUnreferenced(ctx, blob)
+}
+
+// DeleteOrphan deletes the stored object and poster frame of content no blob row
+// records, e.g. when the server stopped between storing an upload and committing its
+// row. Content stored again in the meantime is kept.
+func DeleteOrphan(ctx context.Context, checksum string) error {
+	return deleteUnreferenced(ctx, models.MediaBlob{
+		Checksum:   checksum,
+		StorageKey: storage.BlobKey(checksum),
+	})
+}
+
+// deleteUnreferenced deletes the stored object of a removed blob, unless the same
+// content was stored again in the meantime
+func deleteUnreferenced(ctx context.Context, blob models.MediaBlob) error {
+	return database.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockChecksum(tx, blob.Checksum); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.MediaBlob{}).Where("checksum = ?", blob.Checksum).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		if err := storage.Default.Delete(ctx, storage.PosterKey(blob.Checksum)); err != nil {
+			return err
+		}
+		return storage.Default.Delete(ctx, blob.StorageKey)
+	})
+}
+
+// lockChecksum serializes Store and Release of the same content until tx ends
+func lockChecksum(tx *gorm.DB, checksum string) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "media-blob:"+checksum).Error
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/geocoding"
+	"map-memories-api/models"
+
+	"gorm.io/gorm"
+)
+
+// Backfills City, Country and Address on existing locations using the configured geocoder.
+// With -download it fetches the GeoNames dataset the offline geocoder reads instead.
+func main() {
+	overwrite := flag.Bool("overwrite", false, "Overwrite existing city and address values")
+	dryRun := flag.Bool("dry-run", false, "Report changes without saving them")
+	batchSize := flag.Int("batch-size", 500, "Number of locations processed per batch")
+	download := flag.Bool("download", false, "Download the GeoNames dataset to GEOCODER_DATA_PATH and exit")
+	flag.Parse()
+
+	// Load configuration
+	config.LoadConfig()
+
+	if *download {
+		geocodingConfig := config.AppConfig.Geocoding
+		log.Printf("Downloading %s", geocodingConfig.DataURL)
+		if err := geocoding.Download(context.Background(), geocodingConfig.DataURL, geocodingConfig.DataPath); err != nil {
+			log.Fatalf("Failed to download geocoding dataset: %v", err)
+		}
+		log.Printf("Saved geocoding dataset to %s (GeoNames, CC BY 4.0)", geocodingConfig.DataPath)
+		return
+	}
+
+	// Connect to database
+	database.Connect()
+	defer database.Close()
+
+	// Run database migrations
+	database.AutoMigrate()
+
+	// Initialize reverse geocoder
+	if err := geocoding.Init(); err != nil {
+		log.Fatalf("Failed to initialize geocoder: %v", err)
+	}
+
+	var processed, updated, unresolved int
+	var locations []models.Location
+
+	result := database.DB.Order("id ASC").FindInBatches(&locations, *batchSize, func(tx *gorm.DB, batch int) error {
+		for i := range locations {
+			location := &locations[i]
+			before := *location
+			processed++
+
+			if err := geocoding.FillLocation(location, *overwrite); err != nil {
+				unresolved++
+			}
+
+			if location.City == before.City && location.Country == before.Country && location.Address == before.Address {
+				continue
+			}
+
+			updated++
+			log.Printf("Location %d (%s): city %q -> %q, country %q -> %q",
+				location.ID, location.Name, before.City, location.City, before.Country, location.Country)
+
+			if *dryRun {
+				continue
+			}
+
+			if err := database.DB.Model(location).Updates(map[string]interface{}{
+				"city":    location.City,
+				"country": location.Country,
+				"address": location.Address,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if result.Error != nil {
+		log.Fatalf("Geocoding backfill failed: %v", result.Error)
+	}
+
+	log.Printf("Geocoding backfill completed: %d processed, %d updated, %d unresolved (dry run: %t)",
+		processed, updated, unresolved, *dryRun)
+}
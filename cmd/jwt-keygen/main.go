@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Generates a JWT signing key in the format JWT_KEY_DIR expects. Keys are named by
+// date by default, so a newly generated key becomes the signing key on the next start
+// while older keys keep verifying tokens issued with them.
+func main() {
+	dir := flag.String("dir", "./keys", "Directory to write the key to")
+	alg := flag.String("alg", "ed25519", "Key type: ed25519 (EdDSA) or rsa (RS256)")
+	kid := flag.String("kid", time.Now().UTC().Format("20060102-150405"), "Key ID (file name without .pem)")
+	bits := flag.Int("bits", 3072, "RSA key size")
+	flag.Parse()
+
+	var key crypto.Signer
+	var err error
+	switch *alg {
+	case "ed25519":
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case "rsa":
+		key, err = rsa.GenerateKey(rand.Reader, *bits)
+	default:
+		log.Fatalf("Unsupported key type %q; use ed25519 or rsa", *alg)
+	}
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		log.Fatalf("Failed to encode key: %v", err)
+	}
+
+	if err := os.MkdirAll(*dir, 0700); err != nil {
+		log.Fatalf("Failed to create key directory: %v", err)
+	}
+
+	path := filepath.Join(*dir, *kid+".pem")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		log.Fatalf("Failed to create key file: %v", err)
+	}
+	defer file.Close()
+
+	if err := pem.Encode(file, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		log.Fatalf("Failed to write key: %v", err)
+	}
+
+	log.Printf("Wrote %s key %s to %s", *alg, *kid, path)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/models"
+	"map-memories-api/storage"
+	"map-memories-api/utils"
+)
+
+// Moves media files into the configured storage backend. Records that still point at
+// an absolute file path get a storage key; with -source-dir, files already stored
+// under keys in a local directory (e.g. the old uploads dir) are copied as well, along
+// with the poster frames of videos, which is how a deployment switches from local disk
+// to object storage.
+func main() {
+	dryRun := flag.Bool("dry-run", false, "List the files that would be moved without moving them")
+	deleteSource := flag.Bool("delete-source", false, "Delete each source file after it was copied and verified")
+	sourceDir := flag.String("source-dir", "", "Local storage directory to copy keyed files from")
+	flag.Parse()
+
+	// Load configuration
+	config.LoadConfig()
+
+	// Connect to database
+	database.Connect()
+	defer database.Close()
+
+	// Run database migrations
+	database.AutoMigrate()
+
+	if err := storage.Init(); err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	storageConfig := config.AppConfig.Storage
+	if *sourceDir != "" && storageConfig.Driver == "local" &&
+		filepath.Clean(*sourceDir) == filepath.Clean(storageConfig.LocalPath) {
+		log.Fatalf("-source-dir is the configured storage directory; nothing to copy")
+	}
+
+	var source storage.Storage
+	if *sourceDir != "" {
+		source = storage.NewLocalStorage(*sourceDir)
+	}
+
+	query := database.DB.Model(&models.Media{})
+	if source == nil {
+		query = query.Where("storage_key = '' OR storage_key IS NULL")
+	}
+
+	var mediaList []models.Media
+	if err := query.Order("id ASC").Find(&mediaList).Error; err != nil {
+		log.Fatalf("Failed to load media: %v", err)
+	}
+
+	ctx := context.Background()
+	moved, failed := 0, 0
+	postersCopied, postersFailed := 0, 0
+	// Deduplicated media share a blob and its poster, which are copied only once
+	copied := make(map[string]bool)
+	for i := range mediaList {
+		media := &mediaList[i]
+		key := media.StorageKey
+		if key == "" {
+			key = storage.MediaKey(media.Filename)
+		}
+		// Posters are generated into the storage; only a source directory holds any
+		copyPoster := source != nil && media.PosterKey != "" && !copied[media.PosterKey]
+
+		if *dryRun {
+			log.Printf("Would move media %d to %s", media.ID, key)
+			if copyPoster {
+				log.Printf("Would copy the poster of media %d to %s", media.ID, media.PosterKey)
+				copied[media.PosterKey] = true
+			}
+			continue
+		}
+
+		if copied[key] {
+			moved++
+		} else if err := migrate(ctx, media, key, source, *deleteSource); err != nil {
+			log.Printf("Failed to move media %d: %v", media.ID, err)
+			failed++
+			continue
+		} else {
+			copied[key] = true
+			moved++
+		}
+
+		if copyPoster {
+			if err := migratePoster(ctx, media.PosterKey, source, *deleteSource); err != nil {
+				log.Printf("Failed to copy the poster of media %d: %v", media.ID, err)
+				postersFailed++
+				continue
+			}
+			copied[media.PosterKey] = true
+			postersCopied++
+		}
+	}
+
+	if *dryRun {
+		log.Printf("%d media files would be moved", len(mediaList))
+		return
+	}
+	log.Printf("Moved %d media files, %d failed", moved, failed)
+	if source != nil {
+		log.Printf("Copied %d poster frames, %d failed", postersCopied, postersFailed)
+	}
+}
+
+// migrate copies one media file into the default storage under key, verifies its size
+// and points the record at it
+func migrate(ctx context.Context, media *models.Media, key string, source storage.Storage, deleteSource bool) error {
+	var reader io.ReadCloser
+	var object *storage.Object
+	var err error
+	if media.StorageKey != "" && source != nil {
+		reader, object, err = source.Get(ctx, media.StorageKey)
+	} else {
+		reader, object, err = utils.OpenMediaFile(ctx, media)
+	}
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := put(ctx, key, reader, object.Size, media.MimeType); err != nil {
+		return err
+	}
+
+	if err := database.DB.Model(media).Updates(map[string]interface{}{
+		"storage_key": key,
+		"file_path":   "",
+	}).Error; err != nil {
+		return err
+	}
+
+	if deleteSource {
+		if media.StorageKey != "" && source != nil {
+			err = source.Delete(ctx, media.StorageKey)
+		} else {
+			err = utils.DeleteFile(media.FilePath)
+		}
+		if err != nil {
+			log.Printf("Moved media %d but failed to delete the source file: %v", media.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// migratePoster copies a poster frame from source into the default storage under the
+// same key
+func migratePoster(ctx context.Context, key string, source storage.Storage, deleteSource bool) error {
+	reader, object, err := source.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := put(ctx, key, reader, object.Size, "image/jpeg"); err != nil {
+		return err
+	}
+
+	if deleteSource {
+		if err := source.Delete(ctx, key); err != nil {
+			log.Printf("Copied poster %s but failed to delete the source file: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// put stores the content of reader in the default storage under key and verifies its size
+func put(ctx context.Context, key string, reader io.Reader, size int64, contentType string) error {
+	if err := storage.Default.Put(ctx, key, reader, size, contentType); err != nil {
+		return err
+	}
+
+	stored, err := storage.Default.Stat(ctx, key)
+	if err != nil {
+		return err
+	}
+	if stored.Size != size {
+		return fmt.Errorf("stored %d bytes, expected %d", stored.Size, size)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"map-memories-api/accounts"
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/storage"
+)
+
+// Purges accounts whose deletion grace period has ended.
+func main() {
+	dryRun := flag.Bool("dry-run", false, "List the accounts that would be purged without purging them")
+	flag.Parse()
+
+	// Load configuration
+	config.LoadConfig()
+
+	// Connect to database
+	database.Connect()
+	defer database.Close()
+
+	// Run database migrations
+	database.AutoMigrate()
+
+	// Media files are deleted from the configured storage
+	if err := storage.Init(); err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	now := time.Now()
+
+	if *dryRun {
+		users, err := accounts.DueForPurge(now)
+		if err != nil {
+			log.Fatalf("Failed to find accounts to purge: %v", err)
+		}
+		for _, user := range users {
+			log.Printf("Would purge user %d (%s), scheduled for %s", user.ID, user.Username, user.DeletionScheduledAt.Format(time.RFC3339))
+		}
+		log.Printf("%d accounts due for purge", len(users))
+		return
+	}
+
+	purged, err := accounts.PurgeDue(now)
+	if err != nil {
+		log.Fatalf("Failed to purge accounts: %v", err)
+	}
+	log.Printf("Purged %d accounts", purged)
+}
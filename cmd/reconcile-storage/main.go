@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/reconcile"
+	"map-memories-api/storage"
+)
+
+// Checks the media storage against the database: files no media row references, media
+// rows whose file is gone, media of deleted memories and blob reference counts. Without
+// -purge it only reports what it finds.
+func main() {
+	purge := flag.Bool("purge", false, "Delete what was found instead of only reporting it")
+	force := flag.Bool("force", false, "Purge even when most files look orphaned or missing")
+	gracePeriod := flag.Duration("grace-period", 0, "Leave files and blobs younger than this alone (default RECONCILE_GRACE_PERIOD)")
+	retention := flag.Duration("deleted-memory-retention", 0, "Keep media of memories deleted more recently than this (default RECONCILE_DELETED_MEMORY_RETENTION)")
+	flag.Parse()
+
+	// Load configuration
+	config.LoadConfig()
+
+	// Connect to database
+	database.Connect()
+	defer database.Close()
+
+	// Run database migrations
+	database.AutoMigrate()
+
+	if err := storage.Init(); err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	opts := reconcile.OptionsFrom(config.AppConfig.Reconcile)
+	opts.Purge = *purge
+	opts.Force = *force
+	opts.Logf = log.Printf
+	if *gracePeriod > 0 {
+		opts.GracePeriod = *gracePeriod
+	}
+	if *retention > 0 {
+		opts.DeletedMemoryRetention = *retention
+	}
+
+	report, err := reconcile.Run(context.Background(), opts)
+	log.Println(report)
+	if err != nil {
+		log.Fatalf("Reconciliation stopped: %v", err)
+	}
+	if !*purge {
+		log.Println("Nothing was deleted; run with -purge to clean up")
+	}
+}
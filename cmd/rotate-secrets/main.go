@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/models"
+	"map-memories-api/utils"
+)
+
+// Re-encrypts stored secrets (TOTP secrets) with the current ENCRYPTION_KEY. Run it
+// after rotating the key, while the old one is still listed in ENCRYPTION_PREVIOUS_KEYS;
+// the old key can be removed once nothing needs rotation any more.
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Count the secrets that need rotation without changing them")
+	flag.Parse()
+
+	// Load configuration
+	config.LoadConfig()
+
+	// Connect to database
+	database.Connect()
+	defer database.Close()
+
+	// Run database migrations
+	database.AutoMigrate()
+
+	var users []models.User
+	if err := database.DB.Select("id", "two_factor_secret").
+		Where("two_factor_secret IS NOT NULL AND two_factor_secret <> ''").
+		Find(&users).Error; err != nil {
+		log.Fatalf("Failed to load two-factor secrets: %v", err)
+	}
+
+	rotated, failed := 0, 0
+	for _, user := range users {
+		if !utils.SecretNeedsRotation(user.TwoFactorSecret) {
+			continue
+		}
+		if *dryRun {
+			rotated++
+			continue
+		}
+
+		secret, err := utils.DecryptSecret(user.TwoFactorSecret)
+		if err != nil {
+			log.Printf("Failed to decrypt the two-factor secret of user %d: %v", user.ID, err)
+			failed++
+			continue
+		}
+		encrypted, err := utils.EncryptSecret(secret)
+		if err != nil {
+			log.Fatalf("Failed to encrypt secret: %v", err)
+		}
+
+		// Leave the row alone if the user re-enrolled in the meantime
+		result := database.DB.Model(&models.User{}).
+			Where("id = ? AND two_factor_secret = ?", user.ID, user.TwoFactorSecret).
+			Update("two_factor_secret", encrypted)
+		if result.Error != nil {
+			log.Fatalf("Failed to store the two-factor secret of user %d: %v", user.ID, result.Error)
+		}
+		rotated += int(result.RowsAffected)
+	}
+
+	if *dryRun {
+		log.Printf("%d of %d two-factor secrets need rotation", rotated, len(users))
+		return
+	}
+	log.Printf("Rotated %d two-factor secrets", rotated)
+	if failed > 0 {
+		log.Fatalf("%d secrets could not be decrypted; keep their key in ENCRYPTION_PREVIOUS_KEYS", failed)
+	}
+}
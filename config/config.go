@@ -21,6 +21,9 @@ type Config struct {
 	// JWT
 	JWT JWTConfig
 
+	// Keys for secrets at rest and account tokens
+	Keys KeysConfig
+
 	// Server
 	Server ServerConfig
 
@@ -38,6 +41,39 @@ type Config struct {
 
 	// Pagination
 	Pagination PaginationConfig
+
+	// Geocoding
+	Geocoding GeocodingConfig
+
+	// Mail
+	Mail MailConfig
+
+	// Account tokens (email verification, password reset)
+	Tokens TokenConfig
+
+	// Account lifecycle
+	Account AccountConfig
+
+	// Social login (OpenID Connect)
+	OAuth OAuthConfig
+
+	// Brute-force protection for logins
+	LoginThrottle LoginThrottleConfig
+
+	// Media file storage
+	Storage StorageConfig
+
+	// Per-user storage limits
+	Quota QuotaConfig
+
+	// Video metadata and poster frames
+	Video VideoConfig
+
+	// Background jobs
+	Jobs JobsConfig
+
+	// Storage reconciliation
+	Reconcile ReconcileConfig
 }
 
 type DatabaseConfig struct {
@@ -52,6 +88,23 @@ type DatabaseConfig struct {
 type JWTConfig struct {
 	Secret string
 	Expiry time.Duration
+	// KeyDir holds the PEM keys for RS256/EdDSA signing; tokens are signed with the
+	// shared Secret (HS256) when it is empty
+	KeyDir string
+	// SigningKeyID selects the key (file name without .pem) that signs new tokens;
+	// defaults to the last private key by name
+	SigningKeyID string
+}
+
+// KeysConfig holds the keys that encrypt stored secrets (TOTP secrets) and sign account
+// tokens, kept apart from the JWT secret. Each ring maps a key version to its key: the
+// current version encrypts and signs, older versions are still accepted until their
+// values have been rotated (see cmd/rotate-secrets).
+type KeysConfig struct {
+	EncryptionKeys         map[int]string
+	EncryptionKeyVersion   int
+	TokenSigningKeys       map[int]string
+	TokenSigningKeyVersion int
 }
 
 type ServerConfig struct {
@@ -64,6 +117,21 @@ type UploadConfig struct {
 	MaxFileSize    string
 	AllowedTypes   []string
 	MaxFileSizeInt int64
+	MaxImagePixels int64 // decoded width*height limit, guards against decompression bombs
+
+	// Resumable uploads: chunks are staged on local disk until the upload completes
+	StagingPath      string
+	ChunkSize        int64 // size clients are told to send
+	MaxChunkSize     int64 // largest chunk accepted in one request
+	MaxResumableSize int64
+	SessionTTL       time.Duration // abandoned uploads expire this long after their last chunk
+	CleanupInterval  time.Duration
+	Timeout          time.Duration // read and write deadline of upload requests, instead of the server's
+
+	// Batch uploads: files per request, total request size and files processed at once
+	BatchMaxFiles    int
+	BatchMaxSize     int64
+	BatchConcurrency int
 }
 
 type RedisConfig struct {
@@ -89,6 +157,163 @@ type PaginationConfig struct {
 	MaxPageSize     int
 }
 
+type GeocodingConfig struct {
+	Provider      string
+	DataPath      string // GeoNames cities file, fetched from DataURL by cmd/geocode -download
+	DataURL       string
+	MaxDistanceKm float64
+}
+
+type MailConfig struct {
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	OutputDir    string
+	// FrontendURL is the base URL of the web app that links in emails point to
+	FrontendURL string
+}
+
+type TokenConfig struct {
+	EmailVerificationTTL time.Duration
+	PasswordResetTTL     time.Duration
+	LoginChallengeTTL    time.Duration
+}
+
+type AccountConfig struct {
+	DeletionGracePeriod time.Duration
+	PurgeInterval       time.Duration
+	// How recent a social login must be to stand in for the password of an account without one
+	ReauthenticationWindow time.Duration
+}
+
+type OAuthConfig struct {
+	Providers []OAuthProviderConfig
+	StateTTL  time.Duration
+}
+
+type StorageConfig struct {
+	Driver    string
+	LocalPath string
+	// S3-compatible object storage
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3PathStyle bool
+	// SignedURLTTL is how long media download links to the object storage stay valid
+	SignedURLTTL time.Duration
+	// RedirectDownloads sends media downloads to signed URLs instead of streaming them
+	// through the API, when the backend supports them
+	RedirectDownloads bool
+}
+
+// QuotaConfig limits the total size and number of media files of each user; zero means
+// unlimited. Role limits replace the defaults for users of that role, and admins can
+// set limits for single users on top.
+type QuotaConfig struct {
+	MaxBytes     int64
+	MaxFiles     int64
+	RoleMaxBytes map[string]int64
+	RoleMaxFiles map[string]int64
+}
+
+// VideoConfig controls the background processing of uploaded videos. Posters need an
+// ffmpeg binary; without one only the container metadata is read.
+type VideoConfig struct {
+	FFmpegPath    string // "" disables poster frames
+	PosterWidth   int
+	PosterTimeout time.Duration
+}
+
+// JobsConfig controls the background job workers. Failed jobs are retried after
+// BackoffBase, doubling up to BackoffMax, until MaxAttempts is reached.
+type JobsConfig struct {
+	Workers      int // 0 runs no workers on this instance; jobs are still queued
+	PollInterval time.Duration
+	MaxAttempts  int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	Timeout      time.Duration // longest a single run may take
+	Retention    time.Duration // how long succeeded jobs are kept
+	DrainTimeout time.Duration // how long running jobs may finish on shutdown
+}
+
+// ReconcileConfig controls the periodic check of the media storage against the
+// database. Without Purge it only reports what it finds.
+type ReconcileConfig struct {
+	Interval time.Duration // 0 disables the job
+	Purge    bool
+	// GracePeriod protects files and blobs younger than it, which uploads in progress
+	// store before their media row exists
+	GracePeriod time.Duration
+	// DeletedMemoryRetention is how long media of deleted memories are kept; 0 keeps them
+	DeletedMemoryRetention time.Duration
+}
+
+// LoginThrottleConfig locks an account or client address out after too many failed
+// logins; every further failure doubles the lockout up to MaxLockout
+type LoginThrottleConfig struct {
+	AccountMaxAttempts int
+	IPMaxAttempts      int
+	FailureWindow      time.Duration
+	BaseLockout        time.Duration
+	MaxLockout         time.Duration
+}
+
+type OAuthProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// ResponseMode "form_post" makes the provider POST the code and state to the
+	// redirect URL instead of adding them to its query; Apple requires it when asking
+	// for the name or email
+	ResponseMode string
+}
+
+const (
+	defaultJWTSecret       = "your-super-secret-jwt-key"
+	defaultEncryptionKey   = "your-encryption-key"
+	defaultTokenSigningKey = "your-token-signing-key"
+)
+
+// placeholderSecrets are the secrets and keys shipped in examples and default configs
+var placeholderSecrets = []string{
+	defaultJWTSecret,
+	"your-super-secret-jwt-key-change-this-in-production",
+	"your_super_secret_jwt_key_change_this_in_production",
+	defaultEncryptionKey,
+	"your-encryption-key-change-this-in-production",
+	defaultTokenSigningKey,
+	"your-token-signing-key-change-this-in-production",
+}
+
+// minProductionSecretLength is the shortest secret or key accepted in production
+const minProductionSecretLength = 32
+
+// wellKnownIssuers are used when a provider of that name has no OIDC_<NAME>_ISSUER
+var wellKnownIssuers = map[string]string{
+	"google": "https://accounts.google.com",
+	"apple":  "https://appleid.apple.com",
+}
+
+// wellKnownScopes replace the default scopes for providers that do not accept them
+var wellKnownScopes = map[string][]string{
+	"apple": {"name", "email"},
+}
+
+// wellKnownResponseModes are used when a provider of that name has no
+// OIDC_<NAME>_RESPONSE_MODE
+var wellKnownResponseModes = map[string]string{
+	"apple": "form_post",
+}
+
 var AppConfig *Config
 
 // LoadConfig loads configuration from environment variables
@@ -109,9 +334,12 @@ func LoadConfig() *Config {
 			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
 		},
 		JWT: JWTConfig{
-			Secret: getEnv("JWT_SECRET", "your-super-secret-jwt-key"),
-			Expiry: getEnvAsDuration("JWT_EXPIRY", 24*time.Hour),
+			Secret:       getEnv("JWT_SECRET", defaultJWTSecret),
+			Expiry:       getEnvAsDuration("JWT_EXPIRY", 24*time.Hour),
+			KeyDir:       getEnv("JWT_KEY_DIR", ""),
+			SigningKeyID: getEnv("JWT_SIGNING_KEY_ID", ""),
 		},
+		Keys: loadKeys(),
 		Server: ServerConfig{
 			Port: getEnv("PORT", "8080"),
 			Host: getEnv("HOST", "0.0.0.0"),
@@ -119,7 +347,7 @@ func LoadConfig() *Config {
 		Upload: UploadConfig{
 			Path:         getEnv("UPLOAD_PATH", "./uploads"),
 			MaxFileSize:  getEnv("MAX_FILE_SIZE", "50MB"),
-			AllowedTypes: getEnvAsSlice("ALLOWED_FILE_TYPES", []string{"image/jpeg", "image/png", "image/gif", "video/mp4"}),
+			AllowedTypes: getEnvAsSlice("ALLOWED_FILE_TYPES", []string{"image/jpeg", "image/png", "image/gif", "image/webp", "image/heic", "image/heif", "video/mp4", "video/quicktime", "video/webm"}),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -140,8 +368,93 @@ func LoadConfig() *Config {
 			DefaultPageSize: getEnvAsInt("DEFAULT_PAGE_SIZE", 20),
 			MaxPageSize:     getEnvAsInt("MAX_PAGE_SIZE", 100),
 		},
+		Geocoding: GeocodingConfig{
+			Provider:      getEnv("GEOCODER_PROVIDER", "offline"),
+			DataPath:      getEnv("GEOCODER_DATA_PATH", "./data/cities15000.txt"),
+			DataURL:       getEnv("GEOCODER_DATA_URL", "https://download.geonames.org/export/dump/cities15000.zip"),
+			MaxDistanceKm: getEnvAsFloat("GEOCODER_MAX_DISTANCE_KM", 100),
+		},
+		Mail: MailConfig{
+			Driver:       getEnv("MAIL_DRIVER", "log"),
+			From:         getEnv("MAIL_FROM", "Map Memories <no-reply@mapmemories.com>"),
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+			OutputDir:    getEnv("MAIL_OUTPUT_DIR", "./tmp/mail"),
+			FrontendURL:  strings.TrimRight(getEnv("FRONTEND_URL", "http://localhost:3000"), "/"),
+		},
+		Tokens: TokenConfig{
+			EmailVerificationTTL: getEnvAsDuration("EMAIL_VERIFICATION_TTL", 48*time.Hour),
+			PasswordResetTTL:     getEnvAsDuration("PASSWORD_RESET_TTL", time.Hour),
+			LoginChallengeTTL:    getEnvAsDuration("LOGIN_CHALLENGE_TTL", 5*time.Minute),
+		},
+		Account: AccountConfig{
+			DeletionGracePeriod:    getEnvAsDuration("ACCOUNT_DELETION_GRACE_PERIOD", 30*24*time.Hour),
+			PurgeInterval:          getEnvAsDuration("ACCOUNT_PURGE_INTERVAL", time.Hour),
+			ReauthenticationWindow: getEnvAsDuration("ACCOUNT_REAUTH_WINDOW", 10*time.Minute),
+		},
+		OAuth: OAuthConfig{
+			StateTTL: getEnvAsDuration("OIDC_STATE_TTL", 10*time.Minute),
+		},
+		LoginThrottle: LoginThrottleConfig{
+			AccountMaxAttempts: getEnvAsInt("LOGIN_MAX_ATTEMPTS_PER_ACCOUNT", 5),
+			IPMaxAttempts:      getEnvAsInt("LOGIN_MAX_ATTEMPTS_PER_IP", 20),
+			FailureWindow:      getEnvAsDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+			BaseLockout:        getEnvAsDuration("LOGIN_BASE_LOCKOUT", time.Minute),
+			MaxLockout:         getEnvAsDuration("LOGIN_MAX_LOCKOUT", time.Hour),
+		},
+		Video: VideoConfig{
+			FFmpegPath:    getEnv("VIDEO_FFMPEG_PATH", "ffmpeg"),
+			PosterWidth:   getEnvAsInt("VIDEO_POSTER_WIDTH", 640),
+			PosterTimeout: getEnvAsDuration("VIDEO_POSTER_TIMEOUT", time.Minute),
+		},
+		Jobs: JobsConfig{
+			Workers:      getEnvAsInt("JOBS_WORKERS", 4),
+			PollInterval: getEnvAsDuration("JOBS_POLL_INTERVAL", 2*time.Second),
+			MaxAttempts:  getEnvAsInt("JOBS_MAX_ATTEMPTS", 5),
+			BackoffBase:  getEnvAsDuration("JOBS_BACKOFF_BASE", 30*time.Second),
+			BackoffMax:   getEnvAsDuration("JOBS_BACKOFF_MAX", time.Hour),
+			Timeout:      getEnvAsDuration("JOBS_TIMEOUT", 15*time.Minute),
+			Retention:    getEnvAsDuration("JOBS_RETENTION", 7*24*time.Hour),
+			DrainTimeout: getEnvAsDuration("JOBS_DRAIN_TIMEOUT", 30*time.Second),
+		},
+		Reconcile: ReconcileConfig{
+			Interval:               getEnvAsDuration("RECONCILE_INTERVAL", 24*time.Hour),
+			Purge:                  getEnvAsBool("RECONCILE_PURGE", false),
+			GracePeriod:            getEnvAsDuration("RECONCILE_GRACE_PERIOD", 24*time.Hour),
+			DeletedMemoryRetention: getEnvAsDuration("RECONCILE_DELETED_MEMORY_RETENTION", 30*24*time.Hour),
+		},
+	}
+
+	config.Upload.MaxImagePixels = int64(getEnvAsInt("UPLOAD_MAX_IMAGE_PIXELS", 100000000))
+	config.Upload.StagingPath = getEnv("UPLOAD_STAGING_PATH", "./tmp/uploads")
+	config.Upload.ChunkSize = parseFileSize(getEnv("UPLOAD_CHUNK_SIZE", "5MB"))
+	config.Upload.MaxChunkSize = parseFileSize(getEnv("UPLOAD_MAX_CHUNK_SIZE", "16MB"))
+	config.Upload.MaxResumableSize = parseFileSize(getEnv("MAX_RESUMABLE_FILE_SIZE", "2GB"))
+	config.Upload.SessionTTL = getEnvAsDuration("UPLOAD_SESSION_TTL", 24*time.Hour)
+	config.Upload.CleanupInterval = getEnvAsDuration("UPLOAD_CLEANUP_INTERVAL", time.Hour)
+	config.Upload.Timeout = getEnvAsDuration("UPLOAD_TIMEOUT", 30*time.Minute)
+	config.Upload.BatchMaxFiles = getEnvAsInt("UPLOAD_BATCH_MAX_FILES", 50)
+	config.Upload.BatchMaxSize = parseFileSize(getEnv("UPLOAD_BATCH_MAX_SIZE", "500MB"))
+	config.Upload.BatchConcurrency = getEnvAsInt("UPLOAD_BATCH_CONCURRENCY", 4)
+
+	config.Storage = StorageConfig{
+		Driver:            getEnv("STORAGE_DRIVER", "local"),
+		LocalPath:         getEnv("STORAGE_LOCAL_PATH", config.Upload.Path),
+		S3Endpoint:        getEnv("STORAGE_S3_ENDPOINT", ""),
+		S3Region:          getEnv("STORAGE_S3_REGION", "us-east-1"),
+		S3Bucket:          getEnv("STORAGE_S3_BUCKET", ""),
+		S3AccessKey:       getEnv("STORAGE_S3_ACCESS_KEY", ""),
+		S3SecretKey:       getEnv("STORAGE_S3_SECRET_KEY", ""),
+		S3PathStyle:       getEnvAsBool("STORAGE_S3_PATH_STYLE", false),
+		SignedURLTTL:      getEnvAsDuration("STORAGE_SIGNED_URL_TTL", 15*time.Minute),
+		RedirectDownloads: getEnvAsBool("STORAGE_REDIRECT_DOWNLOADS", false),
 	}
 
+	config.OAuth.Providers = loadOAuthProviders(config.Mail.FrontendURL)
+	config.Quota = loadQuotas()
+
 	// Parse max file size
 	config.Upload.MaxFileSizeInt = parseFileSize(config.Upload.MaxFileSize)
 
@@ -149,6 +462,42 @@ func LoadConfig() *Config {
 	return config
 }
 
+// Validate reports configuration that is unsafe to run with. In production the JWT
+// secret, the encryption key and the token signing key must be set to real values,
+// and to different ones. The JWT secret is not checked when tokens are signed with
+// the keys in JWT_KEY_DIR.
+func (c *Config) Validate() error {
+	if c.Environment != "production" {
+		return nil
+	}
+
+	type secret struct{ name, value string }
+	secrets := []secret{
+		{"ENCRYPTION_KEY", c.Keys.EncryptionKeys[c.Keys.EncryptionKeyVersion]},
+		{"TOKEN_SIGNING_KEY", c.Keys.TokenSigningKeys[c.Keys.TokenSigningKeyVersion]},
+	}
+	if c.JWT.KeyDir == "" {
+		secrets = append([]secret{{"JWT_SECRET", c.JWT.Secret}}, secrets...)
+	}
+	for i, secret := range secrets {
+		for _, placeholder := range placeholderSecrets {
+			if secret.value == placeholder {
+				return fmt.Errorf("%s is set to the default value; set a random secret for production", secret.name)
+			}
+		}
+		if len(secret.value) < minProductionSecretLength {
+			return fmt.Errorf("%s must be at least %d characters in production", secret.name, minProductionSecretLength)
+		}
+		for _, other := range secrets[:i] {
+			if secret.value == other.value {
+				return fmt.Errorf("%s must differ from %s", secret.name, other.name)
+			}
+		}
+	}
+
+	return nil
+}
+
 // GetDSN returns the database connection string
 func (c *Config) GetDSN() string {
 	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=UTC",
@@ -161,6 +510,87 @@ func (c *Config) GetDSN() string {
 	)
 }
 
+// loadQuotas reads STORAGE_QUOTA_BYTES/FILES and their per-role variants, e.g.
+// STORAGE_QUOTA_BYTES_ADMIN=0 for unlimited admins
+func loadQuotas() QuotaConfig {
+	quota := QuotaConfig{
+		MaxBytes:     parseFileSize(getEnv("STORAGE_QUOTA_BYTES", "5GB")),
+		MaxFiles:     int64(getEnvAsInt("STORAGE_QUOTA_FILES", 10000)),
+		RoleMaxBytes: make(map[string]int64),
+		RoleMaxFiles: make(map[string]int64),
+	}
+
+	for _, role := range []string{"user", "moderator", "admin"} {
+		suffix := "_" + strings.ToUpper(role)
+		if value := os.Getenv("STORAGE_QUOTA_BYTES" + suffix); value != "" {
+			quota.RoleMaxBytes[role] = parseFileSize(value)
+		}
+		if value := os.Getenv("STORAGE_QUOTA_FILES" + suffix); value != "" {
+			quota.RoleMaxFiles[role] = int64(getEnvAsInt("STORAGE_QUOTA_FILES"+suffix, 0))
+		}
+	}
+	return quota
+}
+
+// loadKeys reads the encryption and token signing key rings
+func loadKeys() KeysConfig {
+	keys := KeysConfig{}
+	keys.EncryptionKeys, keys.EncryptionKeyVersion = loadKeyRing("ENCRYPTION", defaultEncryptionKey)
+	keys.TokenSigningKeys, keys.TokenSigningKeyVersion = loadKeyRing("TOKEN_SIGNING", defaultTokenSigningKey)
+	return keys
+}
+
+// loadKeyRing reads <NAME>_KEY with its <NAME>_KEY_VERSION (default 1) and the retired
+// keys in <NAME>_PREVIOUS_KEYS, given as "version:key" pairs (e.g. "1:old-key,2:newer-key")
+func loadKeyRing(name, defaultKey string) (map[int]string, int) {
+	version := getEnvAsInt(name+"_KEY_VERSION", 1)
+	ring := make(map[int]string)
+	for _, entry := range getEnvAsSlice(name+"_PREVIOUS_KEYS", nil) {
+		versionStr, key, found := strings.Cut(strings.TrimSpace(entry), ":")
+		previous, err := strconv.Atoi(versionStr)
+		if !found || err != nil || key == "" {
+			log.Printf("Skipping malformed entry in %s_PREVIOUS_KEYS", name)
+			continue
+		}
+		ring[previous] = key
+	}
+	ring[version] = getEnv(name+"_KEY", defaultKey)
+	return ring, version
+}
+
+// loadOAuthProviders reads OIDC_PROVIDERS (e.g. "google,apple,keycloak") and the
+// OIDC_<NAME>_* variables of each provider; providers without a client ID are skipped
+func loadOAuthProviders(frontendURL string) []OAuthProviderConfig {
+	var providers []OAuthProviderConfig
+	for _, name := range getEnvAsSlice("OIDC_PROVIDERS", nil) {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		scopes := wellKnownScopes[name]
+		if scopes == nil {
+			scopes = []string{"openid", "email", "profile"}
+		}
+		provider := OAuthProviderConfig{
+			Name:         name,
+			Issuer:       getEnv(prefix+"ISSUER", wellKnownIssuers[name]),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", frontendURL+"/oauth/"+name+"/callback"),
+			Scopes:       getEnvAsSlice(prefix+"SCOPES", scopes),
+			ResponseMode: getEnv(prefix+"RESPONSE_MODE", wellKnownResponseModes[name]),
+		}
+		if provider.ClientID == "" || provider.Issuer == "" {
+			log.Printf("Skipping OIDC provider %s: issuer and client ID are required", name)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	return providers
+}
+
 // Helper functions
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
@@ -177,6 +607,22 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	valueStr := getEnv(key, "")
 	if value, err := time.ParseDuration(valueStr); err == nil {
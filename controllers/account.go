@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/models"
+	"map-memories-api/storage"
+	"map-memories-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DeleteAccount godoc
+// @Summary Delete account
+// @Description Schedule the current user's account for deletion. Requires the password, or for accounts without one a TOTP code or a recent sign-in. All sessions are signed out and API tokens revoked; logging in again before the grace period ends cancels the deletion. Afterwards memories, media files, likes and sessions are removed and the account is anonymized.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.DeleteAccountRequest true "Current password or TOTP code"
+// @Success 200 {object} models.APIResponse{data=models.AccountDeletionResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/account [delete]
+func (ac *AuthController) DeleteAccount(c *gin.Context) {
+	user, ok := currentUserWithPassword(c)
+	if !ok {
+		return
+	}
+
+	var req models.DeleteAccountRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	if !reauthenticate(c, user, req.Password, req.Code) {
+		return
+	}
+
+	scheduledAt := time.Now().Add(config.AppConfig.Account.DeletionGracePeriod)
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("deletion_scheduled_at", scheduledAt).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.APIToken{}).Error; err != nil {
+			return err
+		}
+		return revokeSessions(tx, user.ID, "")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to delete account",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Account scheduled for deletion",
+		models.AccountDeletionResponse{DeletionScheduledAt: scheduledAt},
+	))
+}
+
+// ExportAccount godoc
+// @Summary Export account data
+// @Description Download a ZIP archive with the current user's profile and all their memories as JSON, plus the original media files
+// @Tags Authentication
+// @Produce application/zip
+// @Security BearerAuth
+// @Success 200 {file} file
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/export [get]
+func (ac *AuthController) ExportAccount(c *gin.Context) {
+	user, ok := currentUserWithPassword(c)
+	if !ok {
+		return
+	}
+
+	var memories []models.Memory
+	if err := database.DB.Preload("Location").Preload("Media").
+		Where("user_id = ?", user.ID).Order("created_at ASC").Find(&memories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to fetch memories",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	// Everything is loaded before streaming so errors can still produce a JSON response
+	memories = attachUser(memories, user)
+	viewer := models.Viewer{UserID: user.ID}
+	memoryResponses := make([]models.MemoryResponse, len(memories))
+	for i, memory := range memories {
+		memoryResponses[i] = memory.ToResponseFor(viewer)
+	}
+
+	filename := fmt.Sprintf("map-memories-%s-%s.zip", user.Username, time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	if err := writeExportArchive(c.Request.Context(), archive, user, memories, memoryResponses); err != nil {
+		// Headers are already sent; the truncated archive tells the client something went wrong
+		log.Printf("Failed to export data of user %d: %v", user.ID, err)
+		return
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("Failed to finish export of user %d: %v", user.ID, err)
+	}
+}
+
+// writeExportArchive writes profile.json, memories.json and media/<memory uuid>/<file> entries
+func writeExportArchive(ctx context.Context, archive *zip.Writer, user *models.User, memories []models.Memory, memoryResponses []models.MemoryResponse) error {
+	if err := writeJSONEntry(archive, "profile.json", user.ToResponse()); err != nil {
+		return err
+	}
+	if err := writeJSONEntry(archive, "memories.json", memoryResponses); err != nil {
+		return err
+	}
+
+	for _, memory := range memories {
+		for _, media := range memory.Media {
+			name := path.Join("media", memory.UUID.String(), media.UUID.String()+"_"+path.Base(media.OriginalFilename))
+			if err := writeMediaEntry(ctx, archive, name, &media); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeJSONEntry adds an indented JSON document to the archive
+func writeJSONEntry(archive *zip.Writer, name string, value interface{}) error {
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// writeMediaEntry copies a media file from storage into the archive; missing files are skipped
+func writeMediaEntry(ctx context.Context, archive *zip.Writer, name string, media *models.Media) error {
+	file, _, err := utils.OpenMediaFile(ctx, media)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			log.Printf("Skipping missing media file %s in export", media.UUID)
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(entry, file)
+	return err
+}
+
+// attachUser sets the already loaded owner on memories instead of preloading it again
+func attachUser(memories []models.Memory, user *models.User) []models.Memory {
+	for i := range memories {
+		memories[i].User = *user
+	}
+	return memories
+}
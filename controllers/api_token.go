@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"map-memories-api/database"
+	"map-memories-api/middleware"
+	"map-memories-api/models"
+	"map-memories-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetAPITokens godoc
+// @Summary List API tokens
+// @Description Get the current user's personal API tokens. The tokens themselves are never returned again after creation.
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse{data=[]models.APITokenResponse}
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/tokens [get]
+func (ac *AuthController) GetAPITokens(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	var tokens []models.APIToken
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to fetch API tokens",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	tokenResponses := make([]models.APITokenResponse, len(tokens))
+	for i, token := range tokens {
+		tokenResponses[i] = token.ToResponse()
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"API tokens retrieved successfully",
+		tokenResponses,
+	))
+}
+
+// CreateAPIToken godoc
+// @Summary Create an API token
+// @Description Create a personal API token for scripts and integrations. The token is only shown in this response; send it as "Authorization: Bearer <token>".
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.APITokenCreateRequest true "Token name, scopes and optional lifetime"
+// @Success 201 {object} models.APIResponse{data=models.APITokenCreatedResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/tokens [post]
+func (ac *AuthController) CreateAPIToken(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	var req models.APITokenCreateRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	token, prefix, hash, err := utils.GenerateAPIToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to create API token",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	apiToken := models.APIToken{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    prefix,
+		TokenHash: hash,
+		Scopes:    uniqueScopes(req.Scopes),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		apiToken.ExpiresAt = &expiresAt
+	}
+
+	if err := database.DB.Create(&apiToken).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to create API token",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		"API token created successfully; copy it now, it will not be shown again",
+		models.APITokenCreatedResponse{
+			APITokenResponse: apiToken.ToResponse(),
+			Token:            token,
+		},
+	))
+}
+
+// RevokeAPIToken godoc
+// @Summary Revoke an API token
+// @Description Delete one of the current user's API tokens; it stops working immediately
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Param uuid path string true "API token UUID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/tokens/{uuid} [delete]
+func (ac *AuthController) RevokeAPIToken(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	tokenUUID, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid UUID format",
+			"INVALID_UUID",
+			nil,
+		))
+		return
+	}
+
+	result := database.DB.Where("uuid = ? AND user_id = ?", tokenUUID, userID).Delete(&models.APIToken{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to revoke API token",
+			"INTERNAL_ERROR",
+			result.Error.Error(),
+		))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+			"API token not found",
+			"API_TOKEN_NOT_FOUND",
+			nil,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"API token revoked successfully",
+		nil,
+	))
+}
+
+// uniqueScopes drops repeated scopes while keeping their order
+func uniqueScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	unique := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			unique = append(unique, scope)
+		}
+	}
+	return unique
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -74,8 +75,13 @@ func (ac *AuthController) Register(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(&user)
+	// Ask the user to confirm their address; registration succeeds even if the email fails
+	if err := sendVerificationEmail(&user, user.Email); err != nil {
+		log.Printf("Failed to send verification email to user %d: %v", user.ID, err)
+	}
+
+	// Generate JWT token for a new session
+	authResponse, err := startSession(c, &user, models.LoginMethodPassword)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
 			"Failed to generate authentication token",
@@ -85,14 +91,6 @@ func (ac *AuthController) Register(c *gin.Context) {
 		return
 	}
 
-	// Create response
-	authResponse := models.AuthResponse{
-		User:        user.ToResponse(),
-		AccessToken: token,
-		TokenType:   "Bearer",
-		ExpiresIn:   int64(time.Hour * 24 / time.Second), // 24 hours in seconds
-	}
-
 	c.JSON(http.StatusCreated, models.SuccessResponse(
 		"User registered successfully",
 		authResponse,
@@ -106,9 +104,10 @@ func (ac *AuthController) Register(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param credentials body models.UserLoginRequest true "User login credentials"
-// @Success 200 {object} models.APIResponse{data=models.AuthResponse}
+// @Success 200 {object} models.APIResponse{data=models.AuthResponse} "Logged in, or models.TwoFactorChallengeResponse when a second factor is required"
 // @Failure 400 {object} models.APIResponse
 // @Failure 401 {object} models.APIResponse
+// @Failure 429 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /auth/login [post]
 func (ac *AuthController) Login(c *gin.Context) {
@@ -118,10 +117,26 @@ func (ac *AuthController) Login(c *gin.Context) {
 		return
 	}
 
+	// Refuse to check the password while the account or address is locked out
+	if err := checkLoginLockout(c, req.Email); err != nil {
+		if locked, ok := err.(*loginLockedError); ok {
+			recordLoginFailure(c, nil, req.Email, models.LoginMethodPassword, loginFailureLocked)
+			respondLoginLocked(c, locked)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
 	// Find user by email
 	var user models.User
 	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			recordLoginFailure(c, nil, req.Email, models.LoginMethodPassword, loginFailureUnknownEmail)
 			c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
 				"Invalid email or password",
 				"INVALID_CREDENTIALS",
@@ -139,6 +154,7 @@ func (ac *AuthController) Login(c *gin.Context) {
 
 	// Verify password
 	if err := utils.VerifyPassword(user.PasswordHash, req.Password); err != nil {
+		recordLoginFailure(c, &user, req.Email, models.LoginMethodPassword, loginFailureInvalidPassword)
 		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
 			"Invalid email or password",
 			"INVALID_CREDENTIALS",
@@ -147,29 +163,7 @@ func (ac *AuthController) Login(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := utils.GenerateJWT(&user)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
-			"Failed to generate authentication token",
-			"INTERNAL_ERROR",
-			nil,
-		))
-		return
-	}
-
-	// Create response
-	authResponse := models.AuthResponse{
-		User:        user.ToResponse(),
-		AccessToken: token,
-		TokenType:   "Bearer",
-		ExpiresIn:   int64(time.Hour * 24 / time.Second), // 24 hours in seconds
-	}
-
-	c.JSON(http.StatusOK, models.SuccessResponse(
-		"Login successful",
-		authResponse,
-	))
+	completeLogin(c, &user, models.LoginMethodPassword)
 }
 
 // GetProfile godoc
@@ -272,6 +266,12 @@ func (ac *AuthController) UpdateProfile(c *gin.Context) {
 	if req.AvatarURL != "" {
 		user.AvatarURL = req.AvatarURL
 	}
+	if req.Bio != "" {
+		user.Bio = req.Bio
+	}
+	if req.ProfileVisibility != "" {
+		user.ProfileVisibility = req.ProfileVisibility
+	}
 
 	// Save changes
 	if err := database.DB.Save(&user).Error; err != nil {
@@ -299,10 +299,27 @@ func (ac *AuthController) UpdateProfile(c *gin.Context) {
 // @Failure 401 {object} models.APIResponse
 // @Router /auth/logout [post]
 func (ac *AuthController) Logout(c *gin.Context) {
-	// In a real application, you would invalidate the token
-	// For now, we'll just return a success response
-	// You could add token blacklisting here
-	
+	claims, exists := middleware.GetCurrentClaims(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	// Revoke the session so the token can no longer be used
+	if err := database.DB.Where("user_id = ? AND token_hash = ?", claims.UserID, middleware.SessionTokenHash(claims)).
+		Delete(&models.UserSession{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to logout",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
 	c.JSON(http.StatusOK, models.SuccessResponse(
 		"Logout successful",
 		nil,
@@ -322,4 +339,103 @@ func (ac *AuthController) TestAuthHeader(c *gin.Context) {
 			"header_length": len(authHeader),
 		},
 	})
-}
\ No newline at end of file
+}
+
+// startSession issues a JWT for the user and records its session with the client it was
+// started from and the login method, empty for a session re-issued after an account change
+func startSession(c *gin.Context, user *models.User, method string) (models.AuthResponse, error) {
+	token, claims, err := utils.GenerateJWTWithClaims(user)
+	if err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	now := time.Now()
+	session := models.UserSession{
+		UserID:      user.ID,
+		TokenHash:   middleware.SessionTokenHash(claims),
+		IPAddress:   c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		LoginMethod: method,
+		LastUsedAt:  &now,
+		ExpiresAt:   claims.ExpiresAt.Time,
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return models.AuthResponse{}, err
+	}
+
+	return models.AuthResponse{
+		User:        user.ToResponse(),
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(session.ExpiresAt) / time.Second),
+	}, nil
+}
+
+// revokeSessions deletes the user's sessions, optionally keeping the one with keepTokenHash
+func revokeSessions(tx *gorm.DB, userID uint, keepTokenHash string) error {
+	query := tx.Where("user_id = ?", userID)
+	if keepTokenHash != "" {
+		query = query.Where("token_hash <> ?", keepTokenHash)
+	}
+	return query.Delete(&models.UserSession{}).Error
+}
+
+// completeLogin finishes a login whose first factor was verified, asking for the second
+// factor when the account has two-factor authentication enabled
+func completeLogin(c *gin.Context, user *models.User, method string) {
+	// Accounts with two-factor authentication finish logging in at /auth/login/2fa
+	if user.IsTwoFactorEnabled() {
+		challenge, err := issueLoginChallenge(user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+				"Failed to start two-factor login",
+				"INTERNAL_ERROR",
+				nil,
+			))
+			return
+		}
+
+		c.JSON(http.StatusOK, models.SuccessResponse(
+			"Two-factor authentication required",
+			challenge,
+		))
+		return
+	}
+
+	finishLogin(c, user, method)
+}
+
+// finishLogin starts a session for a user whose credentials were verified, records the
+// login and writes the login response
+func finishLogin(c *gin.Context, user *models.User, method string) {
+	// Logging in during the grace period cancels a scheduled account deletion
+	if user.DeletionScheduledAt != nil {
+		if err := database.DB.Model(user).Update("deletion_scheduled_at", nil).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+				"Failed to restore account",
+				"INTERNAL_ERROR",
+				nil,
+			))
+			return
+		}
+		user.DeletionScheduledAt = nil
+	}
+
+	// Generate JWT token for a new session
+	authResponse, err := startSession(c, user, method)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to generate authentication token",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	recordLoginSuccess(c, user, method)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Login successful",
+		authResponse,
+	))
+}
@@ -0,0 +1,670 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/mailer"
+	"map-memories-api/middleware"
+	"map-memories-api/models"
+	"map-memories-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errTokenInvalid = errors.New("token is invalid")
+	errTokenExpired = errors.New("token has expired")
+	errTokenUsed    = errors.New("token has already been used")
+	errEmailTaken   = errors.New("email is already in use")
+)
+
+// VerifyEmail godoc
+// @Summary Verify email address
+// @Description Confirm the email address of an account with the token sent by email
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.VerifyEmailRequest true "Verification token"
+// @Success 200 {object} models.APIResponse{data=models.UserResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/verify-email [post]
+func (ac *AuthController) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	var user models.User
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, models.TokenPurposeEmailVerification, req.Token)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+
+		// Confirming a pending email change switches the account to the new address
+		if user.PendingEmail != "" && strings.EqualFold(token.Email, user.PendingEmail) {
+			var count int64
+			if err := tx.Model(&models.User{}).Where("email = ? AND id <> ?", user.PendingEmail, user.ID).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return errEmailTaken
+			}
+
+			user.Email = user.PendingEmail
+			user.PendingEmail = ""
+			user.EmailVerifiedAt = &now
+			return tx.Model(&user).Updates(map[string]interface{}{
+				"email":             user.Email,
+				"pending_email":     "",
+				"email_verified_at": now,
+			}).Error
+		}
+
+		// The address changed since the token was sent
+		if !strings.EqualFold(token.Email, user.Email) {
+			return errTokenInvalid
+		}
+
+		if user.IsEmailVerified() {
+			return nil
+		}
+
+		user.EmailVerifiedAt = &now
+		return tx.Model(&user).Update("email_verified_at", now).Error
+	})
+	if err == errEmailTaken {
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+			"User already exists with this email",
+			"USER_EXISTS",
+			nil,
+		))
+		return
+	}
+	if err != nil {
+		respondTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Email verified successfully",
+		user.ToResponse(),
+	))
+}
+
+// ResendVerificationEmail godoc
+// @Summary Resend verification email
+// @Description Send a new email verification link to the current user; previous links stop working
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/verify-email/resend [post]
+func (ac *AuthController) ResendVerificationEmail(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	if user.IsEmailVerified() {
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+			"Email address is already verified",
+			"EMAIL_ALREADY_VERIFIED",
+			nil,
+		))
+		return
+	}
+
+	if err := sendVerificationEmail(&user, user.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to send verification email",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Verification email sent",
+		nil,
+	))
+}
+
+// ForgotPassword godoc
+// @Summary Request a password reset
+// @Description Email a password reset link. The response is the same whether or not the address belongs to an account.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.ForgotPasswordRequest true "Account email"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Router /auth/forgot-password [post]
+func (ac *AuthController) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("email = ?", req.Email).First(&user).Error; err == nil {
+		if err := sendPasswordResetEmail(&user); err != nil {
+			log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+		}
+	} else if err != gorm.ErrRecordNotFound {
+		log.Printf("Failed to look up user for password reset: %v", err)
+	}
+
+	// Do not reveal whether the address is registered
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"If an account exists for this email, a password reset link has been sent",
+		nil,
+	))
+}
+
+// ResetPassword godoc
+// @Summary Reset password
+// @Description Choose a new password with the token sent by the forgot-password email
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/reset-password [post]
+func (ac *AuthController) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to process password",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, models.TokenPurposePasswordReset, req.Token)
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			return err
+		}
+		if !strings.EqualFold(token.Email, user.Email) {
+			return errTokenInvalid
+		}
+
+		updates := map[string]interface{}{"password_hash": hashedPassword}
+		// Receiving the reset email proves the user owns the address
+		if !user.IsEmailVerified() {
+			updates["email_verified_at"] = time.Now()
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		// Sign out everywhere; whoever knew the old password loses access
+		return revokeSessions(tx, user.ID, "")
+	})
+	if err != nil {
+		respondTokenError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Password reset successfully",
+		nil,
+	))
+}
+
+// ChangePassword godoc
+// @Summary Change password
+// @Description Change the password of the current user. Requires the current password; accounts created by social login set their first password with a TOTP code or right after signing in. Every other session is signed out and a new token is issued.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} models.APIResponse{data=models.AuthResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/password [put]
+func (ac *AuthController) ChangePassword(c *gin.Context) {
+	user, ok := currentUserWithPassword(c)
+	if !ok {
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	if !reauthenticate(c, user, req.CurrentPassword, req.Code) {
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to process password",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Update("password_hash", hashedPassword).Error; err != nil {
+			return err
+		}
+		return revokeSessions(tx, user.ID, "")
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to change password",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	// Re-issue a token for the caller since their session was revoked with the others
+	authResponse, err := startSession(c, user, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to generate authentication token",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Password changed successfully",
+		authResponse,
+	))
+}
+
+// ChangeEmail godoc
+// @Summary Change email address
+// @Description Start changing the email of the current user. Requires the current password, or for accounts without one a TOTP code or a recent sign-in; the new address becomes active once verified through the link sent to it.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.ChangeEmailRequest true "New email and current password"
+// @Success 200 {object} models.APIResponse{data=models.AuthResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/email [put]
+func (ac *AuthController) ChangeEmail(c *gin.Context) {
+	user, ok := currentUserWithPassword(c)
+	if !ok {
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	if !reauthenticate(c, user, req.CurrentPassword, req.Code) {
+		return
+	}
+
+	newEmail := strings.TrimSpace(req.NewEmail)
+	if strings.EqualFold(newEmail, user.Email) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"New email is the same as the current email",
+			"SAME_EMAIL",
+			nil,
+		))
+		return
+	}
+
+	// Check if another user already has this email
+	var existingUser models.User
+	if err := database.DB.Where("email = ?", newEmail).First(&existingUser).Error; err == nil {
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+			"User already exists with this email",
+			"USER_EXISTS",
+			nil,
+		))
+		return
+	}
+
+	user.PendingEmail = newEmail
+	if err := database.DB.Model(user).Update("pending_email", newEmail).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to change email",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := sendVerificationEmail(user, newEmail); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to send verification email",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+	if err := mailer.Send(mailer.EmailChangeNotice(user.Email, user.Username, newEmail)); err != nil {
+		log.Printf("Failed to notify user %d of email change: %v", user.ID, err)
+	}
+
+	// Re-issue the token so the caller's claims reflect the updated account
+	authResponse, err := startSession(c, user, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to generate authentication token",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Verification email sent to the new address",
+		authResponse,
+	))
+}
+
+// currentUserWithPassword loads the authenticated user and writes the error response on failure
+func currentUserWithPassword(c *gin.Context) (*models.User, bool) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return nil, false
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"User not found",
+				"USER_NOT_FOUND",
+				nil,
+			))
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return nil, false
+	}
+
+	return &user, true
+}
+
+// reauthenticate confirms who is making a sensitive change. Accounts with a password need
+// the current password. Accounts created by social login have none; they need a TOTP code
+// when two-factor authentication is on, or a session signed in within the
+// reauthentication window.
+func reauthenticate(c *gin.Context, user *models.User, password, code string) bool {
+	if user.PasswordHash != "" {
+		if err := utils.VerifyPassword(user.PasswordHash, password); err != nil {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+				"Current password is incorrect",
+				"INVALID_CREDENTIALS",
+				nil,
+			))
+			return false
+		}
+		return true
+	}
+
+	if code != "" && user.IsTwoFactorEnabled() {
+		if !isTOTPCode(code) {
+			respondSecondFactorError(c, errInvalidSecondFactor)
+			return false
+		}
+		if err := verifyTOTP(database.DB, user, code); err != nil {
+			respondSecondFactorError(c, err)
+			return false
+		}
+		return true
+	}
+
+	recent, err := signedInRecently(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return false
+	}
+	if !recent {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Sign in again or enter a two-factor code to confirm this change",
+			"REAUTHENTICATION_REQUIRED",
+			gin.H{"max_login_age_seconds": int64(config.AppConfig.Account.ReauthenticationWindow / time.Second)},
+		))
+		return false
+	}
+	return true
+}
+
+// signedInRecently reports whether the session of the request was started by a social
+// login within the reauthentication window. A two-factor login counts as well: without a
+// password its first factor was a social login.
+func signedInRecently(c *gin.Context, user *models.User) (bool, error) {
+	claims, exists := middleware.GetCurrentClaims(c)
+	if !exists {
+		return false, nil
+	}
+
+	var count int64
+	err := database.DB.Model(&models.UserSession{}).
+		Where("user_id = ? AND token_hash = ? AND login_method IN ? AND created_at > ?",
+			user.ID, middleware.SessionTokenHash(claims),
+			[]string{models.LoginMethodOAuth, models.LoginMethodTwoFactor},
+			time.Now().Add(-config.AppConfig.Account.ReauthenticationWindow)).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// sendVerificationEmail issues an email verification token for the address and emails the link
+func sendVerificationEmail(user *models.User, email string) error {
+	token, err := issueUserToken(user.ID, models.TokenPurposeEmailVerification, email,
+		config.AppConfig.Tokens.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(mailer.VerificationEmail(email, user.Username, frontendLink("/verify-email", token)))
+}
+
+// sendPasswordResetEmail issues a password reset token and emails the link
+func sendPasswordResetEmail(user *models.User) error {
+	token, err := issueUserToken(user.ID, models.TokenPurposePasswordReset, user.Email,
+		config.AppConfig.Tokens.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(mailer.PasswordResetEmail(user.Email, user.Username, frontendLink("/reset-password", token)))
+}
+
+// issueUserToken creates a new token for the user and purpose, invalidating the unused
+// tokens issued before it, and returns the token to send
+func issueUserToken(userID uint, purpose, email string, ttl time.Duration) (string, error) {
+	token, hash, err := utils.GenerateSignedToken(purpose)
+	if err != nil {
+		return "", err
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hash,
+			Email:     email,
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeUserToken validates a token for the purpose and marks it used; the update is
+// guarded so concurrent requests cannot use the same token twice
+func consumeUserToken(tx *gorm.DB, purpose, token string) (*models.UserToken, error) {
+	hash, err := utils.VerifySignedToken(purpose, token)
+	if err != nil {
+		return nil, errTokenInvalid
+	}
+
+	var userToken models.UserToken
+	if err := tx.Where("token_hash = ? AND purpose = ?", hash, purpose).First(&userToken).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errTokenInvalid
+		}
+		return nil, err
+	}
+
+	if userToken.UsedAt != nil {
+		return nil, errTokenUsed
+	}
+	if userToken.IsExpired() {
+		return nil, errTokenExpired
+	}
+
+	now := time.Now()
+	result := tx.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", userToken.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errTokenUsed
+	}
+	userToken.UsedAt = &now
+
+	return &userToken, nil
+}
+
+// respondTokenError writes the response for an error returned while consuming a token
+func respondTokenError(c *gin.Context, err error) {
+	switch err {
+	case errTokenInvalid, gorm.ErrRecordNotFound:
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid token",
+			"INVALID_TOKEN",
+			nil,
+		))
+	case errTokenExpired:
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Token has expired",
+			"TOKEN_EXPIRED",
+			nil,
+		))
+	case errTokenUsed:
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Token has already been used",
+			"TOKEN_USED",
+			nil,
+		))
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+	}
+}
+
+// frontendLink builds a link into the web app carrying a token
+func frontendLink(path, token string) string {
+	return config.AppConfig.Mail.FrontendURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// requireVerifiedEmail writes a 403 response unless the user has verified their email
+func requireVerifiedEmail(c *gin.Context, userID uint) bool {
+	var user models.User
+	if err := database.DB.Select("id, email_verified_at").First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return false
+	}
+
+	if !user.IsEmailVerified() {
+		c.JSON(http.StatusForbidden, models.ErrorResponseWithCode(
+			"Verify your email address before publishing public memories",
+			"EMAIL_NOT_VERIFIED",
+			nil,
+		))
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,273 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"map-memories-api/database"
+	"map-memories-api/mailer"
+	"map-memories-api/middleware"
+	"map-memories-api/models"
+	"map-memories-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// captureMail sends the emails of a test through a FileMailer in a temporary directory
+func captureMail(t *testing.T) *mailer.FileMailer {
+	t.Helper()
+
+	fileMailer, err := mailer.NewFileMailer(t.TempDir(), "noreply@example.com")
+	if err != nil {
+		t.Fatalf("create file mailer: %v", err)
+	}
+	previous := mailer.Default
+	mailer.Default = fileMailer
+	t.Cleanup(func() { mailer.Default = previous })
+	return fileMailer
+}
+
+// lastEmailToken returns the token in the link of the last email with the given subject
+// sent to an address, checking that the link points at path
+func lastEmailToken(t *testing.T, fileMailer *mailer.FileMailer, to, subject, path string) string {
+	t.Helper()
+
+	var message *mailer.Message
+	sent := fileMailer.Sent(to)
+	for i := range sent {
+		if sent[i].Subject == subject {
+			message = &sent[i]
+		}
+	}
+	if message == nil {
+		t.Fatalf("no %q email was sent to %s (sent: %+v)", subject, to, sent)
+	}
+
+	for _, field := range strings.Fields(message.Body) {
+		if !strings.Contains(field, "?token=") {
+			continue
+		}
+		link, err := url.Parse(field)
+		if err != nil {
+			t.Fatalf("parse link %q: %v", field, err)
+		}
+		if link.Path != path {
+			t.Errorf("link path = %q, want %q", link.Path, path)
+		}
+		return link.Query().Get("token")
+	}
+	t.Fatalf("email has no link with a token:\n%s", message.Body)
+	return ""
+}
+
+// registerUser registers a new account and returns its email and access token
+func registerUser(t *testing.T, router *gin.Engine) (string, string) {
+	t.Helper()
+
+	suffix := strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+	email := "user_" + suffix + "@example.com"
+	body := fmt.Sprintf(`{"username":"user_%s","email":%q,"password":"first-password"}`, suffix, email)
+
+	response := decodeResponse(t, request(t, router, http.MethodPost, "/api/v1/auth/register", "", body), http.StatusCreated)
+	var auth models.AuthResponse
+	if err := json.Unmarshal(response.Data, &auth); err != nil {
+		t.Fatalf("decode auth response: %v", err)
+	}
+	if auth.User.EmailVerified {
+		t.Fatal("a new account starts out verified")
+	}
+	return email, auth.AccessToken
+}
+
+// expireToken moves the expiry of an issued token into the past
+func expireToken(t *testing.T, token string) {
+	t.Helper()
+
+	result := database.DB.Model(&models.UserToken{}).
+		Where("token_hash = ?", utils.HashToken(token)).
+		Update("expires_at", time.Now().Add(-time.Minute))
+	if result.Error != nil || result.RowsAffected != 1 {
+		t.Fatalf("expire token: %v (%d rows)", result.Error, result.RowsAffected)
+	}
+}
+
+// mustErrorCode fails unless the response is a 400 with the given error code
+func mustErrorCode(t *testing.T, recorder *httptest.ResponseRecorder, code string) {
+	t.Helper()
+
+	response := decodeResponse(t, recorder, http.StatusBadRequest)
+	var apiError models.ErrorResponse
+	if err := json.Unmarshal(response.Error, &apiError); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if apiError.Code != code {
+		t.Errorf("error code = %q, want %q", apiError.Code, code)
+	}
+}
+
+func tokenBody(token string) string {
+	return fmt.Sprintf(`{"token":%q}`, token)
+}
+
+func TestEmailVerificationTokenRoundTrip(t *testing.T) {
+	router := setupTestDB(t)
+	fileMailer := captureMail(t)
+
+	email, accessToken := registerUser(t, router)
+	subject := mailer.VerificationEmail("", "", "").Subject
+	registered := lastEmailToken(t, fileMailer, email, subject, "/verify-email")
+
+	// Resending supersedes the link sent at registration
+	decodeResponse(t, request(t, router, http.MethodPost, "/api/v1/auth/verify-email/resend", accessToken, ""), http.StatusOK)
+	expired := lastEmailToken(t, fileMailer, email, subject, "/verify-email")
+	if expired == registered {
+		t.Fatal("resending reused the previous token")
+	}
+	mustErrorCode(t, request(t, router, http.MethodPost, "/api/v1/auth/verify-email", "", tokenBody(registered)), "TOKEN_USED")
+
+	expireToken(t, expired)
+	mustErrorCode(t, request(t, router, http.MethodPost, "/api/v1/auth/verify-email", "", tokenBody(expired)), "TOKEN_EXPIRED")
+
+	decodeResponse(t, request(t, router, http.MethodPost, "/api/v1/auth/verify-email/resend", accessToken, ""), http.StatusOK)
+	token := lastEmailToken(t, fileMailer, email, subject, "/verify-email")
+
+	// A tampered signature or a token for another purpose is rejected without being used up
+	mustErrorCode(t, request(t, router, http.MethodPost, "/api/v1/auth/verify-email", "", tokenBody(token+"x")), "INVALID_TOKEN")
+	other, _, err := utils.GenerateSignedToken(models.TokenPurposePasswordReset)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	mustErrorCode(t, request(t, router, http.MethodPost, "/api/v1/auth/verify-email", "", tokenBody(other)), "INVALID_TOKEN")
+
+	response := decodeResponse(t, request(t, router, http.MethodPost, "/api/v1/auth/verify-email", "", tokenBody(token)), http.StatusOK)
+	var user models.UserResponse
+	if err := json.Unmarshal(response.Data, &user); err != nil {
+		t.Fatalf("decode user: %v", err)
+	}
+	if !user.EmailVerified || user.Email != email {
+		t.Errorf("user = %+v, want %s verified", user, email)
+	}
+
+	mustErrorCode(t, request(t, router, http.MethodPost, "/api/v1/auth/verify-email", "", tokenBody(token)), "TOKEN_USED")
+}
+
+func TestPasswordResetTokenRoundTrip(t *testing.T) {
+	router := setupTestDB(t)
+	fileMailer := captureMail(t)
+
+	email, accessToken := registerUser(t, router)
+	subject := mailer.PasswordResetEmail("", "", "").Subject
+	forgot := func(address string) {
+		t.Helper()
+		decodeResponse(t, request(t, router, http.MethodPost, "/api/v1/auth/forgot-password", "", fmt.Sprintf(`{"email":%q}`, address)), http.StatusOK)
+	}
+	resetBody := func(token, password string) string {
+		return fmt.Sprintf(`{"token":%q,"password":%q}`, token, password)
+	}
+
+	// Unknown addresses get the same response and no email
+	unknown := "nobody_" + uuid.NewString() + "@example.com"
+	forgot(unknown)
+	if sent := fileMailer.Sent(unknown); len(sent) != 0 {
+		t.Errorf("sent %d emails to an unknown address", len(sent))
+	}
+
+	forgot(email)
+	expired := lastEmailToken(t, fileMailer, email, subject, "/reset-password")
+	expireToken(t, expired)
+	mustErrorCode(t, request(t, router, http.MethodPost, "/api/v1/auth/reset-password", "", resetBody(expired, "second-password")), "TOKEN_EXPIRED")
+
+	forgot(email)
+	token := lastEmailToken(t, fileMailer, email, subject, "/reset-password")
+
+	// A verification token cannot reset the password
+	verification := lastEmailToken(t, fileMailer, email, mailer.VerificationEmail("", "", "").Subject, "/verify-email")
+	mustErrorCode(t, request(t, router, http.MethodPost, "/api/v1/auth/reset-password", "", resetBody(verification, "second-password")), "INVALID_TOKEN")
+
+	decodeResponse(t, request(t, router, http.MethodPost, "/api/v1/auth/reset-password", "", resetBody(token, "second-password")), http.StatusOK)
+	mustErrorCode(t, request(t, router, http.MethodPost, "/api/v1/auth/reset-password", "", resetBody(token, "third-password")), "TOKEN_USED")
+
+	// Existing sessions are signed out and only the new password works
+	mustStatus(t, request(t, router, http.MethodGet, "/api/v1/auth/profile", accessToken, ""), http.StatusUnauthorized)
+	login := func(password string) *httptest.ResponseRecorder {
+		return request(t, router, http.MethodPost, "/api/v1/auth/login", "", fmt.Sprintf(`{"email":%q,"password":%q}`, email, password))
+	}
+	mustStatus(t, login("first-password"), http.StatusUnauthorized)
+	response := decodeResponse(t, login("second-password"), http.StatusOK)
+	var auth models.AuthResponse
+	if err := json.Unmarshal(response.Data, &auth); err != nil {
+		t.Fatalf("decode auth response: %v", err)
+	}
+	// Receiving the reset email proves the user owns the address
+	if !auth.User.EmailVerified {
+		t.Error("resetting the password did not verify the email address")
+	}
+}
+
+// socialLoginSession creates an account without a password and a session for it started
+// by a social login at signedInAt, and returns the user and the session's access token
+func socialLoginSession(t *testing.T, signedInAt time.Time) (models.User, string) {
+	t.Helper()
+
+	suffix := strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+	user := models.User{Username: "social_" + suffix, Email: "social_" + suffix + "@example.com"}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	token, claims, err := utils.GenerateJWTWithClaims(&user)
+	if err != nil {
+		t.Fatalf("generate token: %v", err)
+	}
+	session := models.UserSession{
+		UserID:      user.ID,
+		TokenHash:   middleware.SessionTokenHash(claims),
+		LoginMethod: models.LoginMethodOAuth,
+		ExpiresAt:   claims.ExpiresAt.Time,
+		CreatedAt:   signedInAt,
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	return user, token
+}
+
+func TestPasswordlessAccountSetsFirstPasswordAfterRecentSignIn(t *testing.T) {
+	router := setupTestDB(t)
+	body := `{"new_password":"first-password"}`
+
+	// A session signed in long ago has to sign in again first
+	_, staleToken := socialLoginSession(t, time.Now().Add(-time.Hour))
+	recorder := request(t, router, http.MethodPut, "/api/v1/auth/password", staleToken, body)
+	response := decodeResponse(t, recorder, http.StatusUnauthorized)
+	var apiError models.ErrorResponse
+	if err := json.Unmarshal(response.Error, &apiError); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if apiError.Code != "REAUTHENTICATION_REQUIRED" {
+		t.Errorf("error code = %q, want REAUTHENTICATION_REQUIRED", apiError.Code)
+	}
+
+	user, token := socialLoginSession(t, time.Now())
+	response = decodeResponse(t, request(t, router, http.MethodPut, "/api/v1/auth/password", token, body), http.StatusOK)
+	var auth models.AuthResponse
+	if err := json.Unmarshal(response.Data, &auth); err != nil {
+		t.Fatalf("decode auth response: %v", err)
+	}
+	if !auth.User.HasPassword {
+		t.Error("has_password is false after setting a password")
+	}
+
+	// From now on the password is required
+	recorder = request(t, router, http.MethodPut, "/api/v1/auth/password", auth.AccessToken, `{"new_password":"second-password"}`)
+	mustStatus(t, recorder, http.StatusUnauthorized)
+	login := fmt.Sprintf(`{"email":%q,"password":"first-password"}`, user.Email)
+	decodeResponse(t, request(t, router, http.MethodPost, "/api/v1/auth/login", "", login), http.StatusOK)
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"map-memories-api/database"
+	"map-memories-api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CategoryController struct{}
+
+// GetCategories godoc
+// @Summary Get location categories
+// @Description Get the point-of-interest category taxonomy with icons
+// @Tags Locations
+// @Produce json
+// @Success 200 {object} models.APIResponse{data=[]models.CategoryResponse}
+// @Failure 500 {object} models.APIResponse
+// @Router /categories [get]
+func (cc *CategoryController) GetCategories(c *gin.Context) {
+	var categories []models.Category
+	if err := database.DB.Where("parent_id IS NULL").
+		Preload("Children", func(db *gorm.DB) *gorm.DB {
+			return db.Order("display_order ASC, name ASC")
+		}).
+		Order("display_order ASC, name ASC").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to fetch categories",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	categoryResponses := make([]models.CategoryResponse, len(categories))
+	for i, category := range categories {
+		categoryResponses[i] = category.ToResponse()
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Categories retrieved successfully",
+		categoryResponses,
+	))
+}
+
+// findCategoriesBySlugs loads the categories for the given slugs and reports unknown slugs
+func findCategoriesBySlugs(slugs []string) ([]models.Category, []string, error) {
+	normalized := normalizeCategorySlugs(slugs)
+	if len(normalized) == 0 {
+		return []models.Category{}, nil, nil
+	}
+
+	var categories []models.Category
+	if err := database.DB.Where("slug IN ?", normalized).Find(&categories).Error; err != nil {
+		return nil, nil, err
+	}
+
+	found := make(map[string]bool, len(categories))
+	for _, category := range categories {
+		found[category.Slug] = true
+	}
+
+	var unknown []string
+	for _, slug := range normalized {
+		if !found[slug] {
+			unknown = append(unknown, slug)
+		}
+	}
+
+	return categories, unknown, nil
+}
+
+// categoryFilterIDs resolves a comma-separated list of category slugs to category IDs,
+// including the children of top-level categories so "nature" also matches "beach"
+func categoryFilterIDs(param string) ([]uint, error) {
+	slugs := normalizeCategorySlugs(strings.Split(param, ","))
+	if len(slugs) == 0 {
+		return nil, nil
+	}
+
+	var ids []uint
+	err := database.DB.Model(&models.Category{}).
+		Where("slug IN ?", slugs).
+		Or("parent_id IN (?)", database.DB.Model(&models.Category{}).Select("id").Where("slug IN ?", slugs)).
+		Pluck("id", &ids).Error
+
+	return ids, err
+}
+
+// locationIDsInCategories returns a subquery selecting location IDs tagged with any of the categories
+func locationIDsInCategories(categoryIDs []uint) *gorm.DB {
+	return database.DB.Table("mm_location_categories").
+		Select("location_id").
+		Where("category_id IN ?", categoryIDs)
+}
+
+// loadLocationCategories fills the Categories of locations that were loaded without preloading
+func loadLocationCategories(locations []models.Location) error {
+	if len(locations) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(locations))
+	for i, location := range locations {
+		ids[i] = location.ID
+	}
+
+	var withCategories []models.Location
+	if err := database.DB.Preload("Categories").Select("id").Find(&withCategories, ids).Error; err != nil {
+		return err
+	}
+
+	categoriesByLocation := make(map[uint][]models.Category, len(withCategories))
+	for _, location := range withCategories {
+		categoriesByLocation[location.ID] = location.Categories
+	}
+	for i := range locations {
+		locations[i].Categories = categoriesByLocation[locations[i].ID]
+	}
+
+	return nil
+}
+
+// normalizeCategorySlugs trims, lowercases and de-duplicates category slugs
+func normalizeCategorySlugs(slugs []string) []string {
+	seen := make(map[string]bool, len(slugs))
+	normalized := make([]string, 0, len(slugs))
+	for _, slug := range slugs {
+		slug = strings.ToLower(strings.TrimSpace(slug))
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		normalized = append(normalized, slug)
+	}
+	return normalized
+}
@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"map-memories-api/database"
+	"map-memories-api/jobs"
+	"map-memories-api/middleware"
+	"map-memories-api/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type JobController struct{}
+
+// GetJobs godoc
+// @Summary List background jobs (Admin only)
+// @Description List jobs, newest first, optionally filtered by status and type
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Status (queued, running, succeeded, dead)"
+// @Param type query string false "Job type, e.g. video.process"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 20, max: 100)"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.JobResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/jobs [get]
+func (jc *JobController) GetJobs(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	query := database.DB.Model(&models.Job{})
+	if status := c.Query("status"); status != "" {
+		switch status {
+		case models.JobQueued, models.JobRunning, models.JobSucceeded, models.JobDead:
+			query = query.Where("status = ?", status)
+		default:
+			c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+				"Invalid job status",
+				"INVALID_STATUS",
+				gin.H{"allowed": []string{models.JobQueued, models.JobRunning, models.JobSucceeded, models.JobDead}},
+			))
+			return
+		}
+	}
+	if jobType := c.Query("type"); jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to fetch jobs",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	var jobList []models.Job
+	if err := query.Order("created_at DESC, id DESC").
+		Limit(limit).Offset((page - 1) * limit).Find(&jobList).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to fetch jobs",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	responses := make([]models.JobResponse, len(jobList))
+	for i := range jobList {
+		responses[i] = jobList[i].ToResponse()
+	}
+
+	c.JSON(http.StatusOK, models.PaginatedSuccessResponse(
+		"Jobs retrieved successfully",
+		responses,
+		models.CalculatePagination(page, limit, total),
+	))
+}
+
+// GetJobStats godoc
+// @Summary Count background jobs (Admin only)
+// @Description Count the jobs of every type by status
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse{data=[]models.JobStats}
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/jobs/stats [get]
+func (jc *JobController) GetJobStats(c *gin.Context) {
+	var rows []struct {
+		Type   string
+		Status string
+		Count  int64
+	}
+	if err := database.DB.Model(&models.Job{}).
+		Select("type, status, COUNT(*) AS count").
+		Group("type, status").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to count jobs",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	statsByType := make(map[string]*models.JobStats)
+	for _, row := range rows {
+		stats, ok := statsByType[row.Type]
+		if !ok {
+			stats = &models.JobStats{Type: row.Type}
+			statsByType[row.Type] = stats
+		}
+		switch row.Status {
+		case models.JobQueued:
+			stats.Queued = row.Count
+		case models.JobRunning:
+			stats.Running = row.Count
+		case models.JobSucceeded:
+			stats.Succeeded = row.Count
+		case models.JobDead:
+			stats.Dead = row.Count
+		}
+	}
+
+	stats := make([]models.JobStats, 0, len(statsByType))
+	for _, s := range statsByType {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Type < stats[j].Type })
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Job statistics retrieved successfully",
+		stats,
+	))
+}
+
+// GetJob godoc
+// @Summary Get a background job (Admin only)
+// @Description Get a job with its payload and last error
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param uuid path string true "Job UUID"
+// @Success 200 {object} models.APIResponse{data=models.JobResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/jobs/{uuid} [get]
+func (jc *JobController) GetJob(c *gin.Context) {
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Job retrieved successfully",
+		job.ToResponse(),
+	))
+}
+
+// RetryJob godoc
+// @Summary Retry a background job (Admin only)
+// @Description Queue a dead job again with a fresh set of attempts, or run a job that waits for its retry backoff right away
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param uuid path string true "Job UUID"
+// @Success 200 {object} models.APIResponse{data=models.JobResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/jobs/{uuid}/retry [post]
+func (jc *JobController) RetryJob(c *gin.Context) {
+	job, ok := findJob(c)
+	if !ok {
+		return
+	}
+
+	if err := jobs.Retry(database.DB, job); err != nil {
+		if errors.Is(err, jobs.ErrNotRetryable) {
+			c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+				"Only queued and dead jobs can be retried",
+				"JOB_NOT_RETRYABLE",
+				gin.H{"status": job.Status},
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to retry job",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	adminID, _ := middleware.GetCurrentUserID(c)
+	log.Printf("Admin %d retried job %s (%s)", adminID, job.UUID, job.Type)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Job queued",
+		job.ToResponse(),
+	))
+}
+
+// findJob loads the job named by the uuid path parameter, writing the error response
+// when there is none
+func findJob(c *gin.Context) (*models.Job, bool) {
+	jobUUID, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid UUID format",
+			"INVALID_UUID",
+			nil,
+		))
+		return nil, false
+	}
+
+	var job models.Job
+	if err := database.DB.Where("uuid = ?", jobUUID).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"Job not found",
+				"JOB_NOT_FOUND",
+				nil,
+			))
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return nil, false
+	}
+	return &job, true
+}
@@ -0,0 +1,24 @@
+package controllers
+
+import (
+	"net/http"
+
+	"map-memories-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WellKnownController struct{}
+
+// GetJWKS godoc
+// @Summary JSON Web Key Set
+// @Description Public keys for verifying access tokens, selected by the token's kid header. Empty when tokens are signed with a shared secret.
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} utils.JWKSet
+// @Router /.well-known/jwks.json [get]
+func (wc *WellKnownController) GetJWKS(c *gin.Context) {
+	// Verifiers may cache the set; rotated keys stay listed until their tokens expire
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, utils.JWKS())
+}
@@ -1,11 +1,14 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 
 	"map-memories-api/database"
+	"map-memories-api/geocoding"
 	"map-memories-api/middleware"
 	"map-memories-api/models"
 	"map-memories-api/utils"
@@ -13,6 +16,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type LocationController struct{}
@@ -31,7 +35,7 @@ type LocationController struct{}
 // @Failure 500 {object} models.APIResponse
 // @Router /locations [post]
 func (lc *LocationController) CreateLocation(c *gin.Context) {
-	_, exists := middleware.GetCurrentUserID(c)
+	userID, exists := middleware.GetCurrentUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
 			"Authentication required",
@@ -56,6 +60,35 @@ func (lc *LocationController) CreateLocation(c *gin.Context) {
 		return
 	}
 
+	// Validate boundary
+	var boundary models.GeoJSON
+	if len(req.Boundary) > 0 {
+		normalized, ok := validateBoundary(c, req.Boundary)
+		if !ok {
+			return
+		}
+		boundary = normalized
+	}
+
+	// Resolve categories
+	categories, unknown, err := findCategoriesBySlugs(req.Categories)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+	if len(unknown) > 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Unknown categories",
+			"UNKNOWN_CATEGORY",
+			map[string]interface{}{"categories": unknown},
+		))
+		return
+	}
+
 	// Create location
 	location := models.Location{
 		Name:        req.Name,
@@ -65,8 +98,14 @@ func (lc *LocationController) CreateLocation(c *gin.Context) {
 		Address:     req.Address,
 		Country:     req.Country,
 		City:        req.City,
+		Boundary:    boundary,
+		CreatedBy:   &userID,
+		Categories:  categories,
 	}
 
+	// Resolve city and ISO country code from coordinates (best effort)
+	geocoding.FillLocation(&location, false)
+
 	if err := database.DB.Create(&location).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
 			"Failed to create location",
@@ -92,6 +131,7 @@ func (lc *LocationController) CreateLocation(c *gin.Context) {
 // @Param search query string false "Search in location name and description"
 // @Param country query string false "Filter by country"
 // @Param city query string false "Filter by city"
+// @Param category query string false "Filter by category slugs (comma-separated, parent categories include their children)"
 // @Success 200 {object} models.PaginatedResponse{data=[]models.LocationResponse}
 // @Failure 500 {object} models.APIResponse
 // @Router /locations [get]
@@ -114,20 +154,33 @@ func (lc *LocationController) GetLocations(c *gin.Context) {
 	}
 
 	if country := c.Query("country"); country != "" {
-		query = query.Where("country ILIKE ?", "%"+country+"%")
+		query = query.Where("country ILIKE ? OR country = ?", "%"+country+"%", geocoding.NormalizeCountry(country))
 	}
 
 	if city := c.Query("city"); city != "" {
 		query = query.Where("city ILIKE ?", "%"+city+"%")
 	}
 
+	if category := c.Query("category"); category != "" {
+		categoryIDs, err := categoryFilterIDs(category)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+				"Failed to resolve categories",
+				"INTERNAL_ERROR",
+				err.Error(),
+			))
+			return
+		}
+		query = query.Where("id IN (?)", locationIDsInCategories(categoryIDs))
+	}
+
 	// Get total count
 	var total int64
 	query.Count(&total)
 
 	// Get locations
 	var locations []models.Location
-	if err := query.Limit(limit).Offset(offset).Find(&locations).Error; err != nil {
+	if err := query.Preload("Categories").Limit(limit).Offset(offset).Find(&locations).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
 			"Failed to fetch locations",
 			"INTERNAL_ERROR",
@@ -184,7 +237,7 @@ func (lc *LocationController) GetLocation(c *gin.Context) {
 	}
 
 	var location models.Location
-	if err := database.DB.Where("uuid = ?", locationUUID).First(&location).Error; err != nil {
+	if err := database.DB.Preload("Categories").Where("uuid = ?", locationUUID).First(&location).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
 				"Location not found",
@@ -216,7 +269,7 @@ func (lc *LocationController) GetLocation(c *gin.Context) {
 
 // UpdateLocation godoc
 // @Summary Update location
-// @Description Update a location's details
+// @Description Update a location's details (only by its creator or an admin; other users should submit a suggestion)
 // @Tags Locations
 // @Accept json
 // @Produce json
@@ -226,11 +279,12 @@ func (lc *LocationController) GetLocation(c *gin.Context) {
 // @Success 200 {object} models.APIResponse{data=models.LocationResponse}
 // @Failure 400 {object} models.APIResponse
 // @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 404 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /locations/{uuid} [put]
 func (lc *LocationController) UpdateLocation(c *gin.Context) {
-	_, exists := middleware.GetCurrentUserID(c)
+	userID, exists := middleware.GetCurrentUserID(c)
 	if !exists {
 		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
 			"Authentication required",
@@ -275,12 +329,31 @@ func (lc *LocationController) UpdateLocation(c *gin.Context) {
 		return
 	}
 
-	// Update fields
-	if req.Name != "" {
-		location.Name = req.Name
+	// Check edit permission
+	if !canEditLocation(c, &location, userID) {
+		c.JSON(http.StatusForbidden, models.ErrorResponseWithCode(
+			"Access denied: Only the creator or an admin can edit this location. Submit a suggestion instead",
+			"FORBIDDEN",
+			map[string]interface{}{"suggestion_url": "/api/v1/locations/" + location.UUID.String() + "/suggestions"},
+		))
+		return
 	}
-	if req.Description != "" {
-		location.Description = req.Description
+
+	// Validate coordinates
+	changes := locationChanges{
+		Name:          req.Name,
+		Description:   req.Description,
+		Address:       req.Address,
+		Country:       req.Country,
+		City:          req.City,
+		ClearBoundary: req.ClearBoundary,
+	}
+	if len(req.Boundary) > 0 {
+		normalized, ok := validateBoundary(c, req.Boundary)
+		if !ok {
+			return
+		}
+		changes.Boundary = normalized
 	}
 	if req.Latitude != 0 {
 		if !utils.IsValidLatitude(req.Latitude) {
@@ -291,7 +364,7 @@ func (lc *LocationController) UpdateLocation(c *gin.Context) {
 			))
 			return
 		}
-		location.Latitude = req.Latitude
+		changes.Latitude = &req.Latitude
 	}
 	if req.Longitude != 0 {
 		if !utils.IsValidLongitude(req.Longitude) {
@@ -302,20 +375,42 @@ func (lc *LocationController) UpdateLocation(c *gin.Context) {
 			))
 			return
 		}
-		location.Longitude = req.Longitude
-	}
-	if req.Address != "" {
-		location.Address = req.Address
-	}
-	if req.Country != "" {
-		location.Country = req.Country
-	}
-	if req.City != "" {
-		location.City = req.City
+		changes.Longitude = &req.Longitude
+	}
+
+	// Resolve categories (nil leaves them unchanged)
+	var categories []models.Category
+	if req.Categories != nil {
+		var unknown []string
+		categories, unknown, err = findCategoriesBySlugs(req.Categories)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+				"Database error",
+				"INTERNAL_ERROR",
+				nil,
+			))
+			return
+		}
+		if len(unknown) > 0 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+				"Unknown categories",
+				"UNKNOWN_CATEGORY",
+				map[string]interface{}{"categories": unknown},
+			))
+			return
+		}
 	}
 
 	// Save changes
-	if err := database.DB.Save(&location).Error; err != nil {
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := applyLocationChanges(tx, &location, changes, userID, nil); err != nil {
+			return err
+		}
+		if req.Categories != nil {
+			return tx.Model(&location).Association("Categories").Replace(categories)
+		}
+		return nil
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
 			"Failed to update location",
 			"INTERNAL_ERROR",
@@ -324,6 +419,9 @@ func (lc *LocationController) UpdateLocation(c *gin.Context) {
 		return
 	}
 
+	// Load categories
+	database.DB.Model(&location).Association("Categories").Find(&location.Categories)
+
 	c.JSON(http.StatusOK, models.SuccessResponse(
 		"Location updated successfully",
 		location.ToResponse(),
@@ -405,13 +503,14 @@ func (lc *LocationController) DeleteLocation(c *gin.Context) {
 
 // SearchNearbyLocations godoc
 // @Summary Search locations near coordinates
-// @Description Find locations within a specified radius from given coordinates
+// @Description Find locations within a specified radius from given coordinates. Area locations are measured to their boundary (0 when the point is inside).
 // @Tags Locations
 // @Produce json
 // @Param latitude query number true "Latitude" minimum(-90) maximum(90)
 // @Param longitude query number true "Longitude" minimum(-180) maximum(180)
 // @Param radius query number false "Radius in kilometers (default: 10, max: 100)" minimum(0) maximum(100)
 // @Param limit query int false "Maximum number of results (default: 20, max: 100)" minimum(1) maximum(100)
+// @Param category query string false "Filter by category slugs (comma-separated, parent categories include their children)"
 // @Success 200 {object} models.APIResponse{data=[]models.LocationResponse}
 // @Failure 400 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
@@ -472,26 +571,53 @@ func (lc *LocationController) SearchNearbyLocations(c *gin.Context) {
 		return
 	}
 
-	// Use PostGIS functions for geospatial search
-	// ST_DWithin uses meters, so convert km to meters
+	// Use PostGIS geography functions for geospatial search; they work in meters,
+	// so convert km to meters. Area locations are measured to their boundary.
 	radiusMeters := radius * 1000
 
-	var locations []models.Location
+	// Optional category filter
+	categoryClause := ""
+	var categoryIDs []uint
+	if category := c.Query("category"); category != "" {
+		categoryIDs, err = categoryFilterIDs(category)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+				"Failed to resolve categories",
+				"INTERNAL_ERROR",
+				err.Error(),
+			))
+			return
+		}
+		if len(categoryIDs) == 0 {
+			categoryIDs = []uint{0}
+		}
+		categoryClause = "AND id IN (SELECT location_id FROM mm_location_categories WHERE category_id IN ?)"
+	}
+
+	var results []locationWithDistance
 	query := `
-		SELECT * FROM mm_locations 
+		SELECT *, ST_Distance(
+			(` + database.LocationGeometrySQL + `)::geography,
+			ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography
+		) / 1000 AS distance_km
+		FROM mm_locations
 		WHERE ST_DWithin(
-			ST_SetSRID(ST_MakePoint(longitude, latitude), 4326),
-			ST_SetSRID(ST_MakePoint(?, ?), 4326),
+			(` + database.LocationGeometrySQL + `)::geography,
+			ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography,
 			?
 		)
-		ORDER BY ST_Distance(
-			ST_SetSRID(ST_MakePoint(longitude, latitude), 4326),
-			ST_SetSRID(ST_MakePoint(?, ?), 4326)
-		)
+		` + categoryClause + `
+		ORDER BY distance_km
 		LIMIT ?
 	`
 
-	if err := database.DB.Raw(query, longitude, latitude, radiusMeters, longitude, latitude, limit).Scan(&locations).Error; err != nil {
+	args := []interface{}{longitude, latitude, longitude, latitude, radiusMeters}
+	if categoryClause != "" {
+		args = append(args, categoryIDs)
+	}
+	args = append(args, limit)
+
+	if err := database.DB.Raw(query, args...).Scan(&results).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
 			"Failed to search nearby locations",
 			"INTERNAL_ERROR",
@@ -500,10 +626,27 @@ func (lc *LocationController) SearchNearbyLocations(c *gin.Context) {
 		return
 	}
 
+	locations := make([]models.Location, len(results))
+	for i, result := range results {
+		locations[i] = result.Location
+	}
+
+	// Load categories
+	if err := loadLocationCategories(locations); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to load location categories",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
 	// Convert to response format with memory count and distance
 	locationResponses := make([]models.LocationResponse, len(locations))
 	for i, location := range locations {
 		response := location.ToResponse()
+		distanceKm := results[i].DistanceKm
+		response.DistanceKm = &distanceKm
 		
 		// Count memories for this location
 		var memoryCount int64
@@ -519,6 +662,59 @@ func (lc *LocationController) SearchNearbyLocations(c *gin.Context) {
 	))
 }
 
+// GetContainingLocations godoc
+// @Summary Find areas containing a point
+// @Description Find area locations (regions, parks, cities) whose boundary contains the given coordinates, smallest first
+// @Tags Locations
+// @Produce json
+// @Param latitude query number true "Latitude" minimum(-90) maximum(90)
+// @Param longitude query number true "Longitude" minimum(-180) maximum(180)
+// @Success 200 {object} models.APIResponse{data=[]models.LocationResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /locations/containing [get]
+func (lc *LocationController) GetContainingLocations(c *gin.Context) {
+	latitude, err := strconv.ParseFloat(c.Query("latitude"), 64)
+	if err != nil || !utils.IsValidLatitude(latitude) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid latitude",
+			"INVALID_LATITUDE",
+			nil,
+		))
+		return
+	}
+
+	longitude, err := strconv.ParseFloat(c.Query("longitude"), 64)
+	if err != nil || !utils.IsValidLongitude(longitude) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid longitude",
+			"INVALID_LONGITUDE",
+			nil,
+		))
+		return
+	}
+
+	locations, err := findContainingLocations(latitude, longitude)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to search containing locations",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	locationResponses := make([]models.LocationResponse, len(locations))
+	for i, location := range locations {
+		locationResponses[i] = location.ToResponse()
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		fmt.Sprintf("Found %d areas containing the point", len(locations)),
+		locationResponses,
+	))
+}
+
 // GetLocationMemories godoc
 // @Summary Get memories for a location
 // @Description Get all memories associated with a specific location
@@ -576,6 +772,10 @@ func (lc *LocationController) GetLocationMemories(c *gin.Context) {
 	query := database.DB.Preload("User").Preload("Location").Preload("Media").
 		Where("location_id = ?", location.ID)
 
+	// Private memories are only listed for their owner
+	viewer := middleware.GetViewer(c)
+	query = visibleMemories(query, viewer)
+
 	// Apply public filter
 	if isPublicStr := c.Query("is_public"); isPublicStr != "" {
 		if isPublic, err := strconv.ParseBool(isPublicStr); err == nil {
@@ -601,7 +801,7 @@ func (lc *LocationController) GetLocationMemories(c *gin.Context) {
 	// Convert to response format
 	memoryResponses := make([]models.MemoryResponse, len(memories))
 	for i, memory := range memories {
-		memoryResponses[i] = memory.ToResponse()
+		memoryResponses[i] = memory.ToResponseFor(viewer)
 	}
 
 	// Calculate pagination
@@ -612,4 +812,186 @@ func (lc *LocationController) GetLocationMemories(c *gin.Context) {
 		memoryResponses,
 		pagination,
 	))
-}
\ No newline at end of file
+}
+
+// locationWithDistance is a location row with the distance computed by a geospatial query
+type locationWithDistance struct {
+	models.Location
+	DistanceKm float64 `gorm:"column:distance_km"`
+}
+
+// findContainingLocations returns area locations whose boundary covers the point, smallest first
+func findContainingLocations(latitude, longitude float64) ([]models.Location, error) {
+	var locations []models.Location
+	err := database.DB.Preload("Categories").
+		Where("boundary IS NOT NULL").
+		Where("ST_Covers("+database.BoundaryGeometrySQL+", ST_SetSRID(ST_MakePoint(?, ?), 4326))", longitude, latitude).
+		Order("ST_Area((" + database.BoundaryGeometrySQL + ")::geography) ASC").
+		Find(&locations).Error
+	return locations, err
+}
+
+// locationChanges holds the fields to change on a location; empty values are left unchanged
+type locationChanges struct {
+	Name          string
+	Description   string
+	Latitude      *float64
+	Longitude     *float64
+	Address       string
+	Country       string
+	City          string
+	Boundary      models.GeoJSON
+	ClearBoundary bool
+}
+
+// canEditLocation reports whether the user may edit the location directly
+func canEditLocation(c *gin.Context, location *models.Location, userID uint) bool {
+	return location.IsOwnedBy(userID) || middleware.IsAdmin(c)
+}
+
+// canReviewLocation reports whether the user may approve or reject suggestions for the location
+func canReviewLocation(c *gin.Context, location *models.Location, userID uint) bool {
+	return location.IsOwnedBy(userID) || middleware.IsModerator(c)
+}
+
+// validateBoundary checks the structure of a GeoJSON boundary and that PostGIS considers
+// it a valid geometry, writing the error response when it is not
+func validateBoundary(c *gin.Context, raw []byte) (models.GeoJSON, bool) {
+	normalized, err := utils.NormalizeBoundary(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid boundary: "+err.Error(),
+			"INVALID_BOUNDARY",
+			nil,
+		))
+		return nil, false
+	}
+
+	reason, err := database.BoundaryInvalidReason(normalized)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to validate boundary",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return nil, false
+	}
+	if reason != "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid boundary: "+reason,
+			"INVALID_BOUNDARY",
+			gin.H{"reason": reason},
+		))
+		return nil, false
+	}
+
+	return normalized, true
+}
+
+// applyLocationChanges saves the changes to the location, re-resolving city, country and
+// address when it moved and recording coordinate changes in the audit log. The location is
+// reloaded and locked first, and only the changed columns are written, so edits made
+// since the caller read it are kept.
+func applyLocationChanges(tx *gorm.DB, location *models.Location, changes locationChanges, actorID uint, suggestionID *uint) error {
+	var current models.Location
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, location.ID).Error; err != nil {
+		return err
+	}
+	current.Categories = location.Categories
+	*location = current
+
+	oldLatitude, oldLongitude := location.Latitude, location.Longitude
+	updates := map[string]interface{}{}
+
+	if changes.Name != "" {
+		location.Name = changes.Name
+		updates["name"] = location.Name
+	}
+	if changes.Description != "" {
+		location.Description = changes.Description
+		updates["description"] = location.Description
+	}
+	if changes.Latitude != nil {
+		location.Latitude = *changes.Latitude
+		updates["latitude"] = location.Latitude
+	}
+	if changes.Longitude != nil {
+		location.Longitude = *changes.Longitude
+		updates["longitude"] = location.Longitude
+	}
+	if changes.Address != "" {
+		location.Address = changes.Address
+		updates["address"] = location.Address
+	}
+	if changes.Country != "" {
+		location.Country = changes.Country
+		updates["country"] = location.Country
+	}
+	if changes.City != "" {
+		location.City = changes.City
+		updates["city"] = location.City
+	}
+	if changes.ClearBoundary {
+		location.Boundary = nil
+		updates["boundary"] = gorm.Expr("NULL")
+	}
+	if len(changes.Boundary) > 0 {
+		location.Boundary = changes.Boundary
+		updates["boundary"] = location.Boundary
+	}
+
+	// Re-resolve city, country and address when the location moved, keeping values
+	// explicitly provided. The stored values are only replaced when the new coordinates
+	// resolve; without a result (or with geocoding disabled) they are kept.
+	moved := location.Latitude != oldLatitude || location.Longitude != oldLongitude
+	geocoded := false
+	if moved {
+		resolved := *location
+		if changes.City == "" {
+			resolved.City = ""
+		}
+		if changes.Address == "" {
+			resolved.Address = ""
+		}
+		err := geocoding.FillLocation(&resolved, false)
+		switch {
+		case err == nil:
+			geocoded = true
+			location.City = resolved.City
+			location.Country = resolved.Country
+			location.Address = resolved.Address
+			updates["city"] = location.City
+			updates["country"] = location.Country
+			updates["address"] = location.Address
+		case !errors.Is(err, geocoding.ErrNoResult):
+			log.Printf("Failed to reverse geocode location %d: %v", location.ID, err)
+		}
+	}
+	if !geocoded && changes.Country != "" {
+		location.Country = geocoding.NormalizeCountry(location.Country)
+		updates["country"] = location.Country
+	}
+
+	if len(updates) > 0 {
+		if err := tx.Model(location).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	if moved {
+		auditLog := models.LocationAuditLog{
+			LocationID:   location.ID,
+			UserID:       actorID,
+			SuggestionID: suggestionID,
+			OldLatitude:  oldLatitude,
+			OldLongitude: oldLongitude,
+			NewLatitude:  location.Latitude,
+			NewLongitude: location.Longitude,
+		}
+		if err := tx.Create(&auditLog).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,472 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"map-memories-api/database"
+	"map-memories-api/middleware"
+	"map-memories-api/models"
+	"map-memories-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// errSuggestionAlreadyReviewed is returned when another reviewer decided on a suggestion first
+var errSuggestionAlreadyReviewed = errors.New("suggestion has already been reviewed")
+
+// SuggestLocationEdit godoc
+// @Summary Suggest a location edit
+// @Description Propose a change to a location owned by another user; the owner or a moderator reviews it
+// @Tags Locations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param uuid path string true "Location UUID"
+// @Param suggestion body models.LocationSuggestionRequest true "Proposed changes"
+// @Success 201 {object} models.APIResponse{data=models.LocationSuggestionResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /locations/{uuid}/suggestions [post]
+func (lc *LocationController) SuggestLocationEdit(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	location, ok := findLocationByUUIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.LocationSuggestionRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	if req.IsEmpty() {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Suggestion must change at least one field",
+			"EMPTY_SUGGESTION",
+			nil,
+		))
+		return
+	}
+
+	if canEditLocation(c, location, userID) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"You can edit this location directly",
+			"CAN_EDIT_DIRECTLY",
+			nil,
+		))
+		return
+	}
+
+	suggestion := models.LocationEditSuggestion{
+		LocationID:  location.ID,
+		UserID:      userID,
+		Name:        req.Name,
+		Description: req.Description,
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
+		Address:     req.Address,
+		Country:     req.Country,
+		City:        req.City,
+		Reason:      req.Reason,
+		Status:      models.SuggestionStatusPending,
+	}
+
+	if err := database.DB.Create(&suggestion).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to create suggestion",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	database.DB.Preload("Location").Preload("User").First(&suggestion, suggestion.ID)
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		"Suggestion submitted successfully",
+		suggestion.ToResponse(),
+	))
+}
+
+// GetLocationSuggestions godoc
+// @Summary Get suggestions for a location
+// @Description Owners and moderators see all suggestions; other users see only their own
+// @Tags Locations
+// @Produce json
+// @Security BearerAuth
+// @Param uuid path string true "Location UUID"
+// @Param status query string false "Filter by status" Enums(pending, approved, rejected)
+// @Success 200 {object} models.APIResponse{data=[]models.LocationSuggestionResponse}
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /locations/{uuid}/suggestions [get]
+func (lc *LocationController) GetLocationSuggestions(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	location, ok := findLocationByUUIDParam(c)
+	if !ok {
+		return
+	}
+
+	query := database.DB.Preload("Location").Preload("User").Where("location_id = ?", location.ID)
+	if !canReviewLocation(c, location, userID) {
+		query = query.Where("user_id = ?", userID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var suggestions []models.LocationEditSuggestion
+	if err := query.Order("created_at DESC").Find(&suggestions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to fetch suggestions",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	responses := make([]models.LocationSuggestionResponse, len(suggestions))
+	for i, suggestion := range suggestions {
+		responses[i] = suggestion.ToResponse()
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Suggestions retrieved successfully",
+		responses,
+	))
+}
+
+// GetSuggestionQueue godoc
+// @Summary Get the suggestion review queue
+// @Description Moderators see suggestions for all locations; other users see suggestions for locations they created
+// @Tags Locations
+// @Produce json
+// @Security BearerAuth
+// @Param status query string false "Filter by status (default: pending)" Enums(pending, approved, rejected)
+// @Success 200 {object} models.APIResponse{data=[]models.LocationSuggestionResponse}
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /locations/suggestions [get]
+func (lc *LocationController) GetSuggestionQueue(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	query := database.DB.Preload("Location").Preload("User").
+		Where("status = ?", c.DefaultQuery("status", models.SuggestionStatusPending))
+	if !middleware.IsModerator(c) {
+		query = query.Where("location_id IN (?)",
+			database.DB.Model(&models.Location{}).Select("id").Where("created_by = ?", userID))
+	}
+
+	var suggestions []models.LocationEditSuggestion
+	if err := query.Order("created_at ASC").Limit(100).Find(&suggestions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to fetch suggestions",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	responses := make([]models.LocationSuggestionResponse, len(suggestions))
+	for i, suggestion := range suggestions {
+		responses[i] = suggestion.ToResponse()
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Suggestions retrieved successfully",
+		responses,
+	))
+}
+
+// ApproveSuggestion godoc
+// @Summary Approve a location suggestion
+// @Description Apply a pending suggestion to its location (location owner or moderator)
+// @Tags Locations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param uuid path string true "Suggestion UUID"
+// @Param review body models.SuggestionReviewRequest false "Review note"
+// @Success 200 {object} models.APIResponse{data=models.LocationResponse}
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /locations/suggestions/{uuid}/approve [post]
+func (lc *LocationController) ApproveSuggestion(c *gin.Context) {
+	lc.reviewSuggestion(c, models.SuggestionStatusApproved)
+}
+
+// RejectSuggestion godoc
+// @Summary Reject a location suggestion
+// @Description Reject a pending suggestion (location owner or moderator)
+// @Tags Locations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param uuid path string true "Suggestion UUID"
+// @Param review body models.SuggestionReviewRequest false "Review note"
+// @Success 200 {object} models.APIResponse{data=models.LocationSuggestionResponse}
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /locations/suggestions/{uuid}/reject [post]
+func (lc *LocationController) RejectSuggestion(c *gin.Context) {
+	lc.reviewSuggestion(c, models.SuggestionStatusRejected)
+}
+
+// reviewSuggestion approves or rejects a pending suggestion
+func (lc *LocationController) reviewSuggestion(c *gin.Context, status string) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	suggestionUUID, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid UUID format",
+			"INVALID_UUID",
+			nil,
+		))
+		return
+	}
+
+	// The review note is optional, so an empty body is allowed
+	var req models.SuggestionReviewRequest
+	if c.Request.ContentLength > 0 {
+		if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+			return
+		}
+	}
+
+	var suggestion models.LocationEditSuggestion
+	if err := database.DB.Preload("Location").Preload("User").
+		Where("uuid = ?", suggestionUUID).First(&suggestion).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"Suggestion not found",
+				"SUGGESTION_NOT_FOUND",
+				nil,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	if !canReviewLocation(c, &suggestion.Location, userID) {
+		c.JSON(http.StatusForbidden, models.ErrorResponseWithCode(
+			"Access denied: Only the location creator or a moderator can review suggestions",
+			"FORBIDDEN",
+			nil,
+		))
+		return
+	}
+
+	if suggestion.Status != models.SuggestionStatusPending {
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+			"Suggestion has already been reviewed",
+			"SUGGESTION_ALREADY_REVIEWED",
+			map[string]interface{}{"status": suggestion.Status},
+		))
+		return
+	}
+
+	now := time.Now()
+	location := suggestion.Location
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// Guard against two reviewers racing on the same suggestion
+		result := tx.Model(&models.LocationEditSuggestion{}).
+			Where("id = ? AND status = ?", suggestion.ID, models.SuggestionStatusPending).
+			Updates(map[string]interface{}{
+				"status":      status,
+				"reviewed_by": userID,
+				"reviewed_at": now,
+				"review_note": req.Note,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errSuggestionAlreadyReviewed
+		}
+
+		if status != models.SuggestionStatusApproved {
+			return nil
+		}
+
+		changes := locationChanges{
+			Name:        suggestion.Name,
+			Description: suggestion.Description,
+			Latitude:    suggestion.Latitude,
+			Longitude:   suggestion.Longitude,
+			Address:     suggestion.Address,
+			Country:     suggestion.Country,
+			City:        suggestion.City,
+		}
+		return applyLocationChanges(tx, &location, changes, userID, &suggestion.ID)
+	})
+	if err != nil {
+		switch err {
+		case errSuggestionAlreadyReviewed:
+			c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+				"Suggestion has already been reviewed",
+				"SUGGESTION_ALREADY_REVIEWED",
+				nil,
+			))
+			return
+		case gorm.ErrRecordNotFound:
+			// The location was deleted after the suggestion was made
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"Location not found",
+				"LOCATION_NOT_FOUND",
+				nil,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to review suggestion",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	if status == models.SuggestionStatusApproved {
+		c.JSON(http.StatusOK, models.SuccessResponse(
+			"Suggestion approved and applied",
+			location.ToResponse(),
+		))
+		return
+	}
+
+	suggestion.Status = status
+	suggestion.ReviewedBy = &userID
+	suggestion.ReviewedAt = &now
+	suggestion.ReviewNote = req.Note
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Suggestion rejected",
+		suggestion.ToResponse(),
+	))
+}
+
+// GetLocationHistory godoc
+// @Summary Get coordinate change history
+// @Description Get the audit trail of coordinate changes for a location
+// @Tags Locations
+// @Produce json
+// @Param uuid path string true "Location UUID"
+// @Success 200 {object} models.APIResponse{data=[]models.LocationAuditLogResponse}
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /locations/{uuid}/history [get]
+func (lc *LocationController) GetLocationHistory(c *gin.Context) {
+	location, ok := findLocationByUUIDParam(c)
+	if !ok {
+		return
+	}
+
+	var auditLogs []models.LocationAuditLog
+	if err := database.DB.Preload("User").Preload("Suggestion").Where("location_id = ?", location.ID).
+		Order("created_at DESC").Find(&auditLogs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to fetch location history",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	viewer := middleware.GetViewer(c)
+	responses := make([]models.LocationAuditLogResponse, len(auditLogs))
+	for i := range auditLogs {
+		responses[i] = auditLogs[i].ToResponse(viewer)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Location history retrieved successfully",
+		responses,
+	))
+}
+
+// findLocationByUUIDParam loads the location named by the :uuid path parameter,
+// writing the error response itself when it cannot
+func findLocationByUUIDParam(c *gin.Context) (*models.Location, bool) {
+	locationUUID, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid UUID format",
+			"INVALID_UUID",
+			nil,
+		))
+		return nil, false
+	}
+
+	var location models.Location
+	if err := database.DB.Where("uuid = ?", locationUUID).First(&location).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"Location not found",
+				"LOCATION_NOT_FOUND",
+				nil,
+			))
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return nil, false
+	}
+
+	return &location, true
+}
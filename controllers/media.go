@@ -1,12 +1,22 @@
 package controllers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 
+	"map-memories-api/blobs"
+	"map-memories-api/config"
 	"map-memories-api/database"
+	"map-memories-api/mediainfo"
 	"map-memories-api/middleware"
 	"map-memories-api/models"
+	"map-memories-api/quota"
+	"map-memories-api/storage"
 	"map-memories-api/utils"
 
 	"github.com/gin-gonic/gin"
@@ -16,6 +26,9 @@ import (
 
 type MediaController struct{}
 
+// multipartOverhead is the room left in upload requests for form fields and part headers
+const multipartOverhead = 1 << 20
+
 // UploadMedia godoc
 // @Summary Upload media file
 // @Description Upload an image or video file for a memory
@@ -26,11 +39,12 @@ type MediaController struct{}
 // @Param memory_id formData int true "Memory ID"
 // @Param display_order formData int false "Display order (default: 0)"
 // @Param file formData file true "Media file"
-// @Success 201 {object} models.APIResponse{data=models.MediaResponse}
+// @Success 201 {object} models.APIResponse{data=models.MediaUploadResponse}
 // @Failure 400 {object} models.APIResponse
 // @Failure 401 {object} models.APIResponse
 // @Failure 403 {object} models.APIResponse
 // @Failure 413 {object} models.APIResponse
+// @Failure 415 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /media/upload [post]
 func (mc *MediaController) UploadMedia(c *gin.Context) {
@@ -44,6 +58,9 @@ func (mc *MediaController) UploadMedia(c *gin.Context) {
 		return
 	}
 
+	// Cap the request body; the file itself is measured again while it is copied
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.AppConfig.Upload.MaxFileSizeInt+multipartOverhead)
+
 	// Parse form data
 	memoryIDStr := c.PostForm("memory_id")
 	displayOrderStr := c.DefaultPostForm("display_order", "0")
@@ -85,6 +102,15 @@ func (mc *MediaController) UploadMedia(c *gin.Context) {
 	// Get uploaded file
 	file, err := c.FormFile("file")
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponseWithCode(
+				"File size exceeds maximum allowed size",
+				"FILE_TOO_LARGE",
+				nil,
+			))
+			return
+		}
 		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
 			"No file uploaded",
 			"NO_FILE_UPLOADED",
@@ -93,43 +119,26 @@ func (mc *MediaController) UploadMedia(c *gin.Context) {
 		return
 	}
 
-	// Save file
-	fileInfo, err := utils.SaveUploadedFile(file)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
-			"Failed to save file: "+err.Error(),
-			"FILE_SAVE_ERROR",
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
 			nil,
 		))
 		return
 	}
 
-	// Create media record
-	media := models.Media{
-		MemoryID:         uint(memoryID),
-		Filename:         fileInfo.Filename,
-		OriginalFilename: fileInfo.OriginalFilename,
-		FilePath:         fileInfo.FilePath,
-		FileSize:         fileInfo.FileSize,
-		MimeType:         fileInfo.MimeType,
-		MediaType:        fileInfo.MediaType,
-		DisplayOrder:     displayOrder,
-	}
-
-	if err := database.DB.Create(&media).Error; err != nil {
-		// Clean up uploaded file if database save fails
-		utils.DeleteFile(fileInfo.FilePath)
-		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
-			"Failed to save media record",
-			"INTERNAL_ERROR",
-			err.Error(),
-		))
+	// Validate, store and record the file
+	media, err := storeMediaFile(c.Request.Context(), &user, memory.ID, displayOrder, file)
+	if err != nil {
+		respondUploadError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, models.SuccessResponse(
 		"Media uploaded successfully",
-		media.ToResponse(),
+		uploadResponse(userID, media),
 	))
 }
 
@@ -253,12 +262,20 @@ func (mc *MediaController) GetMediaFile(c *gin.Context) {
 
 // ServeMediaFile godoc
 // @Summary Serve media file
-// @Description Serve the actual media file for viewing/download
+// @Description Serve the actual media file for viewing/download, the same way for every storage backend. Supports single and multiple byte ranges (206, multipart/byteranges for several ranges), strong ETags derived from the content hash and conditional requests (If-None-Match, If-Modified-Since, If-Range). With STORAGE_REDIRECT_DOWNLOADS the response redirects to a short-lived signed URL when the backend supports them.
 // @Tags Media
 // @Produce application/octet-stream
 // @Param uuid path string true "Media UUID"
+// @Param download query bool false "Send as attachment instead of inline"
+// @Param Range header string false "Byte ranges, e.g. bytes=0-1023"
+// @Param If-None-Match header string false "ETag of a cached copy"
+// @Param If-Modified-Since header string false "Date of a cached copy"
 // @Success 200 {file} file "Media file"
+// @Success 206 {file} file "Requested byte ranges"
+// @Success 302 "Redirect to a signed object storage URL"
+// @Success 304 "Cached copy is up to date"
 // @Failure 404 {object} models.APIResponse
+// @Failure 416 "Requested range not satisfiable"
 // @Failure 500 {object} models.APIResponse
 // @Router /media/{uuid}/file [get]
 func (mc *MediaController) ServeMediaFile(c *gin.Context) {
@@ -293,23 +310,67 @@ func (mc *MediaController) ServeMediaFile(c *gin.Context) {
 		return
 	}
 
-	// Check if file exists
-	if !utils.FileExists(media.FilePath) {
-		c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
-			"Media file not found on disk",
-			"FILE_NOT_FOUND",
+	// The content of a media never changes, so hashed media can be validated without
+	// touching the storage
+	if media.Checksum != "" {
+		c.Header("ETag", mediaETag(&media, nil))
+		if notModified(c.Request, c.Writer.Header().Get("ETag"), media.CreatedAt) {
+			c.Header("Cache-Control", mediaCacheControl)
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	// Object storage can serve the file itself through a short-lived signed URL
+	if media.StorageKey != "" && config.AppConfig.Storage.RedirectDownloads {
+		signedURL, err := storage.Default.SignedURL(c.Request.Context(), media.StorageKey, config.AppConfig.Storage.SignedURLTTL)
+		if err == nil {
+			c.Redirect(http.StatusFound, signedURL)
+			return
+		}
+		if err != storage.ErrNotSupported {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+				"Failed to create media download link",
+				"INTERNAL_ERROR",
+				nil,
+			))
+			return
+		}
+	}
+
+	content, object, err := utils.OpenMediaContent(c.Request.Context(), &media)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"Media file not found in storage",
+				"FILE_NOT_FOUND",
+				nil,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to open media file",
+			"INTERNAL_ERROR",
 			nil,
 		))
 		return
 	}
+	defer content.Close()
 
-	// Set appropriate headers
-	c.Header("Content-Type", media.MimeType)
-	c.Header("Content-Disposition", "inline; filename=\""+media.OriginalFilename+"\"")
-	c.Header("Cache-Control", "public, max-age=31536000") // 1 year cache
+	disposition := "inline"
+	if download, _ := strconv.ParseBool(c.Query("download")); download {
+		disposition = "attachment"
+	}
 
-	// Serve file
-	c.File(media.FilePath)
+	// Set appropriate headers
+	c.Header("ETag", mediaETag(&media, object))
+	c.Header("Content-Type", mediainfo.Normalize(media.MimeType))
+	c.Header("Content-Disposition", contentDisposition(disposition, media.OriginalFilename))
+	c.Header("Cache-Control", mediaCacheControl)
+	c.Header("X-Content-Type-Options", "nosniff")
+
+	// ServeContent answers conditional and (multi-)range requests from the headers above
+	http.ServeContent(c.Writer, c.Request, "", lastModified(&media, object), content)
 }
 
 // UpdateMedia godoc
@@ -467,18 +528,6 @@ func (mc *MediaController) DeleteMedia(c *gin.Context) {
 		return
 	}
 
-	// Delete file from filesystem
-	if utils.FileExists(media.FilePath) {
-		if err := utils.DeleteFile(media.FilePath); err != nil {
-			c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
-				"Failed to delete media file",
-				"FILE_DELETE_ERROR",
-				err.Error(),
-			))
-			return
-		}
-	}
-
 	// Delete media record
 	if err := database.DB.Delete(&media).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
@@ -489,6 +538,12 @@ func (mc *MediaController) DeleteMedia(c *gin.Context) {
 		return
 	}
 
+	// The stored file goes with the last media row sharing it; a failure here only
+	// leaves the file behind
+	if err := blobs.ReleaseMedia(c.Request.Context(), &media); err != nil {
+		log.Printf("Failed to release file of media %d: %v", media.ID, err)
+	}
+
 	c.JSON(http.StatusOK, models.SuccessResponse(
 		"Media deleted successfully",
 		nil,
@@ -560,4 +615,135 @@ func (mc *MediaController) GetMemoryMedia(c *gin.Context) {
 		"Memory media retrieved successfully",
 		mediaResponses,
 	))
-}
\ No newline at end of file
+}
+// errSaveMediaRecord wraps database errors of recording a stored file
+var errSaveMediaRecord = errors.New("failed to save media record")
+
+// storeMediaFile validates and stores one uploaded file and records it as media of a
+// memory within the user's quota. Nothing is left behind when it fails.
+func storeMediaFile(ctx context.Context, user *models.User, memoryID uint, displayOrder int, file *multipart.FileHeader) (*models.Media, error) {
+	// Reject files over the quota before reading them; the final check happens when
+	// the media record is created
+	if err := quota.Check(database.DB, user, file.Size); err != nil {
+		return nil, err
+	}
+
+	upload, err := utils.PrepareUploadedFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer upload.Close()
+
+	fileInfo, _, err := blobs.Store(ctx, upload)
+	if err != nil {
+		return nil, err
+	}
+
+	media := newMedia(memoryID, displayOrder, fileInfo)
+	if err := createMediaWithinQuota(user, media, nil); err != nil {
+		// Drop the reference on the stored file if database save fails
+		blobs.ReleaseMedia(ctx, media)
+		var exceeded *quota.ExceededError
+		if errors.As(err, &exceeded) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", errSaveMediaRecord, err)
+	}
+	return media, nil
+}
+
+// newMedia builds the media record of a stored file
+func newMedia(memoryID uint, displayOrder int, fileInfo *utils.FileInfo) *models.Media {
+	media := &models.Media{
+		MemoryID:         memoryID,
+		Filename:         fileInfo.Filename,
+		OriginalFilename: fileInfo.OriginalFilename,
+		StorageKey:       fileInfo.StorageKey,
+		BlobID:           fileInfo.BlobID,
+		FileSize:         fileInfo.FileSize,
+		MimeType:         fileInfo.MimeType,
+		MediaType:        fileInfo.MediaType,
+		Checksum:         fileInfo.Checksum,
+		Width:            fileInfo.Width,
+		Height:           fileInfo.Height,
+		DisplayOrder:     displayOrder,
+	}
+	// Videos get their metadata and poster frame in the background (see videoproc)
+	if media.MediaType == "video" {
+		media.ProcessingStatus = models.ProcessingPending
+	}
+	return media
+}
+
+// uploadFailure maps an error of saving an uploaded file to a status and error body
+func uploadFailure(err error) (int, models.ErrorResponse) {
+	var exceeded *quota.ExceededError
+	switch {
+	case errors.Is(err, utils.ErrFileTooLarge):
+		return http.StatusRequestEntityTooLarge, models.ErrorResponse{
+			Code:    "FILE_TOO_LARGE",
+			Message: "File size exceeds maximum allowed size",
+			Details: err.Error(),
+		}
+	case errors.Is(err, utils.ErrUnsupportedFileType):
+		return http.StatusUnsupportedMediaType, models.ErrorResponse{
+			Code:    "UNSUPPORTED_FILE_TYPE",
+			Message: "File type is not allowed",
+			Details: err.Error(),
+		}
+	case errors.Is(err, utils.ErrInvalidMediaFile):
+		return http.StatusBadRequest, models.ErrorResponse{
+			Code:    "INVALID_FILE",
+			Message: "File is not a valid image or video",
+			Details: err.Error(),
+		}
+	case errors.As(err, &exceeded):
+		return http.StatusRequestEntityTooLarge, models.ErrorResponse{
+			Code:    "QUOTA_EXCEEDED",
+			Message: exceeded.Error(),
+			Details: exceeded.Details(),
+		}
+	case errors.Is(err, errSaveMediaRecord):
+		return http.StatusInternalServerError, models.ErrorResponse{
+			Code:    "INTERNAL_ERROR",
+			Message: "Failed to save media record",
+			Details: err.Error(),
+		}
+	default:
+		return http.StatusInternalServerError, models.ErrorResponse{
+			Code:    "FILE_SAVE_ERROR",
+			Message: "Failed to save file",
+			Details: err.Error(),
+		}
+	}
+}
+
+// respondUploadError writes the response for a media file that could not be saved
+func respondUploadError(c *gin.Context, err error) {
+	status, failure := uploadFailure(err)
+	c.JSON(status, models.ErrorResponseWithCode(failure.Message, failure.Code, failure.Details))
+}
+
+// uploadResponse builds the response for a new upload, including the user's earlier
+// uploads of the same file
+func uploadResponse(userID uint, media *models.Media) models.MediaUploadResponse {
+	response := models.MediaUploadResponse{MediaResponse: media.ToResponse()}
+	if media.Checksum == "" {
+		return response
+	}
+
+	var duplicates []models.MediaDuplicate
+	err := database.DB.Table("mm_media").
+		Select("mm_media.uuid, mm_memories.uuid AS memory_uuid, mm_memories.title AS memory_title, mm_media.created_at").
+		Joins("JOIN mm_memories ON mm_memories.id = mm_media.memory_id AND mm_memories.deleted_at IS NULL").
+		Where("mm_memories.user_id = ? AND mm_media.checksum = ? AND mm_media.id <> ?", userID, media.Checksum, media.ID).
+		Order("mm_media.created_at DESC").Limit(5).
+		Scan(&duplicates).Error
+	if err != nil {
+		log.Printf("Failed to look up duplicates of media %d: %v", media.ID, err)
+		return response
+	}
+
+	response.AlreadyUploaded = duplicates
+	return response
+}
@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/middleware"
+	"map-memories-api/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UploadMediaBatch godoc
+// @Summary Upload several media files
+// @Description Upload many images or videos for a memory in one request. Each file is saved on its own: failed files leave nothing behind and do not affect the others. Responds 201 when all files were saved, 207 when some failed and 400 when none was saved; the results follow the order of the files.
+// @Tags Media
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param memory_id formData int true "Memory ID"
+// @Param files formData file true "Media files (repeat the field for each file)"
+// @Param display_order formData []int false "Display order of each file, in the order of the files (default: position in the batch)" collectionFormat(multi)
+// @Success 201 {object} models.APIResponse{data=models.BatchUploadResponse}
+// @Success 207 {object} models.APIResponse{data=models.BatchUploadResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 413 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /media/upload/batch [post]
+func (mc *MediaController) UploadMediaBatch(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	// Receiving up to UPLOAD_BATCH_MAX_SIZE takes far longer than the server's timeouts
+	extendUploadDeadlines(c)
+
+	uploadConfig := config.AppConfig.Upload
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, uploadConfig.BatchMaxSize+multipartOverhead)
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponseWithCode(
+				"Batch size exceeds maximum allowed size",
+				"BATCH_TOO_LARGE",
+				gin.H{"max_size": uploadConfig.BatchMaxSize},
+			))
+			return
+		}
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid multipart form",
+			"INVALID_FORM",
+			err.Error(),
+		))
+		return
+	}
+
+	memoryID, err := strconv.Atoi(firstFormValue(form, "memory_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid memory ID",
+			"INVALID_MEMORY_ID",
+			nil,
+		))
+		return
+	}
+
+	files := form.File["files"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"No file uploaded",
+			"NO_FILE_UPLOADED",
+			nil,
+		))
+		return
+	}
+	if len(files) > uploadConfig.BatchMaxFiles {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			fmt.Sprintf("At most %d files can be uploaded at once", uploadConfig.BatchMaxFiles),
+			"TOO_MANY_FILES",
+			gin.H{"max_files": uploadConfig.BatchMaxFiles},
+		))
+		return
+	}
+
+	displayOrders, err := batchDisplayOrders(form.Value["display_order"], len(files))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			err.Error(),
+			"INVALID_DISPLAY_ORDER",
+			nil,
+		))
+		return
+	}
+
+	// Verify memory exists and belongs to user
+	var memory models.Memory
+	if err := database.DB.Where("id = ? AND user_id = ?", memoryID, userID).First(&memory).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusForbidden, models.ErrorResponseWithCode(
+				"Memory not found or access denied",
+				"MEMORY_ACCESS_DENIED",
+				nil,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	// Every file is stored and recorded on its own, at most BatchConcurrency at a time
+	ctx := c.Request.Context()
+	results := make([]models.BatchUploadResult, len(files))
+	concurrency := make(chan struct{}, max(uploadConfig.BatchConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, file := range files {
+		wg.Add(1)
+		go func(i int, file *multipart.FileHeader) {
+			defer wg.Done()
+			concurrency <- struct{}{}
+			defer func() { <-concurrency }()
+
+			result := models.BatchUploadResult{Index: i, Filename: file.Filename}
+			defer func() {
+				// A panicking file must not take the whole server down
+				if r := recover(); r != nil {
+					log.Printf("Batch upload of %q panicked: %v", file.Filename, r)
+					result.Success = false
+					result.Media = nil
+					result.Error = &models.ErrorResponse{Code: "FILE_SAVE_ERROR", Message: "Failed to save file"}
+				}
+				results[i] = result
+			}()
+
+			media, err := storeMediaFile(ctx, &user, memory.ID, displayOrders[i], file)
+			if err != nil {
+				_, failure := uploadFailure(err)
+				result.Error = &failure
+				return
+			}
+			response := uploadResponse(userID, media)
+			result.Success = true
+			result.Media = &response
+		}(i, file)
+	}
+	wg.Wait()
+
+	response := models.BatchUploadResponse{Results: results}
+	for _, result := range results {
+		if result.Success {
+			response.Uploaded++
+		} else {
+			response.Failed++
+		}
+	}
+
+	switch {
+	case response.Failed == 0:
+		c.JSON(http.StatusCreated, models.SuccessResponse(
+			"Media uploaded successfully",
+			response,
+		))
+	case response.Uploaded > 0:
+		c.JSON(http.StatusMultiStatus, models.SuccessResponse(
+			fmt.Sprintf("%d of %d files uploaded", response.Uploaded, len(files)),
+			response,
+		))
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"No file could be uploaded",
+			"BATCH_UPLOAD_FAILED",
+			response,
+		))
+	}
+}
+
+// batchDisplayOrders parses the display_order values of a batch; without them files are
+// ordered by their position in the batch
+func batchDisplayOrders(values []string, files int) ([]int, error) {
+	orders := make([]int, files)
+	if len(values) == 0 {
+		for i := range orders {
+			orders[i] = i
+		}
+		return orders, nil
+	}
+
+	if len(values) != files {
+		return nil, fmt.Errorf("Expected %d display_order values, one per file, got %d", files, len(values))
+	}
+	for i, value := range values {
+		order, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid display_order %q", value)
+		}
+		orders[i] = order
+	}
+	return orders, nil
+}
+
+// firstFormValue returns the first value of a multipart form field
+func firstFormValue(form *multipart.Form, name string) string {
+	if values := form.Value[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
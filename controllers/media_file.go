@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"map-memories-api/database"
+	"map-memories-api/models"
+	"map-memories-api/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// mediaCacheControl lets clients and proxies keep media files for a year; the file of a
+// media UUID never changes
+const mediaCacheControl = "public, max-age=31536000, immutable"
+
+// posterCacheControl is shorter than for media files since posters can be regenerated
+const posterCacheControl = "public, max-age=86400"
+
+// ServeMediaPoster godoc
+// @Summary Serve video poster frame
+// @Description Serve the JPEG poster frame of a video (the thumbnail_url of the media), once background processing created one. Supports ranges and conditional requests like the media file.
+// @Tags Media
+// @Produce image/jpeg
+// @Param uuid path string true "Media UUID"
+// @Success 200 {file} file "Poster frame"
+// @Success 304 "Cached copy is up to date"
+// @Failure 400 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /media/{uuid}/poster [get]
+func (mc *MediaController) ServeMediaPoster(c *gin.Context) {
+	mediaUUID, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid UUID format",
+			"INVALID_UUID",
+			nil,
+		))
+		return
+	}
+
+	var media models.Media
+	if err := database.DB.Select("id", "uuid", "poster_key").Where("uuid = ?", mediaUUID).First(&media).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"Media not found",
+				"MEDIA_NOT_FOUND",
+				nil,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+	if media.PosterKey == "" {
+		c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+			"Media has no poster frame",
+			"POSTER_NOT_FOUND",
+			nil,
+		))
+		return
+	}
+
+	content, object, err := storage.Open(c.Request.Context(), storage.Default, media.PosterKey)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"Poster frame not found in storage",
+				"FILE_NOT_FOUND",
+				nil,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to open poster frame",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+	defer content.Close()
+
+	c.Header("ETag", objectETag(object))
+	c.Header("Content-Type", "image/jpeg")
+	c.Header("Cache-Control", posterCacheControl)
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, "", object.ModTime, content)
+}
+
+// mediaETag returns the ETag of a media file: a strong one derived from the content hash,
+// or for media uploaded before hashing a weak one derived from the stored object
+func mediaETag(media *models.Media, object *storage.Object) string {
+	if media.Checksum != "" {
+		return `"` + media.Checksum + `"`
+	}
+	if object == nil {
+		return ""
+	}
+	return objectETag(object)
+}
+
+// objectETag returns a weak ETag derived from a stored object
+func objectETag(object *storage.Object) string {
+	tag := object.ETag
+	if tag == "" {
+		tag = strconv.FormatInt(object.ModTime.UnixNano(), 36) + "-" + strconv.FormatInt(object.Size, 36)
+	}
+	return `W/"` + tag + `"`
+}
+
+// lastModified returns the modification time of a media file
+func lastModified(media *models.Media, object *storage.Object) time.Time {
+	if !media.CreatedAt.IsZero() {
+		return media.CreatedAt
+	}
+	return object.ModTime
+}
+
+// contentDisposition builds a Content-Disposition header, quoting or RFC 2231 encoding
+// the filename as needed
+func contentDisposition(disposition, filename string) string {
+	if filename == "" {
+		return disposition
+	}
+	if header := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); header != "" {
+		return header
+	}
+	return disposition
+}
+
+// notModified reports whether the client's cached copy is current: If-None-Match is
+// compared weakly against etag, and If-Modified-Since only counts without it
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modTime.IsZero() {
+		return false
+	}
+	return !modTime.Truncate(time.Second).After(since)
+}
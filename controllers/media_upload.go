@@ -0,0 +1,580 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"map-memories-api/blobs"
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/middleware"
+	"map-memories-api/models"
+	"map-memories-api/resumable"
+	"map-memories-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreateUpload godoc
+// @Summary Start a resumable upload
+// @Description Start a chunked upload for a large file. Send the bytes with PATCH requests to the returned upload URL, then complete the upload.
+// @Tags Media
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.UploadCreateRequest true "Upload details"
+// @Success 201 {object} models.APIResponse{data=models.UploadSessionResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 413 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /media/uploads [post]
+func (mc *MediaController) CreateUpload(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	var req models.UploadCreateRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	uploadConfig := config.AppConfig.Upload
+	if req.Size > uploadConfig.MaxResumableSize {
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponseWithCode(
+			"File size exceeds maximum allowed size",
+			"FILE_TOO_LARGE",
+			gin.H{"max_size": uploadConfig.MaxResumableSize},
+		))
+		return
+	}
+
+	// The declared size counts against the quota until the upload completes or expires
+	if _, err := quotaCheck(userID, req.Size); err != nil {
+		respondQuotaError(c, err)
+		return
+	}
+
+	// Verify memory exists and belongs to user
+	var memory models.Memory
+	if err := database.DB.Where("id = ? AND user_id = ?", req.MemoryID, userID).First(&memory).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusForbidden, models.ErrorResponseWithCode(
+				"Memory not found or access denied",
+				"MEMORY_ACCESS_DENIED",
+				nil,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	session := models.UploadSession{
+		UserID:       userID,
+		MemoryID:     memory.ID,
+		Filename:     req.Filename,
+		Size:         req.Size,
+		DisplayOrder: req.DisplayOrder,
+		Checksum:     strings.ToLower(req.Checksum),
+		ExpiresAt:    time.Now().Add(uploadConfig.SessionTTL),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to start upload",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	c.Header("Location", "/api/v1/media/uploads/"+session.UUID.String())
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		"Upload started",
+		session.ToResponse(uploadConfig.ChunkSize),
+	))
+}
+
+// GetUpload godoc
+// @Summary Get resumable upload status
+// @Description Get how many bytes of an upload were received, to resume after an interruption
+// @Tags Media
+// @Produce json
+// @Security BearerAuth
+// @Param uuid path string true "Upload UUID"
+// @Success 200 {object} models.APIResponse{data=models.UploadSessionResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Router /media/uploads/{uuid} [get]
+func (mc *MediaController) GetUpload(c *gin.Context) {
+	session, ok := findUploadSession(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.Size, 10))
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Upload retrieved successfully",
+		session.ToResponse(config.AppConfig.Upload.ChunkSize),
+	))
+}
+
+// UploadChunk godoc
+// @Summary Upload a chunk
+// @Description Append a chunk to a resumable upload. Upload-Offset must equal the current offset and Upload-Checksum must be "sha256 <base64 digest>" of the chunk; a chunk that fails the checksum is discarded and can be sent again.
+// @Tags Media
+// @Accept application/offset+octet-stream
+// @Produce json
+// @Security BearerAuth
+// @Param uuid path string true "Upload UUID"
+// @Param Upload-Offset header int true "Offset of the chunk"
+// @Param Upload-Checksum header string true "sha256 <base64 digest of the chunk>"
+// @Success 200 {object} models.APIResponse{data=models.UploadSessionResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 413 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /media/uploads/{uuid} [patch]
+func (mc *MediaController) UploadChunk(c *gin.Context) {
+	extendUploadDeadlines(c)
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Upload-Offset header is required",
+			"INVALID_OFFSET",
+			nil,
+		))
+		return
+	}
+
+	checksum, ok := parseChunkChecksum(c.GetHeader("Upload-Checksum"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Upload-Checksum header must be \"sha256 <base64 digest>\"",
+			"INVALID_CHECKSUM",
+			nil,
+		))
+		return
+	}
+
+	session, ok := findUploadSession(c)
+	if !ok {
+		return
+	}
+
+	unlock := resumable.Lock(session.UUID)
+	defer unlock()
+
+	// Reload under the lock; a concurrent chunk may have moved the offset
+	if err := database.DB.First(session, session.ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+			"Upload not found",
+			"UPLOAD_NOT_FOUND",
+			nil,
+		))
+		return
+	}
+
+	if offset != session.Offset {
+		c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+			"Upload-Offset does not match the received data",
+			"OFFSET_MISMATCH",
+			gin.H{"offset": session.Offset},
+		))
+		return
+	}
+
+	uploadConfig := config.AppConfig.Upload
+	limit := session.Size - session.Offset
+	if limit > uploadConfig.MaxChunkSize {
+		limit = uploadConfig.MaxChunkSize
+	}
+	if c.Request.ContentLength > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponseWithCode(
+			"Chunk is too large",
+			"CHUNK_TOO_LARGE",
+			gin.H{"max_chunk_size": limit},
+		))
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, limit+1)
+	newOffset, err := resumable.AppendChunk(session.UUID, session.Offset, limit, body, checksum)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.Is(err, resumable.ErrChecksumMismatch):
+			c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+				"Chunk checksum does not match",
+				"CHECKSUM_MISMATCH",
+				nil,
+			))
+		case errors.Is(err, resumable.ErrChunkTooLarge), errors.As(err, &maxBytesErr):
+			c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponseWithCode(
+				"Chunk is too large",
+				"CHUNK_TOO_LARGE",
+				gin.H{"max_chunk_size": limit},
+			))
+		case errors.Is(err, resumable.ErrOffsetMismatch):
+			c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+				"Staged data is missing; restart the upload",
+				"OFFSET_MISMATCH",
+				nil,
+			))
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+				"Failed to store chunk",
+				"INTERNAL_ERROR",
+				err.Error(),
+			))
+		}
+		return
+	}
+
+	if err := resumable.AdvanceOffset(session, newOffset, time.Now().Add(uploadConfig.SessionTTL)); err != nil {
+		if errors.Is(err, resumable.ErrOffsetMismatch) {
+			c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+				"Upload-Offset does not match the received data",
+				"OFFSET_MISMATCH",
+				nil,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to update upload",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Chunk uploaded successfully",
+		session.ToResponse(uploadConfig.ChunkSize),
+	))
+}
+
+// CompleteUpload godoc
+// @Summary Complete a resumable upload
+// @Description Turn a fully received upload into a media file of its memory. Completing an upload again returns the media it created.
+// @Tags Media
+// @Produce json
+// @Security BearerAuth
+// @Param uuid path string true "Upload UUID"
+// @Success 200 {object} models.APIResponse{data=models.MediaUploadResponse} "Already completed"
+// @Success 201 {object} models.APIResponse{data=models.MediaUploadResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 413 {object} models.APIResponse
+// @Failure 415 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /media/uploads/{uuid}/complete [post]
+func (mc *MediaController) CompleteUpload(c *gin.Context) {
+	extendUploadDeadlines(c)
+
+	// A retry of a completed upload gets the media created the first time
+	if respondCompletedUpload(c) {
+		return
+	}
+
+	session, ok := findUploadSession(c)
+	if !ok {
+		return
+	}
+
+	unlock := resumable.Lock(session.UUID)
+	defer unlock()
+
+	if err := database.DB.First(session, session.ID).Error; err != nil {
+		// Completed by a concurrent request while waiting for the lock
+		if respondCompletedUpload(c) {
+			return
+		}
+		c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+			"Upload not found",
+			"UPLOAD_NOT_FOUND",
+			nil,
+		))
+		return
+	}
+
+	// The memory may have been deleted while the upload was in progress
+	var memory models.Memory
+	if err := database.DB.Where("id = ? AND user_id = ?", session.MemoryID, session.UserID).First(&memory).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusForbidden, models.ErrorResponseWithCode(
+				"Memory not found or access denied",
+				"MEMORY_ACCESS_DENIED",
+				nil,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	if session.Offset != session.Size {
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+			"Upload is not complete",
+			"UPLOAD_INCOMPLETE",
+			gin.H{"offset": session.Offset, "size": session.Size},
+		))
+		return
+	}
+
+	file, err := resumable.Open(session.UUID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to read upload",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+	defer file.Close()
+
+	upload, err := utils.PrepareUpload(file, session.Filename, config.AppConfig.Upload.MaxResumableSize)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+	defer upload.Close()
+
+	if session.Checksum != "" && upload.Checksum != session.Checksum {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"File checksum does not match",
+			"CHECKSUM_MISMATCH",
+			nil,
+		))
+		return
+	}
+
+	ctx := c.Request.Context()
+	fileInfo, _, err := blobs.Store(ctx, upload)
+	if err != nil {
+		respondUploadError(c, err)
+		return
+	}
+
+	media := newMedia(session.MemoryID, session.DisplayOrder, fileInfo)
+	media.UploadUUID = &session.UUID
+
+	user := models.User{ID: session.UserID}
+	if err := database.DB.First(&user).Error; err != nil {
+		blobs.ReleaseMedia(ctx, media)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	// The session goes first so its reserved size is not counted twice
+	err = createMediaWithinQuota(&user, media, func(tx *gorm.DB) error {
+		result := tx.Delete(session)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+	if err != nil {
+		// Drop the reference on the stored file if database save fails
+		blobs.ReleaseMedia(ctx, media)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if respondCompletedUpload(c) {
+				return
+			}
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"Upload not found",
+				"UPLOAD_NOT_FOUND",
+				nil,
+			))
+			return
+		}
+		respondQuotaError(c, err)
+		return
+	}
+
+	file.Close()
+	if err := resumable.Remove(session.UUID); err != nil {
+		log.Printf("Failed to remove staged upload %s: %v", session.UUID, err)
+	}
+
+	c.JSON(http.StatusCreated, models.SuccessResponse(
+		"Media uploaded successfully",
+		uploadResponse(session.UserID, media),
+	))
+}
+
+// CancelUpload godoc
+// @Summary Cancel a resumable upload
+// @Description Abort an upload and discard the received data
+// @Tags Media
+// @Produce json
+// @Security BearerAuth
+// @Param uuid path string true "Upload UUID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /media/uploads/{uuid} [delete]
+func (mc *MediaController) CancelUpload(c *gin.Context) {
+	session, ok := findUploadSession(c)
+	if !ok {
+		return
+	}
+
+	unlock := resumable.Lock(session.UUID)
+	defer unlock()
+
+	if err := database.DB.Delete(session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to cancel upload",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+	if err := resumable.Remove(session.UUID); err != nil {
+		log.Printf("Failed to remove staged upload %s: %v", session.UUID, err)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Upload cancelled",
+		nil,
+	))
+}
+
+// findUploadSession loads the current user's unexpired upload named in the path and
+// writes the error response when there is none
+func findUploadSession(c *gin.Context) (*models.UploadSession, bool) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return nil, false
+	}
+
+	uploadUUID, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid UUID format",
+			"INVALID_UUID",
+			nil,
+		))
+		return nil, false
+	}
+
+	var session models.UploadSession
+	err = database.DB.Where("uuid = ? AND user_id = ? AND expires_at > ?", uploadUUID, userID, time.Now()).
+		First(&session).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"Upload not found",
+				"UPLOAD_NOT_FOUND",
+				nil,
+			))
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return nil, false
+	}
+
+	return &session, true
+}
+
+// respondCompletedUpload writes the media created from the upload named in the path,
+// if the current user already completed it
+func respondCompletedUpload(c *gin.Context) bool {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		return false
+	}
+	uploadUUID, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
+		return false
+	}
+
+	var media models.Media
+	err = database.DB.Joins("JOIN mm_memories ON mm_memories.id = mm_media.memory_id AND mm_memories.deleted_at IS NULL").
+		Where("mm_media.upload_uuid = ? AND mm_memories.user_id = ?", uploadUUID, userID).
+		First(&media).Error
+	if err != nil {
+		return false
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Media uploaded successfully",
+		uploadResponse(userID, &media),
+	))
+	return true
+}
+
+// extendUploadDeadlines replaces the server's read and write timeouts for an upload
+// request, which may need far longer to receive a chunk or to check and store a file
+func extendUploadDeadlines(c *gin.Context) {
+	deadline := time.Now().Add(config.AppConfig.Upload.Timeout)
+	controller := http.NewResponseController(c.Writer)
+	for _, setDeadline := range []func(time.Time) error{controller.SetReadDeadline, controller.SetWriteDeadline} {
+		if err := setDeadline(deadline); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			log.Printf("Failed to extend upload deadline: %v", err)
+		}
+	}
+}
+
+// parseChunkChecksum parses an Upload-Checksum header of the form "sha256 <base64>"
+func parseChunkChecksum(header string) ([]byte, bool) {
+	algorithm, value, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(algorithm, "sha256") {
+		return nil, false
+	}
+
+	digest, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || len(digest) != 32 {
+		return nil, false
+	}
+	return digest, true
+}
@@ -29,6 +29,7 @@ type MemoryController struct{}
 // @Success 201 {object} models.APIResponse{data=models.MemoryResponse}
 // @Failure 400 {object} models.APIResponse
 // @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
 // @Failure 500 {object} models.APIResponse
 // @Router /memories [post]
 func (mc *MemoryController) CreateMemory(c *gin.Context) {
@@ -52,6 +53,11 @@ func (mc *MemoryController) CreateMemory(c *gin.Context) {
 		return
 	}
 
+	// Only verified accounts may publish
+	if req.IsPublic && !requireVerifiedEmail(c, userID) {
+		return
+	}
+
 	// Verify location exists
 	var location models.Location
 	if err := database.DB.First(&location, req.LocationID).Error; err != nil {
@@ -101,7 +107,7 @@ func (mc *MemoryController) CreateMemory(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, models.SuccessResponse(
 		"Memory created successfully",
-		memory.ToResponse(),
+		memory.ToResponseFor(middleware.GetViewer(c)),
 	))
 }
 
@@ -137,6 +143,10 @@ func (mc *MemoryController) GetMemories(c *gin.Context) {
 	// Build query
 	query := database.DB.Preload("User").Preload("Location").Preload("Media")
 
+	// Private memories are only listed for their owner (admins see everything)
+	viewer := middleware.GetViewer(c)
+	query = visibleMemories(query, viewer)
+
 	// Apply filters
 	if userIDStr := c.Query("user_id"); userIDStr != "" {
 		if userID, err := strconv.Atoi(userIDStr); err == nil {
@@ -192,7 +202,7 @@ func (mc *MemoryController) GetMemories(c *gin.Context) {
 	// Convert to response format
 	memoryResponses := make([]models.MemoryResponse, len(memories))
 	for i, memory := range memories {
-		memoryResponses[i] = memory.ToResponse()
+		memoryResponses[i] = memory.ToResponseFor(viewer)
 	}
 
 	// Calculate pagination
@@ -262,7 +272,7 @@ func (mc *MemoryController) GetMemory(c *gin.Context) {
 
 	c.JSON(http.StatusOK, models.SuccessResponse(
 		"Memory retrieved successfully",
-		memory.ToResponse(),
+		memory.ToResponseFor(middleware.GetViewer(c)),
 	))
 }
 
@@ -338,6 +348,11 @@ func (mc *MemoryController) UpdateMemory(c *gin.Context) {
 		return
 	}
 
+	// Only verified accounts may publish
+	if req.IsPublic && !memory.IsPublic && !requireVerifiedEmail(c, userID) {
+		return
+	}
+
 	// Update fields
 	if req.Title != "" {
 		memory.Title = req.Title
@@ -368,7 +383,7 @@ func (mc *MemoryController) UpdateMemory(c *gin.Context) {
 
 	c.JSON(http.StatusOK, models.SuccessResponse(
 		"Memory updated successfully",
-		memory.ToResponse(),
+		memory.ToResponseFor(middleware.GetViewer(c)),
 	))
 }
 
@@ -450,4 +465,92 @@ func (mc *MemoryController) DeleteMemory(c *gin.Context) {
 		"Memory deleted successfully",
 		nil,
 	))
-}
\ No newline at end of file
+}
+
+// GetMemoryRegions godoc
+// @Summary Get regions containing a memory
+// @Description Get the area locations (regions, parks, cities) whose boundary contains the memory's location
+// @Tags Memories
+// @Produce json
+// @Param uuid path string true "Memory UUID"
+// @Success 200 {object} models.APIResponse{data=[]models.LocationResponse}
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /memories/{uuid}/regions [get]
+func (mc *MemoryController) GetMemoryRegions(c *gin.Context) {
+	memoryUUID, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid UUID format",
+			"INVALID_UUID",
+			nil,
+		))
+		return
+	}
+
+	var memory models.Memory
+	if err := database.DB.Preload("Location").Where("uuid = ?", memoryUUID).First(&memory).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"Memory not found",
+				"MEMORY_NOT_FOUND",
+				nil,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	// Check if memory is private and user is not the owner
+	currentUserID, authenticated := middleware.GetCurrentUserID(c)
+	if !memory.IsPublic && (!authenticated || memory.UserID != currentUserID) {
+		c.JSON(http.StatusForbidden, models.ErrorResponseWithCode(
+			"Access denied",
+			"FORBIDDEN",
+			nil,
+		))
+		return
+	}
+
+	regions, err := findContainingLocations(memory.Location.Latitude, memory.Location.Longitude)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to search regions",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	// The memory's own location is not a region containing itself
+	regionResponses := make([]models.LocationResponse, 0, len(regions))
+	for _, region := range regions {
+		if region.ID == memory.LocationID {
+			continue
+		}
+		regionResponses = append(regionResponses, region.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Memory regions retrieved successfully",
+		regionResponses,
+	))
+}
+
+// visibleMemories restricts a memory query to what the viewer may see: public memories
+// and the viewer's own; admins are not restricted
+func visibleMemories(query *gorm.DB, viewer models.Viewer) *gorm.DB {
+	if viewer.IsAdmin {
+		return query
+	}
+	if viewer.UserID == 0 {
+		return query.Where("is_public = ?", true)
+	}
+	return query.Where("(is_public = ? OR user_id = ?)", true, viewer.UserID)
+}
@@ -0,0 +1,525 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/middleware"
+	"map-memories-api/models"
+	"map-memories-api/oauth"
+	"map-memories-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var (
+	errIdentityLinked      = errors.New("identity is linked to another account")
+	errAccountUnverified   = errors.New("an unverified account already uses this email")
+	errIdentityEmailNeeded = errors.New("identity provider returned no email")
+	errLastSignInMethod    = errors.New("cannot remove the last sign-in method")
+)
+
+var usernameInvalidChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// GetOAuthProviders godoc
+// @Summary List social login providers
+// @Description Get the names of the configured identity providers
+// @Tags Authentication
+// @Produce json
+// @Success 200 {object} models.APIResponse{data=[]string}
+// @Router /auth/oauth/providers [get]
+func (ac *AuthController) GetOAuthProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Identity providers retrieved successfully",
+		oauth.Names(),
+	))
+}
+
+// StartOAuth godoc
+// @Summary Start social login
+// @Description Create an authorization request (authorization code flow with PKCE) and return the provider URL to send the user to. When called with a token, the provider is linked to the signed-in account instead.
+// @Tags Authentication
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Success 200 {object} models.APIResponse{data=models.OAuthStartResponse}
+// @Failure 404 {object} models.APIResponse
+// @Failure 502 {object} models.APIResponse
+// @Router /auth/oauth/{provider}/start [post]
+func (ac *AuthController) StartOAuth(c *gin.Context) {
+	provider, ok := findOAuthProvider(c)
+	if !ok {
+		return
+	}
+
+	state, stateErr := oauth.RandomString()
+	nonce, nonceErr := oauth.RandomString()
+	verifier, verifierErr := oauth.RandomString()
+	if stateErr != nil || nonceErr != nil || verifierErr != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to start login",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), oauth.AuthRequest{
+		State:         state,
+		Nonce:         nonce,
+		CodeChallenge: oauth.CodeChallenge(verifier),
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, models.ErrorResponseWithCode(
+			"Identity provider is unavailable",
+			"OAUTH_PROVIDER_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	record := models.OAuthState{
+		StateHash:    utils.HashToken(state),
+		Provider:     provider.Name(),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(config.AppConfig.OAuth.StateTTL),
+	}
+	if userID, authenticated := middleware.GetCurrentUserID(c); authenticated {
+		record.UserID = &userID
+	}
+
+	// Drop abandoned requests while we are here
+	database.DB.Where("expires_at < ?", time.Now()).Delete(&models.OAuthState{})
+
+	if err := database.DB.Create(&record).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to start login",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Authorization URL created",
+		models.OAuthStartResponse{
+			Provider:         provider.Name(),
+			AuthorizationURL: authURL,
+		},
+	))
+}
+
+// OAuthCallback godoc
+// @Summary Complete social login
+// @Description Exchange the code and state the provider redirected back with. Signs in the linked account, links an existing account with the same verified email, or creates a new account. When the flow was started to link a provider, the request must carry the token of that same user and the linked identity is returned instead of a new session.
+// @Tags Authentication
+// @Accept json
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param request body models.OAuthCallbackRequest true "Code and state from the provider redirect"
+// @Success 200 {object} models.APIResponse{data=models.AuthResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/oauth/{provider}/callback [post]
+func (ac *AuthController) OAuthCallback(c *gin.Context) {
+	provider, ok := findOAuthProvider(c)
+	if !ok {
+		return
+	}
+
+	// Providers using response_mode=form_post (Apple) submit a form instead of JSON
+	var req models.OAuthCallbackRequest
+	if err := utils.ValidateAndBind(c, &req); err != nil {
+		return
+	}
+
+	state, err := consumeOAuthState(provider.Name(), req.State)
+	if err != nil {
+		respondTokenError(c, err)
+		return
+	}
+
+	// A link flow only completes in the session that started it; otherwise anyone could
+	// send a victim a callback URL that links the attacker's identity to their account
+	if state.UserID != nil {
+		userID, authenticated := middleware.GetCurrentUserID(c)
+		if !authenticated || middleware.IsAPITokenRequest(c) || userID != *state.UserID {
+			c.JSON(http.StatusForbidden, models.ErrorResponseWithCode(
+				"Sign in to the account that started linking this provider",
+				"OAUTH_LINK_SESSION_REQUIRED",
+				nil,
+			))
+			return
+		}
+	}
+
+	identity, err := provider.Exchange(c.Request.Context(), req.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("OIDC login with %s failed: %v", provider.Name(), err)
+		recordLoginFailure(c, nil, "", models.LoginMethodOAuth, loginFailureOAuth)
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Sign in with the identity provider failed",
+			"OAUTH_FAILED",
+			nil,
+		))
+		return
+	}
+	if identity.Name == "" {
+		identity.Name = oauth.NameFromUserParam(req.User)
+	}
+
+	if state.UserID != nil {
+		linked, err := linkIdentity(identity, *state.UserID)
+		if err != nil {
+			respondIdentityError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, models.SuccessResponse(
+			"Identity linked successfully",
+			linked.ToResponse(),
+		))
+		return
+	}
+
+	user, err := resolveIdentityUser(identity)
+	if err != nil {
+		respondIdentityError(c, err)
+		return
+	}
+
+	completeLogin(c, user, models.LoginMethodOAuth)
+}
+
+// respondIdentityError writes the response for a failed sign in or link with an identity
+func respondIdentityError(c *gin.Context, err error) {
+	switch err {
+	case errIdentityLinked:
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+			"This identity is already linked to another account",
+			"IDENTITY_LINKED",
+			nil,
+		))
+	case errAccountUnverified:
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+			"An account with this email exists but its email is not verified; sign in with the password and verify the email first",
+			"ACCOUNT_NOT_VERIFIED",
+			nil,
+		))
+	case errIdentityEmailNeeded:
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"The identity provider did not share an email address",
+			"OAUTH_EMAIL_REQUIRED",
+			nil,
+		))
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to sign in",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+	}
+}
+
+// GetIdentities godoc
+// @Summary List linked identities
+// @Description Get the identity providers linked to the current user
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse{data=[]models.UserIdentityResponse}
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/identities [get]
+func (ac *AuthController) GetIdentities(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	var identities []models.UserIdentity
+	if err := database.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to fetch identities",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	identityResponses := make([]models.UserIdentityResponse, len(identities))
+	for i, identity := range identities {
+		identityResponses[i] = identity.ToResponse()
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Identities retrieved successfully",
+		identityResponses,
+	))
+}
+
+// UnlinkIdentity godoc
+// @Summary Unlink an identity provider
+// @Description Remove a linked identity provider. The last sign-in method of an account without a password cannot be removed.
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Param provider path string true "Provider name"
+// @Success 200 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/identities/{provider} [delete]
+func (ac *AuthController) UnlinkIdentity(c *gin.Context) {
+	user, ok := currentUserWithPassword(c)
+	if !ok {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.UserIdentity{}).Where("user_id = ?", user.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if user.PasswordHash == "" && count <= 1 {
+			return errLastSignInMethod
+		}
+
+		result := tx.Where("user_id = ? AND provider = ?", user.ID, c.Param("provider")).Delete(&models.UserIdentity{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		switch err {
+		case gorm.ErrRecordNotFound:
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"Identity not found",
+				"IDENTITY_NOT_FOUND",
+				nil,
+			))
+		case errLastSignInMethod:
+			c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+				"Set a password before removing your only sign-in method",
+				"LAST_SIGN_IN_METHOD",
+				nil,
+			))
+		default:
+			c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+				"Failed to unlink identity",
+				"INTERNAL_ERROR",
+				err.Error(),
+			))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Identity unlinked successfully",
+		nil,
+	))
+}
+
+// findOAuthProvider resolves the :provider path parameter and writes a 404 when it is unknown
+func findOAuthProvider(c *gin.Context) (oauth.Provider, bool) {
+	provider, err := oauth.Get(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+			"Identity provider not found",
+			"OAUTH_PROVIDER_NOT_FOUND",
+			nil,
+		))
+		return nil, false
+	}
+	return provider, true
+}
+
+// consumeOAuthState loads and deletes the authorization request a state belongs to
+func consumeOAuthState(provider, state string) (*models.OAuthState, error) {
+	var record models.OAuthState
+	if err := database.DB.Where("state_hash = ? AND provider = ?", utils.HashToken(state), provider).
+		First(&record).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errTokenInvalid
+		}
+		return nil, err
+	}
+
+	// Deleting is the single-use guard: only one request can remove the row
+	result := database.DB.Where("id = ?", record.ID).Delete(&models.OAuthState{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errTokenUsed
+	}
+	if time.Now().After(record.ExpiresAt) {
+		return nil, errTokenExpired
+	}
+
+	return &record, nil
+}
+
+// resolveIdentityUser finds or creates the user an external identity signs in as
+func resolveIdentityUser(identity *oauth.Identity) (*models.User, error) {
+	var user models.User
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+
+		var existing models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&existing).Error
+		switch {
+		case err == nil:
+			if err := tx.Model(&existing).Updates(map[string]interface{}{
+				"email":         identity.Email,
+				"last_login_at": now,
+			}).Error; err != nil {
+				return err
+			}
+			return tx.First(&user, existing.UserID).Error
+		case err != gorm.ErrRecordNotFound:
+			return err
+		}
+
+		if identity.Email == "" {
+			return errIdentityEmailNeeded
+		}
+		err = tx.Where("email = ?", identity.Email).First(&user).Error
+		switch {
+		case err == nil:
+			// Only link accounts when both sides proved ownership of the address
+			if !identity.EmailVerified || !user.IsEmailVerified() {
+				return errAccountUnverified
+			}
+		case err == gorm.ErrRecordNotFound:
+			created, err := createIdentityUser(tx, identity)
+			if err != nil {
+				return err
+			}
+			user = *created
+		default:
+			return err
+		}
+
+		return tx.Create(&models.UserIdentity{
+			UserID:      user.ID,
+			Provider:    identity.Provider,
+			Subject:     identity.Subject,
+			Email:       identity.Email,
+			LastLoginAt: &now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// linkIdentity links an external identity to the signed-in user who started the flow
+func linkIdentity(identity *oauth.Identity, userID uint) (*models.UserIdentity, error) {
+	var linked models.UserIdentity
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&linked).Error
+		switch {
+		case err == nil:
+			if linked.UserID != userID {
+				return errIdentityLinked
+			}
+			// Already linked to this user
+			return tx.Model(&linked).Update("email", identity.Email).Error
+		case err != gorm.ErrRecordNotFound:
+			return err
+		}
+
+		linked = models.UserIdentity{
+			UserID:   userID,
+			Provider: identity.Provider,
+			Subject:  identity.Subject,
+			Email:    identity.Email,
+		}
+		return tx.Create(&linked).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &linked, nil
+}
+
+// createIdentityUser registers a password-less user for an external identity
+func createIdentityUser(tx *gorm.DB, identity *oauth.Identity) (*models.User, error) {
+	username, err := availableUsername(tx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	user := models.User{
+		Username:  username,
+		Email:     identity.Email,
+		FullName:  identity.Name,
+		AvatarURL: identity.Picture,
+	}
+	if identity.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := tx.Create(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// availableUsername derives a free username from the identity's email address
+func availableUsername(tx *gorm.DB, identity *oauth.Identity) (string, error) {
+	base := strings.ToLower(strings.SplitN(identity.Email, "@", 2)[0])
+	base = strings.Trim(usernameInvalidChars.ReplaceAllString(base, "_"), "_")
+	if len(base) < 3 {
+		base = "user_" + base
+	}
+	if len(base) > 40 {
+		base = base[:40]
+	}
+
+	candidate := base
+	for i := 0; i < 20; i++ {
+		var count int64
+		if err := tx.Unscoped().Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+
+		suffix, err := oauth.RandomString()
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s_%s", base, strings.ToLower(usernameInvalidChars.ReplaceAllString(suffix, ""))[:4])
+	}
+
+	return "", errors.New("could not find a free username")
+}
@@ -0,0 +1,226 @@
+package controllers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"map-memories-api/database"
+	"map-memories-api/models"
+
+	"github.com/google/uuid"
+)
+
+// privateUserFields are the JSON fields of a user no anonymous caller may see
+var privateUserFields = []string{
+	"email", "pending_email", "email_verified", "email_verified_at", "role",
+	"two_factor_enabled", "two_factor_enabled_at", "deletion_scheduled_at", "last_login_at",
+}
+
+// publicFixture is a public author with a public and a private memory at one location,
+// and a second user whose profile is private
+type publicFixture struct {
+	author         models.User
+	privateProfile models.User
+	location       models.Location
+	publicMemory   models.Memory
+	privateMemory  models.Memory
+}
+
+func createPublicFixture(t *testing.T) publicFixture {
+	t.Helper()
+
+	suffix := strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+	f := publicFixture{
+		author: models.User{
+			Username:     "author_" + suffix,
+			Email:        "author_" + suffix + "@example.com",
+			PendingEmail: "pending_" + suffix + "@example.com",
+			PasswordHash: "unused",
+			FullName:     "Public Author",
+			Role:         models.RoleModerator,
+		},
+		privateProfile: models.User{
+			Username:          "hidden_" + suffix,
+			Email:             "hidden_" + suffix + "@example.com",
+			PasswordHash:      "unused",
+			ProfileVisibility: models.ProfileVisibilityPrivate,
+		},
+	}
+	for _, user := range []*models.User{&f.author, &f.privateProfile} {
+		if err := database.DB.Create(user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+
+	f.location = models.Location{Name: "Harbour " + suffix, Latitude: 10.77, Longitude: 106.7, Country: "VN", CreatedBy: &f.author.ID}
+	if err := database.DB.Create(&f.location).Error; err != nil {
+		t.Fatalf("create location: %v", err)
+	}
+
+	f.publicMemory = models.Memory{UserID: f.author.ID, LocationID: f.location.ID, Title: "Public " + suffix, Content: "Shared", IsPublic: true}
+	f.privateMemory = models.Memory{UserID: f.author.ID, LocationID: f.location.ID, Title: "Private " + suffix, Content: "Secret diary entry"}
+	hidden := models.Memory{UserID: f.privateProfile.ID, LocationID: f.location.ID, Title: "Hidden profile " + suffix, Content: "Public memory of a private profile", IsPublic: true}
+	for _, memory := range []*models.Memory{&f.publicMemory, &f.privateMemory, &hidden} {
+		if err := database.DB.Create(memory).Error; err != nil {
+			t.Fatalf("create memory: %v", err)
+		}
+	}
+	return f
+}
+
+// assertNoPrivateData fails when a response body contains a private field of a user
+// anywhere in it, or any of the given strings (email addresses, private titles)
+func assertNoPrivateData(t *testing.T, body []byte, secrets ...string) {
+	t.Helper()
+
+	for _, secret := range secrets {
+		if strings.Contains(string(body), secret) {
+			t.Errorf("response contains %q: %s", secret, body)
+		}
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	var walk func(path string, node interface{})
+	walk = func(path string, node interface{}) {
+		switch node := node.(type) {
+		case map[string]interface{}:
+			for key, child := range node {
+				for _, field := range privateUserFields {
+					if key == field {
+						t.Errorf("response has private field %s.%s", path, key)
+					}
+				}
+				walk(path+"."+key, child)
+			}
+		case []interface{}:
+			for _, child := range node {
+				walk(path+"[]", child)
+			}
+		}
+	}
+	walk("", decoded)
+}
+
+// memoryUUIDs returns the UUIDs of a list of memories
+func memoryUUIDs(t *testing.T, data json.RawMessage) map[uuid.UUID]bool {
+	t.Helper()
+
+	var memories []models.MemoryResponse
+	if err := json.Unmarshal(data, &memories); err != nil {
+		t.Fatalf("decode memories: %v", err)
+	}
+	uuids := make(map[uuid.UUID]bool, len(memories))
+	for _, memory := range memories {
+		uuids[memory.UUID] = true
+	}
+	return uuids
+}
+
+func (f publicFixture) secrets() []string {
+	return []string{f.author.Email, f.author.PendingEmail, f.privateProfile.Email, f.privateMemory.Title, f.privateMemory.Content}
+}
+
+func TestAnonymousUserProfileHasNoPrivateData(t *testing.T) {
+	router := setupTestDB(t)
+	f := createPublicFixture(t)
+
+	for _, param := range []string{f.author.Username, f.author.UUID.String()} {
+		recorder := request(t, router, http.MethodGet, "/api/v1/users/"+param, "", "")
+		response := decodeResponse(t, recorder, http.StatusOK)
+		assertNoPrivateData(t, recorder.Body.Bytes(), f.secrets()...)
+
+		var profile models.UserProfileResponse
+		if err := json.Unmarshal(response.Data, &profile); err != nil {
+			t.Fatalf("decode profile: %v", err)
+		}
+		if profile.Username != f.author.Username {
+			t.Errorf("username = %q, want %q", profile.Username, f.author.Username)
+		}
+		// The private memory is not counted either
+		if profile.MemoryCount != 1 {
+			t.Errorf("memory_count = %d, want 1", profile.MemoryCount)
+		}
+	}
+}
+
+func TestAnonymousUserMemoriesListOnlyPublicMemories(t *testing.T) {
+	router := setupTestDB(t)
+	f := createPublicFixture(t)
+
+	recorder := request(t, router, http.MethodGet, "/api/v1/users/"+f.author.Username+"/memories", "", "")
+	response := decodeResponse(t, recorder, http.StatusOK)
+	assertNoPrivateData(t, recorder.Body.Bytes(), f.secrets()...)
+
+	uuids := memoryUUIDs(t, response.Data)
+	if len(uuids) != 1 || !uuids[f.publicMemory.UUID] {
+		t.Errorf("memories = %v, want only the public memory %s", uuids, f.publicMemory.UUID)
+	}
+}
+
+func TestAnonymousCannotSeePrivateProfile(t *testing.T) {
+	router := setupTestDB(t)
+	f := createPublicFixture(t)
+
+	for _, path := range []string{"", "/memories", "/locations", "/stats"} {
+		recorder := request(t, router, http.MethodGet, "/api/v1/users/"+f.privateProfile.Username+path, "", "")
+		mustStatus(t, recorder, http.StatusForbidden)
+		assertNoPrivateData(t, recorder.Body.Bytes(), f.privateProfile.Email)
+	}
+}
+
+func TestAnonymousMemoryHasNoPrivateData(t *testing.T) {
+	router := setupTestDB(t)
+	f := createPublicFixture(t)
+
+	recorder := request(t, router, http.MethodGet, "/api/v1/memories/"+f.publicMemory.UUID.String(), "", "")
+	response := decodeResponse(t, recorder, http.StatusOK)
+	assertNoPrivateData(t, recorder.Body.Bytes(), f.secrets()...)
+
+	var memory models.MemoryResponse
+	if err := json.Unmarshal(response.Data, &memory); err != nil {
+		t.Fatalf("decode memory: %v", err)
+	}
+	if memory.User.Username != f.author.Username || memory.User.FullName != f.author.FullName {
+		t.Errorf("author = %+v, want the public profile of %s", memory.User, f.author.Username)
+	}
+
+	recorder = request(t, router, http.MethodGet, "/api/v1/memories/"+f.privateMemory.UUID.String(), "", "")
+	mustStatus(t, recorder, http.StatusForbidden)
+	assertNoPrivateData(t, recorder.Body.Bytes(), f.secrets()...)
+}
+
+func TestAnonymousMemoryListsHidePrivateMemories(t *testing.T) {
+	router := setupTestDB(t)
+	f := createPublicFixture(t)
+
+	for _, path := range []string{
+		"/api/v1/locations/" + f.location.UUID.String() + "/memories",
+		fmt.Sprintf("/api/v1/memories?user_id=%d", f.author.ID),
+	} {
+		recorder := request(t, router, http.MethodGet, path, "", "")
+		response := decodeResponse(t, recorder, http.StatusOK)
+		assertNoPrivateData(t, recorder.Body.Bytes(), f.secrets()...)
+
+		uuids := memoryUUIDs(t, response.Data)
+		if !uuids[f.publicMemory.UUID] {
+			t.Errorf("%s does not list the public memory", path)
+		}
+		if uuids[f.privateMemory.UUID] {
+			t.Errorf("%s lists the private memory", path)
+		}
+	}
+
+	// Asking for private memories does not get around the filter
+	recorder := request(t, router, http.MethodGet, fmt.Sprintf("/api/v1/memories?user_id=%d&is_public=false", f.author.ID), "", "")
+	response := decodeResponse(t, recorder, http.StatusOK)
+	assertNoPrivateData(t, recorder.Body.Bytes(), f.secrets()...)
+	if uuids := memoryUUIDs(t, response.Data); len(uuids) != 0 {
+		t.Errorf("is_public=false listed %v for an anonymous caller", uuids)
+	}
+}
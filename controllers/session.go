@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"map-memories-api/accounts"
+	"map-memories-api/database"
+	"map-memories-api/middleware"
+	"map-memories-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Reasons recorded for failed login events
+const (
+	loginFailureUnknownEmail     = "unknown_email"
+	loginFailureInvalidPassword  = "invalid_password"
+	loginFailureInvalidTwoFactor = "invalid_two_factor_code"
+	loginFailureLocked           = "locked"
+	loginFailureOAuth            = "oauth_failed"
+)
+
+// loginLockedError is returned when the account or client address is locked out
+type loginLockedError struct {
+	retryAfter time.Duration
+}
+
+func (e *loginLockedError) Error() string {
+	return fmt.Sprintf("login locked for %s", e.retryAfter)
+}
+
+// GetSessions godoc
+// @Summary List active sessions
+// @Description Get the current user's active sessions with the address and client they were started from
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse{data=[]models.SessionResponse}
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/sessions [get]
+func (ac *AuthController) GetSessions(c *gin.Context) {
+	claims, exists := middleware.GetCurrentClaims(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	var sessions []models.UserSession
+	if err := database.DB.Where("user_id = ? AND expires_at > ?", claims.UserID, time.Now()).
+		Order("created_at DESC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to fetch sessions",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	currentHash := middleware.SessionTokenHash(claims)
+	sessionResponses := make([]models.SessionResponse, len(sessions))
+	for i, session := range sessions {
+		sessionResponses[i] = session.ToResponse(session.TokenHash == currentHash)
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Sessions retrieved successfully",
+		sessionResponses,
+	))
+}
+
+// RevokeSession godoc
+// @Summary Revoke a session
+// @Description Sign out one of the current user's sessions; its token stops working immediately
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Session ID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/sessions/{id} [delete]
+func (ac *AuthController) RevokeSession(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid session ID",
+			"INVALID_ID",
+			nil,
+		))
+		return
+	}
+
+	result := database.DB.Where("id = ? AND user_id = ?", sessionID, userID).Delete(&models.UserSession{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to revoke session",
+			"INTERNAL_ERROR",
+			result.Error.Error(),
+		))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+			"Session not found",
+			"SESSION_NOT_FOUND",
+			nil,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Session revoked successfully",
+		nil,
+	))
+}
+
+// checkLoginLockout returns a lockout error when logins for the email (or only the
+// client address when email is empty) are currently locked
+func checkLoginLockout(c *gin.Context, email string) error {
+	lockout, err := accounts.LoginLockout(email, c.ClientIP(), time.Now())
+	if err != nil {
+		return err
+	}
+	if lockout > 0 {
+		return &loginLockedError{retryAfter: lockout}
+	}
+	return nil
+}
+
+// respondLoginLocked writes the 429 response for a locked out login
+func respondLoginLocked(c *gin.Context, err *loginLockedError) {
+	retryAfter := int(math.Ceil(err.retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, models.ErrorResponseWithCode(
+		"Too many failed login attempts, try again later",
+		"TOO_MANY_ATTEMPTS",
+		gin.H{"retry_after": retryAfter},
+	))
+}
+
+// recordLoginFailure audits a failed login and counts it towards the lockout of the
+// email and client address; locked out attempts are only audited
+func recordLoginFailure(c *gin.Context, user *models.User, email, method, reason string) {
+	recordLoginEvent(c, user, email, method, reason)
+
+	if reason == loginFailureLocked || reason == loginFailureOAuth {
+		return
+	}
+	if err := accounts.RecordLoginFailure(email, c.ClientIP(), time.Now()); err != nil {
+		log.Printf("Failed to record failed login for %s: %v", email, err)
+	}
+}
+
+// recordLoginSuccess audits a completed login and clears the account's failed attempts
+func recordLoginSuccess(c *gin.Context, user *models.User, method string) {
+	recordLoginEvent(c, user, user.Email, method, "")
+
+	if err := accounts.RecordLoginSuccess(user.Email); err != nil {
+		log.Printf("Failed to reset failed logins of user %d: %v", user.ID, err)
+	}
+}
+
+// recordLoginEvent stores a row in the login audit log; an empty reason means success
+func recordLoginEvent(c *gin.Context, user *models.User, email, method, reason string) {
+	event := models.LoginEvent{
+		Email:     email,
+		Method:    method,
+		Success:   reason == "",
+		Reason:    reason,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if user != nil {
+		event.UserID = &user.ID
+	}
+
+	if err := database.DB.Create(&event).Error; err != nil {
+		log.Printf("Failed to record login event for %s: %v", email, err)
+	}
+}
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"map-memories-api/database"
+	"map-memories-api/middleware"
+	"map-memories-api/models"
+	"map-memories-api/quota"
+	"map-memories-api/utils"
+	"map-memories-api/videoproc"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type StorageController struct{}
+
+// GetStorageUsage godoc
+// @Summary Get storage usage
+// @Description Get how much storage the current user's media take, by images and videos, and the user's limits (null when unlimited)
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse{data=models.StorageUsageResponse}
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/storage [get]
+func (sc *StorageController) GetStorageUsage(c *gin.Context) {
+	userID, exists := middleware.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Authentication required",
+			"UNAUTHORIZED",
+			nil,
+		))
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	usage, err := quota.UsageOf(database.DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to calculate storage usage",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	limits := quota.LimitsFor(&user)
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Storage usage retrieved successfully",
+		models.StorageUsageResponse{
+			UsedBytes:    usage.Bytes,
+			UsedFiles:    usage.Files,
+			PendingBytes: usage.PendingBytes,
+			LimitBytes:   limitOrNil(limits.MaxBytes),
+			LimitFiles:   limitOrNil(limits.MaxFiles),
+			Images:       models.StorageBreakdown{Bytes: usage.ImageBytes, Files: usage.ImageFiles},
+			Videos:       models.StorageBreakdown{Bytes: usage.VideoBytes, Files: usage.VideoFiles},
+		},
+	))
+}
+
+// GetTopConsumers godoc
+// @Summary Get the largest storage consumers (Admin only)
+// @Description List the users whose media take the most storage
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param limit query int false "Number of users (default: 20, max: 100)"
+// @Success 200 {object} models.APIResponse{data=[]models.StorageConsumerResponse}
+// @Failure 403 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/storage/top [get]
+func (sc *StorageController) GetTopConsumers(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	var rows []struct {
+		UserID uint
+		Files  int64
+		Bytes  int64
+	}
+	err := database.DB.Table("mm_media").
+		Select("mm_memories.user_id, COUNT(*) AS files, COALESCE(SUM(mm_media.file_size), 0) AS bytes").
+		Joins("JOIN mm_memories ON mm_memories.id = mm_media.memory_id").
+		Group("mm_memories.user_id").
+		Order("bytes DESC").Limit(limit).
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to calculate storage usage",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	userIDs := make([]uint, len(rows))
+	for i, row := range rows {
+		userIDs[i] = row.UserID
+	}
+	var users []models.User
+	if len(userIDs) > 0 {
+		if err := database.DB.Unscoped().Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+				"Database error",
+				"INTERNAL_ERROR",
+				nil,
+			))
+			return
+		}
+	}
+	usersByID := make(map[uint]*models.User, len(users))
+	for i := range users {
+		usersByID[users[i].ID] = &users[i]
+	}
+
+	consumers := make([]models.StorageConsumerResponse, 0, len(rows))
+	for _, row := range rows {
+		user, ok := usersByID[row.UserID]
+		if !ok {
+			continue
+		}
+		limits := quota.LimitsFor(user)
+		consumers = append(consumers, models.StorageConsumerResponse{
+			UserUUID:   user.UUID,
+			Username:   user.Username,
+			Role:       user.Role,
+			UsedBytes:  row.Bytes,
+			UsedFiles:  row.Files,
+			LimitBytes: limitOrNil(limits.MaxBytes),
+			LimitFiles: limitOrNil(limits.MaxFiles),
+		})
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Storage consumers retrieved successfully",
+		consumers,
+	))
+}
+
+// UpdateUserQuota godoc
+// @Summary Set the storage quota of a user (Admin only)
+// @Description Override the role quota of one user; null restores the role quota and 0 means unlimited
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param uuid path string true "User UUID"
+// @Param request body models.QuotaUpdateRequest true "Quota"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{uuid}/quota [put]
+func (sc *StorageController) UpdateUserQuota(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid UUID format",
+			"INVALID_UUID",
+			nil,
+		))
+		return
+	}
+
+	var req models.QuotaUpdateRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("uuid = ?", userUUID).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"User not found",
+				"USER_NOT_FOUND",
+				nil,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	if err := database.DB.Model(&user).Updates(map[string]interface{}{
+		"storage_quota_bytes": req.MaxBytes,
+		"storage_quota_files": req.MaxFiles,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to update quota",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	adminID, _ := middleware.GetCurrentUserID(c)
+	log.Printf("Admin %d set the storage quota of user %d", adminID, user.ID)
+
+	user.StorageQuotaBytes = req.MaxBytes
+	user.StorageQuotaFiles = req.MaxFiles
+	limits := quota.LimitsFor(&user)
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Quota updated successfully",
+		gin.H{
+			"limit_bytes": limitOrNil(limits.MaxBytes),
+			"limit_files": limitOrNil(limits.MaxFiles),
+		},
+	))
+}
+
+// quotaCheck loads the user and checks that a file of size bytes still fits their quota
+func quotaCheck(userID uint, size int64) (*models.User, error) {
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if err := quota.Check(database.DB, &user, size); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// createMediaWithinQuota records a stored file as media of user, checking the quota in
+// the same transaction, and queues the processing of videos; before runs first inside
+// that transaction
+func createMediaWithinQuota(user *models.User, media *models.Media, before func(tx *gorm.DB) error) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if before != nil {
+			if err := before(tx); err != nil {
+				return err
+			}
+		}
+		if err := quota.Reserve(tx, user, media.FileSize); err != nil {
+			return err
+		}
+		if err := tx.Create(media).Error; err != nil {
+			return err
+		}
+		if media.ProcessingStatus == models.ProcessingPending {
+			return videoproc.Enqueue(tx, media.ID)
+		}
+		return nil
+	})
+}
+
+// respondQuotaError writes 413 with the quota details for an exceeded quota, or 500 for
+// any other error of checking it or saving the media
+func respondQuotaError(c *gin.Context, err error) {
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponseWithCode(
+			exceeded.Error(),
+			"QUOTA_EXCEEDED",
+			exceeded.Details(),
+		))
+		return
+	}
+	c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+		"Failed to save media record",
+		"INTERNAL_ERROR",
+		err.Error(),
+	))
+}
+
+// limitOrNil returns nil for unlimited (zero) limits
+func limitOrNil(limit int64) *int64 {
+	if limit <= 0 {
+		return nil
+	}
+	return &limit
+}
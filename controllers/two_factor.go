@@ -0,0 +1,521 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/middleware"
+	"map-memories-api/models"
+	"map-memories-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	totpIssuer        = "Map Memories"
+	recoveryCodeCount = 10
+)
+
+var errInvalidSecondFactor = errors.New("invalid two-factor code")
+
+// SetupTwoFactor godoc
+// @Summary Start two-factor enrollment
+// @Description Generate a TOTP secret for the current user. Add it to an authenticator app (the provisioning URI can be rendered as a QR code), then confirm with /auth/2fa/enable.
+// @Tags Authentication
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.APIResponse{data=models.TwoFactorSetupResponse}
+// @Failure 401 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/2fa/setup [post]
+func (ac *AuthController) SetupTwoFactor(c *gin.Context) {
+	user, ok := currentUserWithPassword(c)
+	if !ok {
+		return
+	}
+
+	if user.IsTwoFactorEnabled() {
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+			"Two-factor authentication is already enabled",
+			"TWO_FACTOR_ENABLED",
+			nil,
+		))
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to generate secret",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	encrypted, err := utils.EncryptSecret(secret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to store secret",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	if err := database.DB.Model(user).Updates(map[string]interface{}{
+		"two_factor_secret":    encrypted,
+		"two_factor_last_step": 0,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to store secret",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Scan the provisioning URI with an authenticator app, then confirm with a code",
+		models.TwoFactorSetupResponse{
+			Secret:          secret,
+			ProvisioningURI: utils.TOTPProvisioningURI(totpIssuer, user.Email, secret),
+		},
+	))
+}
+
+// EnableTwoFactor godoc
+// @Summary Enable two-factor authentication
+// @Description Confirm enrollment with a code from the authenticator app. Returns recovery codes, which are only shown once.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "TOTP code"
+// @Success 200 {object} models.APIResponse{data=models.RecoveryCodesResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 409 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/2fa/enable [post]
+func (ac *AuthController) EnableTwoFactor(c *gin.Context) {
+	user, ok := currentUserWithPassword(c)
+	if !ok {
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	if user.IsTwoFactorEnabled() {
+		c.JSON(http.StatusConflict, models.ErrorResponseWithCode(
+			"Two-factor authentication is already enabled",
+			"TWO_FACTOR_ENABLED",
+			nil,
+		))
+		return
+	}
+	if user.TwoFactorSecret == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Start two-factor setup first",
+			"TWO_FACTOR_NOT_SET_UP",
+			nil,
+		))
+		return
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := verifyTOTP(tx, user, req.Code); err != nil {
+			return err
+		}
+		if err := tx.Model(user).Update("two_factor_enabled_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		respondSecondFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Two-factor authentication enabled",
+		models.RecoveryCodesResponse{RecoveryCodes: codes},
+	))
+}
+
+// DisableTwoFactor godoc
+// @Summary Disable two-factor authentication
+// @Description Turn off two-factor authentication. Requires the password (for accounts without one, a recent sign-in) and a TOTP or recovery code.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorDisableRequest true "Password and code"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/2fa/disable [post]
+func (ac *AuthController) DisableTwoFactor(c *gin.Context) {
+	user, ok := currentUserWithPassword(c)
+	if !ok {
+		return
+	}
+
+	var req models.TwoFactorDisableRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	// The code is checked below as the second factor, so it cannot also stand in for the
+	// password of an account without one
+	if !requireTwoFactorEnabled(c, user) || !reauthenticate(c, user, req.Password, "") {
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := verifySecondFactor(tx, user, req.Code); err != nil {
+			return err
+		}
+		return clearTwoFactor(tx, user.ID)
+	})
+	if err != nil {
+		respondSecondFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Two-factor authentication disabled",
+		nil,
+	))
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes of the current user. Requires a TOTP or recovery code; the new codes are only shown once.
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.TwoFactorCodeRequest true "TOTP or recovery code"
+// @Success 200 {object} models.APIResponse{data=models.RecoveryCodesResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/2fa/recovery-codes [post]
+func (ac *AuthController) RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := currentUserWithPassword(c)
+	if !ok {
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	if !requireTwoFactorEnabled(c, user) {
+		return
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := verifySecondFactor(tx, user, req.Code); err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		respondSecondFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Recovery codes regenerated",
+		models.RecoveryCodesResponse{RecoveryCodes: codes},
+	))
+}
+
+// LoginTwoFactor godoc
+// @Summary Complete login with a second factor
+// @Description Exchange the challenge token returned by /auth/login and a TOTP or recovery code for an access token
+// @Tags Authentication
+// @Accept json
+// @Produce json
+// @Param request body models.TwoFactorLoginRequest true "Challenge token and code"
+// @Success 200 {object} models.APIResponse{data=models.AuthResponse}
+// @Failure 400 {object} models.APIResponse
+// @Failure 401 {object} models.APIResponse
+// @Failure 429 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /auth/login/2fa [post]
+func (ac *AuthController) LoginTwoFactor(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := utils.ValidateAndBindJSON(c, &req); err != nil {
+		return
+	}
+
+	var user models.User
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := consumeUserToken(tx, models.TokenPurposeLoginChallenge, req.ChallengeToken)
+		if err != nil {
+			return err
+		}
+		if err := tx.First(&user, token.UserID).Error; err != nil {
+			return err
+		}
+		if !user.IsTwoFactorEnabled() {
+			return errTokenInvalid
+		}
+
+		// Guessing codes counts towards the same lockout as guessing passwords
+		if err := checkLoginLockout(c, user.Email); err != nil {
+			return err
+		}
+
+		// A wrong code rolls back the transaction so the challenge can be retried
+		return verifySecondFactor(tx, &user, req.Code)
+	})
+	if err != nil {
+		if locked, ok := err.(*loginLockedError); ok {
+			recordLoginFailure(c, &user, user.Email, models.LoginMethodTwoFactor, loginFailureLocked)
+			respondLoginLocked(c, locked)
+			return
+		}
+		if err == errInvalidSecondFactor {
+			recordLoginFailure(c, &user, user.Email, models.LoginMethodTwoFactor, loginFailureInvalidTwoFactor)
+		}
+		respondSecondFactorError(c, err)
+		return
+	}
+
+	finishLogin(c, &user, models.LoginMethodTwoFactor)
+}
+
+// ResetUserTwoFactor godoc
+// @Summary Reset two-factor authentication of a user (Admin only)
+// @Description Turn off two-factor authentication and delete the recovery codes of a locked-out user
+// @Tags Admin
+// @Produce json
+// @Security BearerAuth
+// @Param uuid path string true "User UUID"
+// @Success 200 {object} models.APIResponse
+// @Failure 400 {object} models.APIResponse
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /admin/users/{uuid}/2fa [delete]
+func (ac *AuthController) ResetUserTwoFactor(c *gin.Context) {
+	userUUID, err := uuid.Parse(c.Param("uuid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+			"Invalid UUID format",
+			"INVALID_UUID",
+			nil,
+		))
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("uuid = ?", userUUID).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"User not found",
+				"USER_NOT_FOUND",
+				nil,
+			))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return
+	}
+
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return clearTwoFactor(tx, user.ID)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to reset two-factor authentication",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	adminID, _ := middleware.GetCurrentUserID(c)
+	log.Printf("Admin %d reset two-factor authentication of user %d", adminID, user.ID)
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Two-factor authentication reset",
+		nil,
+	))
+}
+
+// issueLoginChallenge creates the short-lived token exchanged for a session after the second factor
+func issueLoginChallenge(user *models.User) (models.TwoFactorChallengeResponse, error) {
+	ttl := config.AppConfig.Tokens.LoginChallengeTTL
+	token, err := issueUserToken(user.ID, models.TokenPurposeLoginChallenge, user.Email, ttl)
+	if err != nil {
+		return models.TwoFactorChallengeResponse{}, err
+	}
+
+	return models.TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresIn:         int64(ttl / time.Second),
+	}, nil
+}
+
+// verifySecondFactor accepts either a TOTP code or an unused recovery code
+func verifySecondFactor(tx *gorm.DB, user *models.User, code string) error {
+	if isTOTPCode(code) {
+		return verifyTOTP(tx, user, code)
+	}
+	return useRecoveryCode(tx, user.ID, code)
+}
+
+// verifyTOTP checks a TOTP code and records its time step so it cannot be used again
+func verifyTOTP(tx *gorm.DB, user *models.User, code string) error {
+	secret, err := utils.DecryptSecret(user.TwoFactorSecret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := utils.ValidateTOTP(secret, code, time.Now(), user.TwoFactorLastStep)
+	if !ok {
+		return errInvalidSecondFactor
+	}
+
+	result := tx.Model(&models.User{}).
+		Where("id = ? AND two_factor_last_step < ?", user.ID, step).
+		Update("two_factor_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidSecondFactor
+	}
+	user.TwoFactorLastStep = step
+
+	return nil
+}
+
+// useRecoveryCode marks a matching unused recovery code as used
+func useRecoveryCode(tx *gorm.DB, userID uint, code string) error {
+	result := tx.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidSecondFactor
+	}
+	return nil
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and returns a new set
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	records := make([]models.RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		random := make([]byte, 7)
+		if _, err := rand.Read(random); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(random))[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		records[i] = models.RecoveryCode{UserID: userID, CodeHash: utils.HashToken(raw)}
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// clearTwoFactor turns off two-factor authentication and removes the recovery codes
+func clearTwoFactor(tx *gorm.DB, userID uint) error {
+	if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"two_factor_secret":     "",
+		"two_factor_enabled_at": nil,
+		"two_factor_last_step":  0,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
+
+// requireTwoFactorEnabled writes a 400 response when the user has not enabled two-factor authentication
+func requireTwoFactorEnabled(c *gin.Context, user *models.User) bool {
+	if user.IsTwoFactorEnabled() {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, models.ErrorResponseWithCode(
+		"Two-factor authentication is not enabled",
+		"TWO_FACTOR_NOT_ENABLED",
+		nil,
+	))
+	return false
+}
+
+// respondSecondFactorError writes the response for an error from a second factor check
+func respondSecondFactorError(c *gin.Context, err error) {
+	if err == errInvalidSecondFactor {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+			"Invalid two-factor code",
+			"INVALID_TWO_FACTOR_CODE",
+			nil,
+		))
+		return
+	}
+	respondTokenError(c, err)
+}
+
+// isTOTPCode reports whether a code has the shape of a TOTP code rather than a recovery code
+func isTOTPCode(code string) bool {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != utils.TOTPDigits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeRecoveryCode strips separators and case so codes can be typed loosely
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
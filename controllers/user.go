@@ -0,0 +1,455 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"map-memories-api/database"
+	"map-memories-api/geocoding"
+	"map-memories-api/middleware"
+	"map-memories-api/models"
+	"map-memories-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UserController struct{}
+
+// GetUserProfile godoc
+// @Summary Get a user's public profile
+// @Description Get the public profile of a user by username or UUID, with counts computed from memories visible to the caller
+// @Tags Users
+// @Produce json
+// @Param username path string true "Username or user UUID"
+// @Success 200 {object} models.APIResponse{data=models.UserProfileResponse}
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /users/{username} [get]
+func (uc *UserController) GetUserProfile(c *gin.Context) {
+	user, ok := findUserByParam(c)
+	if !ok {
+		return
+	}
+
+	isOwner, ok := authorizeProfileView(c, user)
+	if !ok {
+		return
+	}
+
+	profile := user.ToProfileResponse()
+	memories := visibleUserMemories(user.ID, isOwner)
+
+	if err := memories.Session(&gorm.Session{}).Model(&models.Memory{}).Count(&profile.MemoryCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to count memories",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := database.DB.Model(&models.Location{}).
+		Where("id IN (?)", memories.Session(&gorm.Session{}).Model(&models.Memory{}).Select("location_id")).
+		Count(&profile.LocationCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to count locations",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	var countries []string
+	if err := database.DB.Model(&models.Location{}).
+		Where("id IN (?)", memories.Session(&gorm.Session{}).Model(&models.Memory{}).Select("location_id")).
+		Distinct().Pluck("country", &countries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to count countries",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+	visited := make(map[string]bool, len(countries))
+	for _, country := range countries {
+		if code := geocoding.NormalizeCountry(country); code != "" {
+			visited[code] = true
+		}
+	}
+	profile.CountryCount = int64(len(visited))
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"User profile retrieved successfully",
+		profile,
+	))
+}
+
+// GetUserMemories godoc
+// @Summary Get a user's memories
+// @Description Get the memories of a user by username or UUID. Other users only see public memories.
+// @Tags Users
+// @Produce json
+// @Param username path string true "Username or user UUID"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 20, max: 100)"
+// @Success 200 {object} models.PaginatedResponse{data=[]models.MemoryResponse}
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /users/{username}/memories [get]
+func (uc *UserController) GetUserMemories(c *gin.Context) {
+	user, ok := findUserByParam(c)
+	if !ok {
+		return
+	}
+
+	isOwner, ok := authorizeProfileView(c, user)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	offset := (page - 1) * limit
+
+	query := visibleUserMemories(user.ID, isOwner)
+
+	var total int64
+	query.Session(&gorm.Session{}).Model(&models.Memory{}).Count(&total)
+
+	var memories []models.Memory
+	if err := query.Preload("User").Preload("Location").Preload("Media").
+		Order("COALESCE(visit_date, created_at) DESC, id DESC").
+		Limit(limit).Offset(offset).Find(&memories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to fetch memories",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	viewer := middleware.GetViewer(c)
+	memoryResponses := make([]models.MemoryResponse, len(memories))
+	for i, memory := range memories {
+		memoryResponses[i] = memory.ToResponseFor(viewer)
+	}
+
+	pagination := models.CalculatePagination(page, limit, total)
+
+	c.JSON(http.StatusOK, models.PaginatedSuccessResponse(
+		"Memories retrieved successfully",
+		memoryResponses,
+		pagination,
+	))
+}
+
+// GetUserLocations godoc
+// @Summary Get the locations on a user's memory map
+// @Description Get every location where a user has memories visible to the caller, with the number of those memories, for drawing the user's map
+// @Tags Users
+// @Produce json
+// @Param username path string true "Username or user UUID"
+// @Success 200 {object} models.APIResponse{data=[]models.LocationResponse}
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /users/{username}/locations [get]
+func (uc *UserController) GetUserLocations(c *gin.Context) {
+	user, ok := findUserByParam(c)
+	if !ok {
+		return
+	}
+
+	isOwner, ok := authorizeProfileView(c, user)
+	if !ok {
+		return
+	}
+
+	var counts []struct {
+		LocationID  uint
+		MemoryCount int64
+	}
+	if err := visibleUserMemories(user.ID, isOwner).Model(&models.Memory{}).
+		Select("location_id, COUNT(*) AS memory_count").
+		Group("location_id").Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to fetch locations",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	locationResponses := make([]models.LocationResponse, 0, len(counts))
+	if len(counts) > 0 {
+		memoryCounts := make(map[uint]int64, len(counts))
+		ids := make([]uint, len(counts))
+		for i, count := range counts {
+			memoryCounts[count.LocationID] = count.MemoryCount
+			ids[i] = count.LocationID
+		}
+
+		var locations []models.Location
+		if err := database.DB.Preload("Categories").Where("id IN ?", ids).
+			Order("name ASC").Find(&locations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+				"Failed to fetch locations",
+				"INTERNAL_ERROR",
+				err.Error(),
+			))
+			return
+		}
+
+		for _, location := range locations {
+			response := location.ToResponse()
+			response.MemoryCount = memoryCounts[location.ID]
+			locationResponses = append(locationResponses, response)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"Locations retrieved successfully",
+		locationResponses,
+	))
+}
+
+// GetUserStats godoc
+// @Summary Get travel statistics for a user
+// @Description Countries and cities visited, first/last visit per country, distance travelled and memories per year/month. Other users only see statistics computed from public memories.
+// @Tags Users
+// @Produce json
+// @Param username path string true "Username or user UUID"
+// @Success 200 {object} models.APIResponse{data=models.UserTravelStats}
+// @Failure 403 {object} models.APIResponse
+// @Failure 404 {object} models.APIResponse
+// @Failure 500 {object} models.APIResponse
+// @Router /users/{username}/stats [get]
+func (uc *UserController) GetUserStats(c *gin.Context) {
+	user, ok := findUserByParam(c)
+	if !ok {
+		return
+	}
+
+	// Only the owner sees statistics that include private memories
+	isOwner, ok := authorizeProfileView(c, user)
+	if !ok {
+		return
+	}
+
+	var memories []models.Memory
+	if err := visibleUserMemories(user.ID, isOwner).Preload("Location").
+		Select("id, user_id, location_id, visit_date, created_at").
+		Order("COALESCE(visit_date, created_at) ASC, id ASC").Find(&memories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Failed to fetch memories",
+			"INTERNAL_ERROR",
+			err.Error(),
+		))
+		return
+	}
+
+	stats := computeTravelStats(memories)
+	stats.UserUUID = user.UUID
+	stats.Username = user.Username
+	stats.IncludesPrivate = isOwner
+	stats.Map.Username = user.Username
+
+	c.JSON(http.StatusOK, models.SuccessResponse(
+		"User statistics retrieved successfully",
+		stats,
+	))
+}
+
+// findUserByParam loads the user named by the :username path parameter, which may also
+// be the user's UUID, and writes the error response when it cannot be found
+func findUserByParam(c *gin.Context) (*models.User, bool) {
+	param := c.Param("username")
+
+	query := database.DB.Where("username = ?", param)
+	if userUUID, err := uuid.Parse(param); err == nil {
+		query = database.DB.Where("uuid = ?", userUUID)
+	}
+
+	var user models.User
+	if err := query.First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponseWithCode(
+				"User not found",
+				"USER_NOT_FOUND",
+				nil,
+			))
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+			"Database error",
+			"INTERNAL_ERROR",
+			nil,
+		))
+		return nil, false
+	}
+
+	return &user, true
+}
+
+// authorizeProfileView reports whether the caller owns the profile and rejects callers
+// other than the owner or an admin when the profile is private
+func authorizeProfileView(c *gin.Context, user *models.User) (bool, bool) {
+	currentUserID, authenticated := middleware.GetCurrentUserID(c)
+	isOwner := authenticated && currentUserID == user.ID
+
+	if !user.IsProfilePublic() && !isOwner && !middleware.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, models.ErrorResponseWithCode(
+			"This profile is private",
+			"PROFILE_PRIVATE",
+			nil,
+		))
+		return isOwner, false
+	}
+
+	return isOwner, true
+}
+
+// visibleUserMemories scopes memories to a user, hiding private ones from everyone but the owner
+func visibleUserMemories(userID uint, isOwner bool) *gorm.DB {
+	query := database.DB.Where("user_id = ?", userID)
+	if !isOwner {
+		query = query.Where("is_public = ?", true)
+	}
+	return query
+}
+
+// computeTravelStats aggregates memories ordered by visit time into travel statistics.
+// The visit time of a memory is its VisitDate, falling back to when it was created.
+func computeTravelStats(memories []models.Memory) models.UserTravelStats {
+	stats := models.UserTravelStats{
+		MemoryCount:      len(memories),
+		Countries:        []models.CountryVisit{},
+		Cities:           []models.CityVisit{},
+		MemoriesPerYear:  map[string]int{},
+		MemoriesPerMonth: map[string]int{},
+		Map: models.VisitedCountriesMap{
+			Countries: []string{},
+			Counts:    map[string]int{},
+		},
+	}
+
+	countries := map[string]*models.CountryVisit{}
+	cities := map[string]*models.CityVisit{}
+	citiesPerCountry := map[string]map[string]bool{}
+	locations := map[uint]bool{}
+
+	var previous *models.Location
+	for i := range memories {
+		memory := &memories[i]
+		location := &memory.Location
+
+		visitedAt := memory.CreatedAt
+		if memory.VisitDate != nil {
+			visitedAt = *memory.VisitDate
+		}
+
+		if stats.FirstVisit == nil || visitedAt.Before(*stats.FirstVisit) {
+			first := visitedAt
+			stats.FirstVisit = &first
+		}
+		if stats.LastVisit == nil || visitedAt.After(*stats.LastVisit) {
+			last := visitedAt
+			stats.LastVisit = &last
+		}
+
+		stats.MemoriesPerYear[strconv.Itoa(visitedAt.Year())]++
+		stats.MemoriesPerMonth[visitedAt.Format("2006-01")]++
+
+		if location.ID == 0 {
+			continue
+		}
+		locations[location.ID] = true
+
+		// Distance travelled between consecutive memories
+		if previous != nil && previous.ID != location.ID {
+			stats.TotalDistanceKm += utils.HaversineDistance(
+				previous.Latitude, previous.Longitude, location.Latitude, location.Longitude)
+		}
+		previous = location
+
+		countryCode := geocoding.NormalizeCountry(location.Country)
+		if countryCode == "" {
+			continue
+		}
+
+		country, ok := countries[countryCode]
+		if !ok {
+			country = &models.CountryVisit{
+				CountryCode: countryCode,
+				CountryName: geocoding.CountryName(countryCode),
+				FirstVisit:  visitedAt,
+				LastVisit:   visitedAt,
+			}
+			countries[countryCode] = country
+			citiesPerCountry[countryCode] = map[string]bool{}
+		}
+		country.MemoryCount++
+		if visitedAt.Before(country.FirstVisit) {
+			country.FirstVisit = visitedAt
+		}
+		if visitedAt.After(country.LastVisit) {
+			country.LastVisit = visitedAt
+		}
+
+		if location.City == "" {
+			continue
+		}
+		cityKey := countryCode + "|" + strings.ToLower(location.City)
+		city, ok := cities[cityKey]
+		if !ok {
+			city = &models.CityVisit{City: location.City, CountryCode: countryCode}
+			cities[cityKey] = city
+			citiesPerCountry[countryCode][cityKey] = true
+		}
+		city.MemoryCount++
+	}
+
+	for code, country := range countries {
+		country.CityCount = len(citiesPerCountry[code])
+		stats.Countries = append(stats.Countries, *country)
+		stats.Map.Countries = append(stats.Map.Countries, code)
+		stats.Map.Counts[code] = country.MemoryCount
+	}
+	for _, city := range cities {
+		stats.Cities = append(stats.Cities, *city)
+	}
+
+	// Order countries by first visit and cities by popularity for stable output
+	sort.Slice(stats.Countries, func(i, j int) bool {
+		return stats.Countries[i].FirstVisit.Before(stats.Countries[j].FirstVisit)
+	})
+	sort.Slice(stats.Cities, func(i, j int) bool {
+		if stats.Cities[i].MemoryCount != stats.Cities[j].MemoryCount {
+			return stats.Cities[i].MemoryCount > stats.Cities[j].MemoryCount
+		}
+		return stats.Cities[i].City < stats.Cities[j].City
+	})
+	sort.Strings(stats.Map.Countries)
+
+	stats.TotalDistanceKm = math.Round(stats.TotalDistanceKm*10) / 10
+	stats.LocationCount = len(locations)
+	stats.CountryCount = len(countries)
+	stats.CityCount = len(cities)
+
+	return stats
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"map-memories-api/models"
+)
+
+// visit is a memory at location, visited at visitDate or, when it is zero, created at
+// createdAt without a visit date
+func visit(location models.Location, visitDate, createdAt time.Time) models.Memory {
+	memory := models.Memory{LocationID: location.ID, Location: location, CreatedAt: createdAt}
+	if !visitDate.IsZero() {
+		memory.VisitDate = &visitDate
+	}
+	return memory
+}
+
+func TestComputeTravelStats(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 12, 0, 0, 0, time.UTC)
+	}
+	// Locations one degree of longitude (111.2 km) apart along the equator
+	hanoi := models.Location{ID: 1, Longitude: 0, City: "Hanoi", Country: "VN"}
+	hue := models.Location{ID: 2, Longitude: 1, City: "Hue", Country: "Việt Nam"}
+	hanoiAgain := models.Location{ID: 3, Longitude: 1, City: "HANOI", Country: "vn"}
+	tokyo := models.Location{ID: 4, Longitude: 2, City: "Tokyo", Country: "JP"}
+	atSea := models.Location{ID: 5, Longitude: 3}
+
+	tests := []struct {
+		name     string
+		memories []models.Memory
+		want     models.UserTravelStats
+	}{
+		{
+			name: "no memories",
+			want: models.UserTravelStats{
+				Countries:        []models.CountryVisit{},
+				Cities:           []models.CityVisit{},
+				MemoriesPerYear:  map[string]int{},
+				MemoriesPerMonth: map[string]int{},
+				Map:              models.VisitedCountriesMap{Countries: []string{}, Counts: map[string]int{}},
+			},
+		},
+		{
+			name:     "single memory",
+			memories: []models.Memory{visit(hanoi, day(2023, time.May, 1), day(2024, time.January, 1))},
+			want: models.UserTravelStats{
+				MemoryCount:   1,
+				LocationCount: 1,
+				CountryCount:  1,
+				CityCount:     1,
+				FirstVisit:    timePtr(day(2023, time.May, 1)),
+				LastVisit:     timePtr(day(2023, time.May, 1)),
+				Countries: []models.CountryVisit{
+					{CountryCode: "VN", CountryName: "Vietnam", MemoryCount: 1, CityCount: 1, FirstVisit: day(2023, time.May, 1), LastVisit: day(2023, time.May, 1)},
+				},
+				Cities:           []models.CityVisit{{City: "Hanoi", CountryCode: "VN", MemoryCount: 1}},
+				MemoriesPerYear:  map[string]int{"2023": 1},
+				MemoriesPerMonth: map[string]int{"2023-05": 1},
+				Map:              models.VisitedCountriesMap{Countries: []string{"VN"}, Counts: map[string]int{"VN": 1}},
+			},
+		},
+		{
+			name: "memories without a visit date count when they were created",
+			memories: []models.Memory{
+				visit(hanoi, time.Time{}, day(2022, time.December, 31)),
+				visit(tokyo, time.Time{}, day(2023, time.January, 2)),
+			},
+			want: models.UserTravelStats{
+				MemoryCount:     2,
+				LocationCount:   2,
+				CountryCount:    2,
+				CityCount:       2,
+				TotalDistanceKm: 222.4,
+				FirstVisit:      timePtr(day(2022, time.December, 31)),
+				LastVisit:       timePtr(day(2023, time.January, 2)),
+				Countries: []models.CountryVisit{
+					{CountryCode: "VN", CountryName: "Vietnam", MemoryCount: 1, CityCount: 1, FirstVisit: day(2022, time.December, 31), LastVisit: day(2022, time.December, 31)},
+					{CountryCode: "JP", CountryName: "Japan", MemoryCount: 1, CityCount: 1, FirstVisit: day(2023, time.January, 2), LastVisit: day(2023, time.January, 2)},
+				},
+				Cities: []models.CityVisit{
+					{City: "Hanoi", CountryCode: "VN", MemoryCount: 1},
+					{City: "Tokyo", CountryCode: "JP", MemoryCount: 1},
+				},
+				MemoriesPerYear:  map[string]int{"2022": 1, "2023": 1},
+				MemoriesPerMonth: map[string]int{"2022-12": 1, "2023-01": 1},
+				Map:              models.VisitedCountriesMap{Countries: []string{"JP", "VN"}, Counts: map[string]int{"JP": 1, "VN": 1}},
+			},
+		},
+		{
+			name: "trip across countries",
+			memories: []models.Memory{
+				visit(hanoi, day(2023, time.March, 1), day(2024, time.June, 1)),
+				// The same location twice adds no distance
+				visit(hanoi, day(2023, time.March, 2), day(2024, time.June, 1)),
+				visit(hue, day(2023, time.March, 5), day(2024, time.June, 1)),
+				// Another location at the same spot; the city matches regardless of case
+				visit(hanoiAgain, day(2023, time.April, 1), day(2024, time.June, 1)),
+				visit(tokyo, time.Time{}, day(2024, time.February, 10)),
+				// Locations without a country count towards distance only
+				visit(atSea, day(2024, time.February, 11), day(2024, time.June, 1)),
+				// Memories without a location count towards the visit times only
+				visit(models.Location{}, day(2024, time.March, 1), day(2024, time.June, 1)),
+			},
+			want: models.UserTravelStats{
+				MemoryCount:     7,
+				LocationCount:   5,
+				CountryCount:    2,
+				CityCount:       3,
+				TotalDistanceKm: 333.6,
+				FirstVisit:      timePtr(day(2023, time.March, 1)),
+				LastVisit:       timePtr(day(2024, time.March, 1)),
+				Countries: []models.CountryVisit{
+					{CountryCode: "VN", CountryName: "Vietnam", MemoryCount: 4, CityCount: 2, FirstVisit: day(2023, time.March, 1), LastVisit: day(2023, time.April, 1)},
+					{CountryCode: "JP", CountryName: "Japan", MemoryCount: 1, CityCount: 1, FirstVisit: day(2024, time.February, 10), LastVisit: day(2024, time.February, 10)},
+				},
+				Cities: []models.CityVisit{
+					{City: "Hanoi", CountryCode: "VN", MemoryCount: 3},
+					{City: "Hue", CountryCode: "VN", MemoryCount: 1},
+					{City: "Tokyo", CountryCode: "JP", MemoryCount: 1},
+				},
+				MemoriesPerYear:  map[string]int{"2023": 4, "2024": 3},
+				MemoriesPerMonth: map[string]int{"2023-03": 3, "2023-04": 1, "2024-02": 2, "2024-03": 1},
+				Map:              models.VisitedCountriesMap{Countries: []string{"JP", "VN"}, Counts: map[string]int{"JP": 1, "VN": 4}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := computeTravelStats(test.memories)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("computeTravelStats =\n%+v\nwant\n%+v", got, test.want)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
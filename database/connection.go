@@ -54,18 +54,43 @@ func Connect() {
 
 // AutoMigrate runs database migrations
 func AutoMigrate() {
+	// Accounts created before email verification existed are treated as verified
+	grandfatherEmails := !DB.Migrator().HasColumn(&models.User{}, "email_verified_at") &&
+		DB.Migrator().HasTable(&models.User{})
+
 	err := DB.AutoMigrate(
 		&models.User{},
+		&models.Category{},
 		&models.Location{},
 		&models.Memory{},
+		&models.MediaBlob{},
 		&models.Media{},
 		&models.UserSession{},
 		&models.MemoryLike{},
+		&models.LocationEditSuggestion{},
+		&models.LocationAuditLog{},
+		&models.UserToken{},
+		&models.RecoveryCode{},
+		&models.UserIdentity{},
+		&models.OAuthState{},
+		&models.LoginEvent{},
+		&models.LoginThrottle{},
+		&models.APIToken{},
+		&models.UploadSession{},
+		&models.Job{},
 	)
 	
 	if err != nil {
 		log.Fatal("Failed to run database migrations:", err)
 	}
+
+	if grandfatherEmails {
+		if err := DB.Exec("UPDATE mm_users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error; err != nil {
+			log.Printf("Failed to mark existing users as verified: %v", err)
+		}
+	}
+
+	createSpatialIndexes()
 	
 	log.Println("Database migrations completed successfully")
 }
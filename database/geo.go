@@ -0,0 +1,39 @@
+package database
+
+import (
+	"log"
+)
+
+// BoundaryGeometrySQL converts a location's GeoJSON boundary to a PostGIS geometry
+const BoundaryGeometrySQL = "ST_SetSRID(ST_GeomFromGeoJSON(boundary::text), 4326)"
+
+// LocationGeometrySQL is a location's boundary when it has one, otherwise its point
+const LocationGeometrySQL = "COALESCE(" + BoundaryGeometrySQL + ", ST_SetSRID(ST_MakePoint(longitude, latitude), 4326))"
+
+// BoundaryInvalidReason asks PostGIS whether a GeoJSON boundary is a valid geometry and
+// returns why not, e.g. "Self-intersection[30.5 10.2]"; valid boundaries give "". Rings
+// that cross themselves pass the structural checks of utils.NormalizeBoundary but make
+// ST_Covers and ST_Area return wrong results or fail.
+func BoundaryInvalidReason(boundary []byte) (string, error) {
+	var reason string
+	err := DB.Raw("SELECT CASE WHEN ST_IsValid(geom) THEN '' ELSE ST_IsValidReason(geom) END "+
+		"FROM (SELECT ST_SetSRID(ST_GeomFromGeoJSON(?), 4326) AS geom) AS boundary", string(boundary)).
+		Scan(&reason).Error
+	return reason, err
+}
+
+// createSpatialIndexes creates PostGIS indexes used by area-based location queries
+func createSpatialIndexes() {
+	statements := []string{
+		"CREATE INDEX IF NOT EXISTS idx_mm_locations_boundary ON mm_locations USING GIST ((" +
+			BoundaryGeometrySQL + ")) WHERE boundary IS NOT NULL",
+		"CREATE INDEX IF NOT EXISTS idx_mm_locations_geography ON mm_locations USING GIST (((" +
+			LocationGeometrySQL + ")::geography))",
+	}
+
+	for _, statement := range statements {
+		if err := DB.Exec(statement).Error; err != nil {
+			log.Printf("Failed to create spatial index (is PostGIS installed?): %v", err)
+		}
+	}
+}
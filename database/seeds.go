@@ -2,6 +2,7 @@ package database
 
 import (
 	"log"
+	"time"
 
 	"map-memories-api/models"
 	"map-memories-api/utils"
@@ -13,6 +14,9 @@ func SeedData() {
 	
 	// Seed admin user
 	seedAdminUser()
+
+	// Seed location categories
+	seedCategories()
 	
 	log.Println("Database seeding completed successfully")
 }
@@ -24,6 +28,12 @@ func seedAdminUser() {
 	result := DB.Where("username = ? OR email = ?", "admin", "admin@map-memories.com").First(&existingUser)
 	
 	if result.Error == nil {
+		// Make sure admins created before roles existed get the admin role
+		if existingUser.Role != models.RoleAdmin {
+			DB.Model(&existingUser).Update("role", models.RoleAdmin)
+			log.Println("Admin user already exists, granted admin role")
+			return
+		}
 		log.Println("Admin user already exists, skipping...")
 		return
 	}
@@ -36,11 +46,14 @@ func seedAdminUser() {
 	}
 	
 	// Create admin user
+	now := time.Now()
 	adminUser := models.User{
-		Username:     "admin",
-		Email:        "admin@map-memories.com",
-		PasswordHash: hashedPassword,
-		FullName:     "Administrator",
+		Username:        "admin",
+		Email:           "admin@map-memories.com",
+		PasswordHash:    hashedPassword,
+		FullName:        "Administrator",
+		Role:            models.RoleAdmin,
+		EmailVerifiedAt: &now,
 	}
 	
 	// Save to database
@@ -50,4 +63,105 @@ func seedAdminUser() {
 	}
 	
 	log.Printf("Admin user created successfully with ID: %d", adminUser.ID)
-} 
\ No newline at end of file
+}
+
+// categorySeed describes a top-level category and its children
+type categorySeed struct {
+	Slug     string
+	Name     string
+	Icon     string
+	Children []categorySeed
+}
+
+// defaultCategories is the built-in point-of-interest taxonomy
+var defaultCategories = []categorySeed{
+	{Slug: "food-drink", Name: "Food & Drink", Icon: "🍽️", Children: []categorySeed{
+		{Slug: "restaurant", Name: "Restaurant", Icon: "🍜"},
+		{Slug: "cafe", Name: "Cafe", Icon: "☕"},
+		{Slug: "bar", Name: "Bar", Icon: "🍺"},
+		{Slug: "street-food", Name: "Street Food", Icon: "🥢"},
+	}},
+	{Slug: "nature", Name: "Nature", Icon: "🌿", Children: []categorySeed{
+		{Slug: "beach", Name: "Beach", Icon: "🏖️"},
+		{Slug: "park", Name: "Park", Icon: "🌳"},
+		{Slug: "mountain", Name: "Mountain", Icon: "⛰️"},
+		{Slug: "lake", Name: "Lake & River", Icon: "🏞️"},
+		{Slug: "waterfall", Name: "Waterfall", Icon: "💧"},
+		{Slug: "island", Name: "Island", Icon: "🏝️"},
+	}},
+	{Slug: "culture", Name: "Culture", Icon: "🏛️", Children: []categorySeed{
+		{Slug: "museum", Name: "Museum", Icon: "🖼️"},
+		{Slug: "religious-site", Name: "Temple & Church", Icon: "⛩️"},
+		{Slug: "historic-site", Name: "Historic Site", Icon: "🏯"},
+		{Slug: "landmark", Name: "Landmark", Icon: "📍"},
+	}},
+	{Slug: "entertainment", Name: "Entertainment", Icon: "🎡", Children: []categorySeed{
+		{Slug: "viewpoint", Name: "Viewpoint", Icon: "🌄"},
+		{Slug: "amusement-park", Name: "Amusement Park", Icon: "🎢"},
+		{Slug: "nightlife", Name: "Nightlife", Icon: "🌃"},
+	}},
+	{Slug: "shopping", Name: "Shopping", Icon: "🛍️", Children: []categorySeed{
+		{Slug: "market", Name: "Market", Icon: "🧺"},
+		{Slug: "mall", Name: "Shopping Mall", Icon: "🏬"},
+	}},
+	{Slug: "accommodation", Name: "Accommodation", Icon: "🏨", Children: []categorySeed{
+		{Slug: "hotel", Name: "Hotel", Icon: "🏨"},
+		{Slug: "homestay", Name: "Homestay", Icon: "🏡"},
+		{Slug: "campsite", Name: "Campsite", Icon: "🏕️"},
+	}},
+	{Slug: "transport", Name: "Transport", Icon: "🚉", Children: []categorySeed{
+		{Slug: "airport", Name: "Airport", Icon: "✈️"},
+		{Slug: "station", Name: "Station", Icon: "🚉"},
+	}},
+	{Slug: "other", Name: "Other", Icon: "📌"},
+}
+
+// seedCategories creates the default location categories that do not exist yet
+func seedCategories() {
+	created := 0
+
+	for i, seed := range defaultCategories {
+		parent, isNew, err := ensureCategory(seed, nil, i)
+		if err != nil {
+			log.Printf("Error creating category %s: %v", seed.Slug, err)
+			continue
+		}
+		if isNew {
+			created++
+		}
+
+		for j, childSeed := range seed.Children {
+			_, isNew, err := ensureCategory(childSeed, &parent.ID, j)
+			if err != nil {
+				log.Printf("Error creating category %s: %v", childSeed.Slug, err)
+				continue
+			}
+			if isNew {
+				created++
+			}
+		}
+	}
+
+	log.Printf("Category seeding completed, %d categories created", created)
+}
+
+// ensureCategory returns the category with the seed's slug, creating it when missing
+func ensureCategory(seed categorySeed, parentID *uint, order int) (*models.Category, bool, error) {
+	var category models.Category
+	if err := DB.Where("slug = ?", seed.Slug).First(&category).Error; err == nil {
+		return &category, false, nil
+	}
+
+	category = models.Category{
+		Slug:         seed.Slug,
+		Name:         seed.Name,
+		Icon:         seed.Icon,
+		ParentID:     parentID,
+		DisplayOrder: order,
+	}
+	if err := DB.Create(&category).Error; err != nil {
+		return nil, false, err
+	}
+
+	return &category, true, nil
+}
@@ -0,0 +1,99 @@
+package geocoding
+
+import (
+	"strings"
+)
+
+// countryNames maps ISO 3166-1 alpha-2 codes to English country names
+var countryNames = map[string]string{
+	"AE": "United Arab Emirates", "AF": "Afghanistan", "AM": "Armenia", "AO": "Angola",
+	"AR": "Argentina", "AT": "Austria", "AU": "Australia", "AZ": "Azerbaijan",
+	"BA": "Bosnia and Herzegovina", "BD": "Bangladesh", "BE": "Belgium", "BG": "Bulgaria",
+	"BN": "Brunei", "BO": "Bolivia", "BR": "Brazil", "BY": "Belarus",
+	"CA": "Canada", "CD": "DR Congo", "CH": "Switzerland", "CL": "Chile",
+	"CN": "China", "CO": "Colombia", "CR": "Costa Rica", "CU": "Cuba",
+	"CY": "Cyprus", "CZ": "Czechia", "DE": "Germany", "DK": "Denmark",
+	"DO": "Dominican Republic", "DZ": "Algeria", "EC": "Ecuador", "EE": "Estonia",
+	"EG": "Egypt", "ES": "Spain", "ET": "Ethiopia", "FI": "Finland",
+	"FJ": "Fiji", "FR": "France", "GB": "United Kingdom", "GE": "Georgia",
+	"GH": "Ghana", "GR": "Greece", "GT": "Guatemala", "HK": "Hong Kong",
+	"HR": "Croatia", "HU": "Hungary", "ID": "Indonesia", "IE": "Ireland",
+	"IL": "Israel", "IN": "India", "IQ": "Iraq", "IR": "Iran",
+	"IS": "Iceland", "IT": "Italy", "JM": "Jamaica", "JO": "Jordan",
+	"JP": "Japan", "KE": "Kenya", "KH": "Cambodia", "KP": "North Korea",
+	"KR": "South Korea", "KW": "Kuwait", "KZ": "Kazakhstan", "LA": "Laos",
+	"LB": "Lebanon", "LK": "Sri Lanka", "LT": "Lithuania", "LU": "Luxembourg",
+	"LV": "Latvia", "MA": "Morocco", "MC": "Monaco", "MG": "Madagascar",
+	"MM": "Myanmar", "MN": "Mongolia", "MO": "Macao", "MT": "Malta",
+	"MU": "Mauritius", "MV": "Maldives", "MX": "Mexico", "MY": "Malaysia",
+	"NC": "New Caledonia", "NG": "Nigeria", "NL": "Netherlands", "NO": "Norway",
+	"NP": "Nepal", "NZ": "New Zealand", "OM": "Oman", "PA": "Panama",
+	"PE": "Peru", "PF": "French Polynesia", "PG": "Papua New Guinea", "PH": "Philippines",
+	"PK": "Pakistan", "PL": "Poland", "PR": "Puerto Rico", "PT": "Portugal",
+	"PY": "Paraguay", "QA": "Qatar", "RO": "Romania", "RS": "Serbia",
+	"RU": "Russia", "RW": "Rwanda", "SA": "Saudi Arabia", "SE": "Sweden",
+	"SG": "Singapore", "SI": "Slovenia", "SK": "Slovakia", "SN": "Senegal",
+	"SY": "Syria", "TH": "Thailand", "TL": "Timor-Leste", "TN": "Tunisia",
+	"TR": "Turkey", "TW": "Taiwan", "TZ": "Tanzania", "UA": "Ukraine",
+	"UG": "Uganda", "US": "United States", "UY": "Uruguay", "UZ": "Uzbekistan",
+	"VE": "Venezuela", "VN": "Vietnam", "ZA": "South Africa", "ZM": "Zambia",
+	"ZW": "Zimbabwe",
+}
+
+// countryAliases maps common spellings that are not the English name to ISO codes
+var countryAliases = map[string]string{
+	"việt nam":                 "VN",
+	"viet nam":                 "VN",
+	"usa":                      "US",
+	"united states of america": "US",
+	"america":                  "US",
+	"uk":                       "GB",
+	"great britain":            "GB",
+	"england":                  "GB",
+	"south korea":              "KR",
+	"korea":                    "KR",
+	"republic of korea":        "KR",
+	"czech republic":           "CZ",
+	"holland":                  "NL",
+	"türkiye":                  "TR",
+	"burma":                    "MM",
+	"lào":                      "LA",
+	"campuchia":                "KH",
+	"thái lan":                 "TH",
+	"trung quốc":               "CN",
+	"nhật bản":                 "JP",
+	"hàn quốc":                 "KR",
+}
+
+// CountryName returns the English name for an ISO 3166-1 alpha-2 code
+func CountryName(code string) string {
+	return countryNames[strings.ToUpper(code)]
+}
+
+// NormalizeCountry converts a free-form country value ("Vietnam", "Việt Nam", "vn")
+// to its ISO 3166-1 alpha-2 code. Unknown values are returned unchanged.
+func NormalizeCountry(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return ""
+	}
+
+	if len(trimmed) == 2 {
+		code := strings.ToUpper(trimmed)
+		if _, ok := countryNames[code]; ok {
+			return code
+		}
+	}
+
+	lower := strings.ToLower(trimmed)
+	if code, ok := countryAliases[lower]; ok {
+		return code
+	}
+	for code, name := range countryNames {
+		if strings.EqualFold(name, trimmed) {
+			return code
+		}
+	}
+
+	return trimmed
+}
@@ -0,0 +1,113 @@
+package geocoding
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Download fetches a GeoNames dump (e.g. cities15000.zip, places with more than 15000
+// inhabitants) from url and writes its cities file to dest. Zip archives are extracted;
+// the file is replaced atomically so a running server never reads a partial dataset.
+// GeoNames data (https://www.geonames.org) is licensed under Creative Commons
+// Attribution 4.0.
+func Download(ctx context.Context, url, dest string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".geonames-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if _, err := io.Copy(tmp, resp.Body); err != nil {
+		return err
+	}
+
+	var source io.Reader = tmp
+	if strings.HasSuffix(strings.ToLower(path.Base(req.URL.Path)), ".zip") {
+		info, err := tmp.Stat()
+		if err != nil {
+			return err
+		}
+		archive, err := zip.NewReader(tmp, info.Size())
+		if err != nil {
+			return fmt.Errorf("download %s: %w", url, err)
+		}
+		entry, err := citiesEntry(archive)
+		if err != nil {
+			return fmt.Errorf("download %s: %w", url, err)
+		}
+		content, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+		source = content
+	} else if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(dest), ".geonames-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	if _, err := io.Copy(out, source); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	// Refuse to replace a working dataset with something that does not parse
+	check, err := os.Open(out.Name())
+	if err != nil {
+		return err
+	}
+	places, err := parseGeoNames(check)
+	check.Close()
+	if err != nil {
+		return err
+	}
+	if len(places) == 0 {
+		return fmt.Errorf("download %s: dataset contains no places", url)
+	}
+
+	return os.Rename(out.Name(), dest)
+}
+
+// citiesEntry finds the cities file of a GeoNames zip archive
+func citiesEntry(archive *zip.Reader) (*zip.File, error) {
+	for _, file := range archive.File {
+		if strings.HasSuffix(file.Name, ".txt") && !strings.Contains(file.Name, "/") {
+			return file, nil
+		}
+	}
+	return nil, errors.New("archive contains no .txt file")
+}
@@ -0,0 +1,98 @@
+package geocoding
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"map-memories-api/config"
+	"map-memories-api/models"
+)
+
+// ErrNoResult is returned when no place is known near the given coordinates
+var ErrNoResult = errors.New("no place found near coordinates")
+
+// Result represents a reverse geocoding match
+type Result struct {
+	City        string
+	Region      string
+	CountryCode string
+	DistanceKm  float64
+}
+
+// Geocoder resolves coordinates to a city and country
+type Geocoder interface {
+	ReverseGeocode(latitude, longitude float64) (*Result, error)
+}
+
+// noopGeocoder is used when geocoding is disabled
+type noopGeocoder struct{}
+
+func (noopGeocoder) ReverseGeocode(latitude, longitude float64) (*Result, error) {
+	return nil, ErrNoResult
+}
+
+// Default is the geocoder used by controllers and commands
+var Default Geocoder = noopGeocoder{}
+
+// Init configures the default geocoder from the application configuration
+func Init() error {
+	cfg := config.AppConfig.Geocoding
+
+	switch strings.ToLower(cfg.Provider) {
+	case "", "none", "disabled":
+		Default = noopGeocoder{}
+		log.Println("Reverse geocoding disabled")
+		return nil
+	case "offline":
+		geocoder, err := NewOfflineGeocoder(cfg.DataPath, cfg.MaxDistanceKm)
+		if errors.Is(err, ErrNoDataset) {
+			// Locations are still saved, just without city and country
+			Default = noopGeocoder{}
+			log.Printf("Reverse geocoding disabled: %s not found, download it with go run ./cmd/geocode -download", cfg.DataPath)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		Default = geocoder
+		log.Printf("Offline reverse geocoder loaded with %d places (GeoNames, CC BY 4.0)", geocoder.Len())
+		return nil
+	default:
+		return fmt.Errorf("unknown geocoding provider: %s", cfg.Provider)
+	}
+}
+
+// FillLocation resolves the location's coordinates and fills City, Country and Address.
+// The resolved ISO country code always replaces the stored country so values stay
+// consistent; City and Address are only filled when empty unless overwrite is set.
+func FillLocation(location *models.Location, overwrite bool) error {
+	location.Country = NormalizeCountry(location.Country)
+
+	result, err := Default.ReverseGeocode(location.Latitude, location.Longitude)
+	if err != nil {
+		return err
+	}
+
+	location.Country = result.CountryCode
+	if overwrite || location.City == "" {
+		location.City = result.City
+	}
+	if overwrite || location.Address == "" {
+		location.Address = formatAddress(result)
+	}
+
+	return nil
+}
+
+// formatAddress builds a short human-readable address from a geocoding result
+func formatAddress(result *Result) string {
+	parts := []string{result.City}
+	if name := CountryName(result.CountryCode); name != "" {
+		parts = append(parts, name)
+	} else {
+		parts = append(parts, result.CountryCode)
+	}
+	return strings.Join(parts, ", ")
+}
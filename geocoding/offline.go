@@ -0,0 +1,187 @@
+package geocoding
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"map-memories-api/utils"
+)
+
+// ErrNoDataset is returned by NewOfflineGeocoder when the dataset has not been downloaded
+var ErrNoDataset = errors.New("geocoding dataset not found")
+
+// place is a single populated place from the dataset
+type place struct {
+	name        string
+	region      string
+	countryCode string
+	latitude    float64
+	longitude   float64
+}
+
+// OfflineGeocoder resolves coordinates to the nearest populated place in a GeoNames dataset
+type OfflineGeocoder struct {
+	places        []place
+	maxDistanceKm float64
+	// cells buckets place indexes by whole-degree latitude/longitude to avoid full scans
+	cells map[[2]int][]int
+}
+
+// NewOfflineGeocoder loads a GeoNames "cities" dataset from dataPath (see Download). A
+// missing file is reported as ErrNoDataset.
+func NewOfflineGeocoder(dataPath string, maxDistanceKm float64) (*OfflineGeocoder, error) {
+	if dataPath == "" {
+		return nil, ErrNoDataset
+	}
+	file, err := os.Open(dataPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoDataset
+		}
+		return nil, fmt.Errorf("failed to open geocoding dataset: %w", err)
+	}
+	defer file.Close()
+
+	places, err := parseGeoNames(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, fmt.Errorf("geocoding dataset contains no places")
+	}
+
+	if maxDistanceKm <= 0 {
+		maxDistanceKm = 100
+	}
+
+	g := &OfflineGeocoder{
+		places:        places,
+		maxDistanceKm: maxDistanceKm,
+		cells:         make(map[[2]int][]int),
+	}
+	for i, p := range places {
+		key := cellKey(p.latitude, p.longitude)
+		g.cells[key] = append(g.cells[key], i)
+	}
+
+	return g, nil
+}
+
+// Len returns the number of places loaded
+func (g *OfflineGeocoder) Len() int {
+	return len(g.places)
+}
+
+// ReverseGeocode returns the nearest place within the configured maximum distance
+func (g *OfflineGeocoder) ReverseGeocode(latitude, longitude float64) (*Result, error) {
+	if !utils.IsValidLatitude(latitude) || !utils.IsValidLongitude(longitude) {
+		return nil, fmt.Errorf("invalid coordinates: %f, %f", latitude, longitude)
+	}
+
+	// Degrees of latitude to search; longitude cells shrink towards the poles
+	latSpan := int(math.Ceil(g.maxDistanceKm/111.0)) + 1
+	lngSpan := latSpan
+	if cos := math.Cos(latitude * math.Pi / 180); cos > 0.01 {
+		lngSpan = int(math.Ceil(g.maxDistanceKm/(111.0*cos))) + 1
+	}
+	if lngSpan > 180 {
+		lngSpan = 180
+	}
+
+	center := cellKey(latitude, longitude)
+	best := -1
+	bestDistance := math.MaxFloat64
+
+	for dLat := -latSpan; dLat <= latSpan; dLat++ {
+		for dLng := -lngSpan; dLng <= lngSpan; dLng++ {
+			lng := center[1] + dLng
+			// Wrap around the antimeridian
+			if lng < -180 {
+				lng += 360
+			} else if lng >= 180 {
+				lng -= 360
+			}
+			for _, i := range g.cells[[2]int{center[0] + dLat, lng}] {
+				p := g.places[i]
+				distance := utils.HaversineDistance(latitude, longitude, p.latitude, p.longitude)
+				if distance < bestDistance {
+					best = i
+					bestDistance = distance
+				}
+			}
+		}
+	}
+
+	if best < 0 || bestDistance > g.maxDistanceKm {
+		return nil, ErrNoResult
+	}
+
+	p := g.places[best]
+	return &Result{
+		City:        p.name,
+		Region:      p.region,
+		CountryCode: p.countryCode,
+		DistanceKm:  bestDistance,
+	}, nil
+}
+
+// cellKey returns the whole-degree grid cell containing the coordinates
+func cellKey(latitude, longitude float64) [2]int {
+	return [2]int{int(math.Floor(latitude)), int(math.Floor(longitude))}
+}
+
+// parseGeoNames parses the tab-separated GeoNames "cities" export format
+func parseGeoNames(r io.Reader) ([]place, error) {
+	var places []place
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) < 11 {
+			return nil, fmt.Errorf("geocoding dataset line %d: expected at least 11 columns, got %d", line, len(fields))
+		}
+
+		latitude, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("geocoding dataset line %d: invalid latitude: %w", line, err)
+		}
+		longitude, err := strconv.ParseFloat(fields[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("geocoding dataset line %d: invalid longitude: %w", line, err)
+		}
+
+		// Only keep populated places (feature class P)
+		if fields[6] != "" && fields[6] != "P" {
+			continue
+		}
+
+		places = append(places, place{
+			name:        fields[1],
+			region:      fields[10],
+			countryCode: strings.ToUpper(fields[8]),
+			latitude:    latitude,
+			longitude:   longitude,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read geocoding dataset: %w", err)
+	}
+
+	return places, nil
+}
@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"log"
+	"time"
+
+	"map-memories-api/database"
+	"map-memories-api/models"
+
+	"gorm.io/gorm"
+)
+
+// maintenanceInterval is how often stale jobs are taken back and old jobs removed
+const maintenanceInterval = time.Minute
+
+// staleGrace is how long past the job timeout a running job is given before its worker
+// is considered gone
+const staleGrace = time.Minute
+
+// maintain periodically requeues jobs of workers that died and prunes succeeded jobs
+func (p *pool) maintain() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			if err := p.requeueStale(now); err != nil {
+				log.Printf("Jobs: failed to requeue stale jobs: %v", err)
+			}
+			if p.cfg.Retention > 0 {
+				if err := database.DB.Where("status = ? AND finished_at < ?", models.JobSucceeded, now.Add(-p.cfg.Retention)).
+					Delete(&models.Job{}).Error; err != nil {
+					log.Printf("Jobs: failed to prune finished jobs: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// requeueStale takes back jobs that ran far longer than the timeout, which happens when
+// an instance is killed while running them. Jobs out of attempts become dead.
+func (p *pool) requeueStale(now time.Time) error {
+	cutoff := now.Add(-(p.cfg.Timeout + staleGrace))
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		stale := func() *gorm.DB {
+			return tx.Model(&models.Job{}).Where("status = ? AND locked_at < ?", models.JobRunning, cutoff)
+		}
+		if err := stale().Where("attempts >= max_attempts").Updates(map[string]interface{}{
+			"status":      models.JobDead,
+			"finished_at": now,
+			"last_error":  "worker stopped while running the job",
+			"locked_by":   "",
+			"locked_at":   nil,
+		}).Error; err != nil {
+			return err
+		}
+
+		result := stale().Updates(map[string]interface{}{
+			"status":     models.JobQueued,
+			"run_at":     now,
+			"last_error": "worker stopped while running the job",
+			"locked_by":  "",
+			"locked_at":  nil,
+		})
+		if result.RowsAffected > 0 {
+			log.Printf("Jobs: requeued %d jobs of stopped workers", result.RowsAffected)
+		}
+		return result.Error
+	})
+}
+
+// schedule queues a job of jobType at start and then every interval, so that a daily
+// job still runs on instances restarted more often than that
+func (p *pool) schedule(jobType string, interval time.Duration) {
+	defer p.wg.Done()
+	enqueue := func(now time.Time) {
+		if err := enqueueScheduled(jobType, interval, now); err != nil {
+			log.Printf("Jobs: failed to schedule %s: %v", jobType, err)
+		}
+	}
+
+	enqueue(time.Now())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			enqueue(now)
+		}
+	}
+}
+
+// enqueueScheduled queues a scheduled job unless one is pending or, since every instance
+// runs the schedules, another instance queued one during this interval already
+func enqueueScheduled(jobType string, interval time.Duration, now time.Time) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "job-schedule:"+jobType).Error; err != nil {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&models.Job{}).
+			Where("type = ? AND (status IN ? OR created_at > ?)",
+				jobType, []string{models.JobQueued, models.JobRunning}, now.Add(-interval/2)).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		_, err := EnqueueAt(tx, jobType, nil, now)
+		return err
+	})
+}
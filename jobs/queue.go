@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"map-memories-api/config"
+	"map-memories-api/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotRetryable is returned by Retry for jobs that are running or succeeded
+var ErrNotRetryable = errors.New("only queued and dead jobs can be retried")
+
+// Handler runs one job. A returned error retries the job after a backoff, unless it is
+// wrapped with Permanent. The context is cancelled when the job times out or the
+// server shuts down.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// permanentError marks failures retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps an error so the job is moved to the dead state without further retries
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+var (
+	mu        sync.RWMutex
+	handlers  = map[string]Handler{}
+	schedules = map[string]time.Duration{}
+	// wakeup tells idle workers of this instance that a job was queued
+	wakeup = make(chan struct{}, 1)
+)
+
+// Register sets the handler of a job type; only registered types are run by the workers
+// of this instance
+func Register(jobType string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[jobType] = handler
+}
+
+// Every queues a job of a registered type when the workers start and then every interval;
+// no job is queued while the previous one is still queued or running. A zero interval
+// disables the schedule.
+func Every(jobType string, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	schedules[jobType] = interval
+}
+
+// Enqueue queues a job to run as soon as a worker is free. Passing a transaction
+// queues the job only if the transaction commits.
+func Enqueue(tx *gorm.DB, jobType string, payload interface{}) (*models.Job, error) {
+	return EnqueueAt(tx, jobType, payload, time.Now())
+}
+
+// EnqueueAt queues a job to run at runAt or later
+func EnqueueAt(tx *gorm.DB, jobType string, payload interface{}, runAt time.Time) (*models.Job, error) {
+	data := []byte("{}")
+	if payload != nil {
+		var err error
+		if data, err = json.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	job := &models.Job{
+		Type:        jobType,
+		Payload:     string(data),
+		Status:      models.JobQueued,
+		RunAt:       runAt,
+		MaxAttempts: max(config.AppConfig.Jobs.MaxAttempts, 1),
+	}
+	if err := tx.Create(job).Error; err != nil {
+		return nil, err
+	}
+
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Retry queues a dead job again with a fresh set of attempts, or runs a queued job that
+// waits for its backoff right away
+func Retry(tx *gorm.DB, job *models.Job) error {
+	if job.Status != models.JobQueued && job.Status != models.JobDead {
+		return ErrNotRetryable
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":      models.JobQueued,
+		"run_at":      now,
+		"finished_at": nil,
+	}
+	if job.Status == models.JobDead {
+		updates["attempts"] = 0
+	}
+	result := tx.Model(&models.Job{}).
+		Where("id = ? AND status = ?", job.ID, job.Status).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// A worker picked the job up in the meantime
+		return ErrNotRetryable
+	}
+
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+	return tx.First(job, job.ID).Error
+}
+
+// registeredTypes lists the job types this instance can run
+func registeredTypes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	types := make([]string, 0, len(handlers))
+	for jobType := range handlers {
+		types = append(types, jobType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func handlerFor(jobType string) Handler {
+	mu.RLock()
+	defer mu.RUnlock()
+	return handlers[jobType]
+}
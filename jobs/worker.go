@@ -0,0 +1,243 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// pool is the set of workers of this instance
+type pool struct {
+	cfg  config.JobsConfig
+	stop chan struct{} // closed to stop claiming jobs
+	// ctx is cancelled when running jobs have to give up, after the drain timeout
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+var running *pool
+
+// Start runs the workers, the schedules registered with Every and the maintenance of
+// the queue. Handlers and schedules have to be registered before.
+func Start(cfg config.JobsConfig) {
+	if cfg.Workers <= 0 {
+		log.Println("Jobs: no workers on this instance")
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &pool{cfg: cfg, stop: make(chan struct{}), ctx: ctx, cancel: cancel}
+	hostname, _ := os.Hostname()
+	for i := 0; i < cfg.Workers; i++ {
+		p.wg.Add(1)
+		go p.work(fmt.Sprintf("%s:%d:%d", hostname, os.Getpid(), i))
+	}
+
+	mu.RLock()
+	for jobType, interval := range schedules {
+		p.wg.Add(1)
+		go p.schedule(jobType, interval)
+	}
+	mu.RUnlock()
+
+	p.wg.Add(1)
+	go p.maintain()
+
+	running = p
+	log.Printf("Jobs: %d workers running %v", cfg.Workers, registeredTypes())
+}
+
+// Shutdown stops claiming jobs and waits for running jobs to finish. Jobs still running
+// when ctx ends are cancelled and queued again without using up an attempt.
+func Shutdown(ctx context.Context) error {
+	p := running
+	if p == nil {
+		return nil
+	}
+	close(p.stop)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		p.cancel()
+		return nil
+	case <-ctx.Done():
+		p.cancel()
+		// Cancelled handlers return promptly; give them a moment to record it
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+		}
+		return ctx.Err()
+	}
+}
+
+// work claims and runs jobs until the pool stops
+func (p *pool) work(workerID string) {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		job, err := claim(workerID)
+		if err != nil {
+			log.Printf("Jobs: failed to claim a job: %v", err)
+		}
+		if job == nil {
+			select {
+			case <-p.stop:
+				return
+			case <-wakeup:
+			case <-time.After(p.cfg.PollInterval):
+			}
+			continue
+		}
+
+		p.run(job, workerID)
+	}
+}
+
+// claim takes the next due job of a registered type; SKIP LOCKED lets concurrent workers,
+// on any instance, pass over jobs another worker is claiming
+func claim(workerID string) (*models.Job, error) {
+	types := registeredTypes()
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	var job models.Job
+	found := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND run_at <= ? AND type IN ?", models.JobQueued, now, types).
+			Order("run_at, id").Take(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		job.Status = models.JobRunning
+		job.Attempts++
+		job.LockedBy = workerID
+		job.LockedAt = &now
+		found = true
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":    job.Status,
+			"attempts":  job.Attempts,
+			"locked_by": job.LockedBy,
+			"locked_at": job.LockedAt,
+		}).Error
+	})
+	if err != nil || !found {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// run executes a claimed job and records the outcome
+func (p *pool) run(job *models.Job, workerID string) {
+	ctx, cancel := context.WithTimeout(p.ctx, p.cfg.Timeout)
+	defer cancel()
+
+	started := time.Now()
+	err := call(ctx, handlerFor(job.Type), json.RawMessage(job.Payload))
+	if err != nil {
+		log.Printf("Jobs: %s %s failed (attempt %d of %d) after %s: %v",
+			job.Type, job.UUID, job.Attempts, job.MaxAttempts, time.Since(started).Round(time.Millisecond), err)
+	}
+
+	if err := p.finish(job, workerID, err); err != nil {
+		log.Printf("Jobs: failed to record the result of %s %s: %v", job.Type, job.UUID, err)
+	}
+}
+
+// call runs a handler, turning panics into permanent errors
+func call(ctx context.Context, handler Handler, payload json.RawMessage) (err error) {
+	if handler == nil {
+		return errors.New("no handler registered")
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = Permanent(fmt.Errorf("panic: %v\n%s", r, debug.Stack()))
+		}
+	}()
+	return handler(ctx, payload)
+}
+
+// finish records the outcome of a run: success, a retry after a backoff, or the dead
+// state once the attempts are used up
+func (p *pool) finish(job *models.Job, workerID string, err error) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"locked_by": "",
+		"locked_at": nil,
+	}
+
+	var permanent *permanentError
+	switch {
+	case err == nil:
+		updates["status"] = models.JobSucceeded
+		updates["finished_at"] = now
+		updates["last_error"] = ""
+	case p.ctx.Err() != nil:
+		// Interrupted by the shutdown; the attempt does not count
+		updates["status"] = models.JobQueued
+		updates["run_at"] = now
+		updates["attempts"] = job.Attempts - 1
+	case errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts:
+		updates["status"] = models.JobDead
+		updates["finished_at"] = now
+		updates["last_error"] = err.Error()
+	default:
+		updates["status"] = models.JobQueued
+		updates["run_at"] = now.Add(backoff(p.cfg, job.Attempts))
+		updates["last_error"] = err.Error()
+	}
+
+	// A job the maintenance took back from this worker is not touched
+	return database.DB.Model(&models.Job{}).
+		Where("id = ? AND status = ? AND locked_by = ?", job.ID, models.JobRunning, workerID).
+		Updates(updates).Error
+}
+
+// backoff returns the delay before retry number attempt: the base delay doubled for
+// every earlier attempt, capped, with up to 20% jitter so failed jobs spread out
+func backoff(cfg config.JobsConfig, attempt int) time.Duration {
+	delay := cfg.BackoffBase
+	for i := 1; i < attempt && delay < cfg.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > cfg.BackoffMax {
+		delay = cfg.BackoffMax
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
@@ -0,0 +1,77 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LogMailer writes emails to the application log, for local development
+type LogMailer struct{}
+
+// NewLogMailer creates a mailer that logs emails
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+// Send implements Mailer
+func (m *LogMailer) Send(message Message) error {
+	log.Printf("Email to %s: %s\n%s", message.To, message.Subject, message.Body)
+	return nil
+}
+
+// FileMailer writes every email to its own .eml file and keeps the sent messages in
+// memory, for local development and tests
+type FileMailer struct {
+	dir  string
+	from string
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+// NewFileMailer creates a mailer writing into dir, creating it when needed
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("MAIL_OUTPUT_DIR is required for the file mail driver")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create mail output directory: %w", err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+// Send implements Mailer
+func (m *FileMailer) Send(message Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	name := fmt.Sprintf("%s_%s.eml", time.Now().UTC().Format("20060102T150405"), uuid.New().String())
+	path := filepath.Join(m.dir, name)
+	if err := os.WriteFile(path, formatMessage(m.from, message), 0644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+
+	m.sent = append(m.sent, message)
+	return nil
+}
+
+// Sent returns the messages sent so far, optionally only those to the given address
+func (m *FileMailer) Sent(to string) []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var messages []Message
+	for _, message := range m.sent {
+		if to == "" || strings.EqualFold(message.To, to) {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
@@ -0,0 +1,71 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailerWritesAndKeepsMessages(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	mailer, err := NewFileMailer(dir, "Map Memories <noreply@example.com>")
+	if err != nil {
+		t.Fatalf("NewFileMailer: %v", err)
+	}
+
+	verification := VerificationEmail("ada@example.com", "ada", "http://localhost:3000/verify-email?token=abc.def")
+	reset := PasswordResetEmail("grace@example.com", "grace", "http://localhost:3000/reset-password?token=ghi.jkl")
+	for _, message := range []Message{verification, reset} {
+		if err := mailer.Send(message); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	if sent := mailer.Sent(""); len(sent) != 2 {
+		t.Fatalf("Sent(\"\") returned %d messages, want 2", len(sent))
+	}
+	sent := mailer.Sent("ADA@example.com")
+	if len(sent) != 1 || sent[0] != verification {
+		t.Fatalf("Sent(ada) = %+v, want only the verification email", sent)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("wrote %d .eml files, want 2", len(files))
+	}
+
+	var found bool
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		if !strings.Contains(string(content), "To: ada@example.com\r\n") {
+			continue
+		}
+		found = true
+		for _, want := range []string{
+			"From: Map Memories <noreply@example.com>\r\n",
+			"Subject: " + verification.Subject + "\r\n",
+			"\r\n\r\nHi ada,\r\n",
+			"http://localhost:3000/verify-email?token=abc.def\r\n",
+		} {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("%s does not contain %q:\n%s", file, want, content)
+			}
+		}
+	}
+	if !found {
+		t.Error("no .eml file was written for ada@example.com")
+	}
+}
+
+func TestNewFileMailerRequiresDirectory(t *testing.T) {
+	if _, err := NewFileMailer("", "noreply@example.com"); err == nil {
+		t.Error("NewFileMailer accepted an empty directory")
+	}
+}
@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"map-memories-api/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers emails
+type Mailer interface {
+	Send(message Message) error
+}
+
+// Default is the mailer used by controllers
+var Default Mailer = NewLogMailer()
+
+// Init configures the default mailer from the application configuration
+func Init() error {
+	cfg := config.AppConfig.Mail
+
+	switch strings.ToLower(cfg.Driver) {
+	case "", "log":
+		Default = NewLogMailer()
+		log.Println("Mailer: logging emails instead of sending them")
+		return nil
+	case "file":
+		mailer, err := NewFileMailer(cfg.OutputDir, cfg.From)
+		if err != nil {
+			return err
+		}
+		Default = mailer
+		log.Printf("Mailer: writing emails to %s", cfg.OutputDir)
+		return nil
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return fmt.Errorf("SMTP_HOST is required for the smtp mail driver")
+		}
+		Default = NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From)
+		log.Printf("Mailer: sending emails through %s:%d", cfg.SMTPHost, cfg.SMTPPort)
+		return nil
+	default:
+		return fmt.Errorf("unknown mail driver: %s", cfg.Driver)
+	}
+}
+
+// Send delivers a message with the default mailer
+func Send(message Message) error {
+	return Default.Send(message)
+}
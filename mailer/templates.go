@@ -0,0 +1,50 @@
+package mailer
+
+import "fmt"
+
+// VerificationEmail builds the message asking a user to confirm their email address
+func VerificationEmail(to, username, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Verify your Map Memories email address",
+		Body: fmt.Sprintf(`Hi %s,
+
+Please confirm your email address by opening the link below:
+
+%s
+
+If you did not create a Map Memories account you can ignore this email.
+`, username, link),
+	}
+}
+
+// PasswordResetEmail builds the message carrying a password reset link
+func PasswordResetEmail(to, username, link string) Message {
+	return Message{
+		To:      to,
+		Subject: "Reset your Map Memories password",
+		Body: fmt.Sprintf(`Hi %s,
+
+Someone asked to reset the password of your Map Memories account. Open the link below to choose a new password:
+
+%s
+
+If you did not ask for a password reset you can ignore this email; your password stays unchanged.
+`, username, link),
+	}
+}
+
+// EmailChangeNotice tells the current address that the account email is being changed
+func EmailChangeNotice(to, username, newEmail string) Message {
+	return Message{
+		To:      to,
+		Subject: "Your Map Memories email address is being changed",
+		Body: fmt.Sprintf(`Hi %s,
+
+A request was made to change the email address of your Map Memories account to %s.
+The change takes effect once the new address is verified.
+
+If you did not make this request, reset your password right away.
+`, username, newEmail),
+	}
+}
@@ -10,9 +10,19 @@ import (
 	"syscall"
 	"time"
 
+	"map-memories-api/accounts"
 	"map-memories-api/config"
 	"map-memories-api/database"
+	"map-memories-api/geocoding"
+	"map-memories-api/jobs"
+	"map-memories-api/mailer"
+	"map-memories-api/oauth"
+	"map-memories-api/reconcile"
+	"map-memories-api/resumable"
 	"map-memories-api/routes"
+	"map-memories-api/storage"
+	"map-memories-api/utils"
+	"map-memories-api/videoproc"
 	_ "map-memories-api/docs"
 
 	"github.com/gin-gonic/gin"
@@ -73,9 +83,20 @@ import (
 // @tag.name Media
 // @tag.description Upload và quản lý hình ảnh, video
 
+// @tag.name Users
+// @tag.description Hồ sơ công khai và thống kê du lịch của người dùng
+
 func main() {
 	// Load configuration
 	config.LoadConfig()
+	if err := config.AppConfig.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Load JWT signing keys
+	if err := utils.InitJWTKeys(); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
 
 	// Set Gin mode
 	if config.AppConfig.Environment == "production" {
@@ -92,6 +113,35 @@ func main() {
 	// Run database seeding
 	database.SeedData()
 
+	// Initialize reverse geocoder
+	if err := geocoding.Init(); err != nil {
+		log.Fatalf("Failed to initialize geocoder: %v", err)
+	}
+
+	// Initialize mailer
+	if err := mailer.Init(); err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// Initialize media storage
+	if err := storage.Init(); err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	// Initialize social login providers
+	if err := oauth.Init(); err != nil {
+		log.Fatalf("Failed to initialize identity providers: %v", err)
+	}
+
+	// Background jobs: purge accounts whose deletion grace period has ended, remove
+	// abandoned resumable uploads, check the media storage against the database and
+	// read metadata and poster frames of uploaded videos
+	accounts.ScheduleJobs(config.AppConfig.Account.PurgeInterval)
+	resumable.ScheduleJobs(config.AppConfig.Upload.CleanupInterval)
+	reconcile.ScheduleJobs(config.AppConfig.Reconcile)
+	videoproc.Init(config.AppConfig.Video)
+	jobs.Start(config.AppConfig.Jobs)
+
 	// Create Gin router
 	r := gin.New()
 
@@ -144,4 +194,13 @@ func main() {
 	} else {
 		log.Println("Server exited gracefully")
 	}
+
+	// Let running jobs finish; the rest are queued again for the next start
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), config.AppConfig.Jobs.DrainTimeout)
+	defer drainCancel()
+	if err := jobs.Shutdown(drainCtx); err != nil {
+		log.Printf("Jobs interrupted by shutdown: %v", err)
+	} else {
+		log.Println("Jobs drained")
+	}
 }
\ No newline at end of file
@@ -0,0 +1,152 @@
+package mediainfo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// maxBoxes bounds how many boxes are read from one container
+const maxBoxes = 1 << 16
+
+// box is a box (atom) of an ISO base media file (MP4, QuickTime, HEIF)
+type box struct {
+	Type   string
+	Offset int64 // start of the box header
+	Size   int64 // including the header
+	Header int64
+}
+
+// Body returns where the content of the box starts and ends
+func (b box) Body() (int64, int64) {
+	return b.Offset + b.Header, b.Offset + b.Size
+}
+
+// readBoxes lists the boxes stored between start and end. Each box has to fit exactly,
+// which catches truncated and corrupted files.
+func readBoxes(r io.ReaderAt, start, end int64) ([]box, error) {
+	var boxes []box
+	for offset := start; offset < end; {
+		if end-offset < 8 {
+			return nil, fmt.Errorf("%w: truncated box at %d", ErrMalformed, offset)
+		}
+		if len(boxes) == maxBoxes {
+			return nil, fmt.Errorf("%w: too many boxes", ErrMalformed)
+		}
+
+		var header [16]byte
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil, err
+		}
+		b := box{
+			Type:   string(header[4:8]),
+			Offset: offset,
+			Size:   int64(binary.BigEndian.Uint32(header[0:4])),
+			Header: 8,
+		}
+		switch b.Size {
+		case 0:
+			// The last box may extend to the end of the file
+			b.Size = end - offset
+		case 1:
+			if end-offset < 16 {
+				return nil, fmt.Errorf("%w: truncated box at %d", ErrMalformed, offset)
+			}
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, err
+			}
+			b.Size = int64(binary.BigEndian.Uint64(header[8:16]))
+			b.Header = 16
+		}
+		if b.Size < b.Header || b.Size > end-offset {
+			return nil, fmt.Errorf("%w: %q box at %d has an invalid size", ErrMalformed, b.Type, offset)
+		}
+
+		boxes = append(boxes, b)
+		offset += b.Size
+	}
+	return boxes, nil
+}
+
+// findBox returns the first box of type typ
+func findBox(boxes []box, typ string) (box, bool) {
+	for _, b := range boxes {
+		if b.Type == typ {
+			return b, true
+		}
+	}
+	return box{}, false
+}
+
+// childBoxes lists the boxes inside b; fullBox skips the version and flags that
+// precede the children of full boxes such as meta
+func childBoxes(r io.ReaderAt, b box, fullBox bool) ([]box, error) {
+	start, end := b.Body()
+	if fullBox {
+		start += 4
+	}
+	if start > end {
+		return nil, fmt.Errorf("%w: %q box is too short", ErrMalformed, b.Type)
+	}
+	return readBoxes(r, start, end)
+}
+
+// checkMovie validates the top-level layout of an MP4/QuickTime file
+func checkMovie(r io.ReaderAt, size int64) error {
+	boxes, err := readBoxes(r, 0, size)
+	if err != nil {
+		return err
+	}
+	if _, ok := findBox(boxes, "moov"); !ok {
+		return fmt.Errorf("%w: no movie header", ErrMalformed)
+	}
+	return nil
+}
+
+// heifSize reads the dimensions of the primary image of a HEIF file from the image
+// spatial extents properties (meta/iprp/ipco/ispe). Files hold an ispe per tile and
+// thumbnail, so the largest is taken.
+func heifSize(r io.ReaderAt, size int64) (int, int, error) {
+	boxes, err := readBoxes(r, 0, size)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	path := []struct {
+		typ     string
+		fullBox bool
+	}{{"meta", true}, {"iprp", false}, {"ipco", false}}
+	for _, step := range path {
+		parent, ok := findBox(boxes, step.typ)
+		if !ok {
+			return 0, 0, fmt.Errorf("%w: no %q box", ErrMalformed, step.typ)
+		}
+		if boxes, err = childBoxes(r, parent, step.fullBox); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	width, height := 0, 0
+	for _, b := range boxes {
+		if b.Type != "ispe" {
+			continue
+		}
+		start, end := b.Body()
+		if end-start < 12 {
+			return 0, 0, fmt.Errorf("%w: short ispe box", ErrMalformed)
+		}
+		var extents [12]byte
+		if _, err := r.ReadAt(extents[:], start); err != nil {
+			return 0, 0, err
+		}
+		w := int(binary.BigEndian.Uint32(extents[4:8]))
+		h := int(binary.BigEndian.Uint32(extents[8:12]))
+		if w*h > width*height {
+			width, height = w, h
+		}
+	}
+	if width == 0 || height == 0 {
+		return 0, 0, fmt.Errorf("%w: no image dimensions", ErrMalformed)
+	}
+	return width, height, nil
+}
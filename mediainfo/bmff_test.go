@@ -0,0 +1,126 @@
+package mediainfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// makeBox builds a box of type typ holding the concatenated parts
+func makeBox(typ string, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	data := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	data = append(data, typ...)
+	return append(data, body...)
+}
+
+// makeFullBox builds a full box: version and flags precede the parts
+func makeFullBox(typ string, version byte, parts ...[]byte) []byte {
+	return makeBox(typ, append([][]byte{{version, 0, 0, 0}}, parts...)...)
+}
+
+// makeFtyp builds an ftyp box with a major brand and compatible brands
+func makeFtyp(major string, compatible ...string) []byte {
+	body := append([]byte(major), 0, 0, 0, 0)
+	for _, brand := range compatible {
+		body = append(body, brand...)
+	}
+	return makeBox("ftyp", body)
+}
+
+// u32 encodes big-endian 32 bit values
+func u32(values ...uint32) []byte {
+	var data []byte
+	for _, v := range values {
+		data = binary.BigEndian.AppendUint32(data, v)
+	}
+	return data
+}
+
+// u64 encodes big-endian 64 bit values
+func u64(values ...uint64) []byte {
+	var data []byte
+	for _, v := range values {
+		data = binary.BigEndian.AppendUint64(data, v)
+	}
+	return data
+}
+
+func TestReadBoxes(t *testing.T) {
+	largeBox := append(u32(1), "mdat"...)
+	largeBox = append(largeBox, u64(20)...)
+	largeBox = append(largeBox, "abcd"...)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []box
+		wantErr bool
+	}{
+		{
+			name: "consecutive boxes",
+			data: append(makeBox("ftyp", []byte("isom")), makeBox("free")...),
+			want: []box{
+				{Type: "ftyp", Offset: 0, Size: 12, Header: 8},
+				{Type: "free", Offset: 12, Size: 8, Header: 8},
+			},
+		},
+		{
+			name: "size 0 extends to the end",
+			data: append(makeBox("free"), append(u32(0), "mdat1234567"...)...),
+			want: []box{
+				{Type: "free", Offset: 0, Size: 8, Header: 8},
+				{Type: "mdat", Offset: 8, Size: 15, Header: 8},
+			},
+		},
+		{
+			name: "64 bit size",
+			data: largeBox,
+			want: []box{{Type: "mdat", Offset: 0, Size: 20, Header: 16}},
+		},
+		{
+			name:    "64 bit size smaller than its header",
+			data:    append(append(u32(1), "mdat"...), u64(8)...),
+			wantErr: true,
+		},
+		{
+			name:    "truncated 64 bit header",
+			data:    append(u32(1), "mdat1234"...),
+			wantErr: true,
+		},
+		{
+			name:    "box larger than the file",
+			data:    makeBox("moov", make([]byte, 16))[:20],
+			wantErr: true,
+		},
+		{
+			name:    "size smaller than the header",
+			data:    append(u32(4), "free"...),
+			wantErr: true,
+		},
+		{
+			name:    "trailing bytes",
+			data:    append(makeBox("free"), 0, 0, 0),
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			boxes, err := readBoxes(bytes.NewReader(test.data), 0, int64(len(test.data)))
+			if test.wantErr {
+				if !errors.Is(err, ErrMalformed) {
+					t.Fatalf("readBoxes error = %v, want ErrMalformed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readBoxes: %v", err)
+			}
+			if !reflect.DeepEqual(boxes, test.want) {
+				t.Errorf("readBoxes = %+v, want %+v", boxes, test.want)
+			}
+		})
+	}
+}
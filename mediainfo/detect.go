@@ -0,0 +1,130 @@
+package mediainfo
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// HeadSize is how many leading bytes Detect needs to recognize every supported format
+const HeadSize = 4096
+
+var (
+	pngSignature  = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}
+	jpegSignature = []byte{0xFF, 0xD8, 0xFF}
+	ebmlSignature = []byte{0x1A, 0x45, 0xDF, 0xA3}
+)
+
+// ftypBrands maps ISO base media file brands to the type of the file
+var ftypBrands = map[string]string{
+	"heic": TypeHEIC,
+	"heix": TypeHEIC,
+	"heim": TypeHEIC,
+	"heis": TypeHEIC,
+	"hevc": TypeHEIC,
+	"hevx": TypeHEIC,
+	"mif1": TypeHEIF,
+	"msf1": TypeHEIF,
+	"avif": TypeAVIF,
+	"avis": TypeAVIF,
+	"qt  ": TypeQuickTime,
+	"isom": TypeMP4,
+	"iso2": TypeMP4,
+	"iso3": TypeMP4,
+	"iso4": TypeMP4,
+	"iso5": TypeMP4,
+	"iso6": TypeMP4,
+	"mp41": TypeMP4,
+	"mp42": TypeMP4,
+	"mp71": TypeMP4,
+	"avc1": TypeMP4,
+	"dash": TypeMP4,
+	"mmp4": TypeMP4,
+	"f4v ": TypeMP4,
+	"M4V ": TypeMP4,
+	"M4VH": TypeMP4,
+	"M4VP": TypeMP4,
+	"MSNV": TypeMP4,
+	"3gp4": Type3GPP,
+	"3gp5": Type3GPP,
+	"3gp6": Type3GPP,
+	"3gg6": Type3GPP,
+	"3ge6": Type3GPP,
+	"3g2a": Type3GPP,
+}
+
+// quickTimeAtoms are atoms old QuickTime files (without an ftyp box) start with
+var quickTimeAtoms = map[string]bool{
+	"moov": true,
+	"mdat": true,
+	"wide": true,
+	"pnot": true,
+}
+
+// Detect identifies the format of a file from its first bytes (see HeadSize) and returns
+// its media type, or "" when it is not a known image or video format. The file name is
+// deliberately not consulted.
+func Detect(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, jpegSignature):
+		return TypeJPEG
+	case bytes.HasPrefix(head, pngSignature):
+		return TypePNG
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return TypeGIF
+	case len(head) >= 12 && string(head[0:4]) == "RIFF":
+		switch string(head[8:12]) {
+		case "WEBP":
+			return TypeWebP
+		case "AVI ":
+			return TypeAVI
+		}
+	case bytes.HasPrefix(head, ebmlSignature):
+		return detectMatroska(head)
+	case len(head) >= 16 && string(head[4:8]) == "ftyp":
+		return detectFtyp(head)
+	case len(head) >= 8 && quickTimeAtoms[string(head[4:8])] && binary.BigEndian.Uint32(head) >= 8:
+		return TypeQuickTime
+	}
+	return ""
+}
+
+// detectFtyp picks the type from the major brand of an ftyp box, falling back to the
+// compatible brands
+func detectFtyp(head []byte) string {
+	end := int(binary.BigEndian.Uint32(head))
+	if end < 16 || end > len(head) {
+		end = len(head)
+	}
+
+	brands := []string{string(head[8:12])}
+	for i := 16; i+4 <= end; i += 4 {
+		brands = append(brands, string(head[i:i+4]))
+	}
+	for _, brand := range brands {
+		if mimeType, ok := ftypBrands[brand]; ok {
+			return mimeType
+		}
+	}
+	return ""
+}
+
+// detectMatroska tells WebM from other Matroska files by the DocType of the EBML header
+func detectMatroska(head []byte) string {
+	index := bytes.Index(head, []byte{0x42, 0x82})
+	if index < 0 || index+3 > len(head) || head[index+2]&0x80 == 0 {
+		return ""
+	}
+
+	start := index + 3
+	end := start + int(head[index+2]&0x7F)
+	if end > len(head) {
+		return ""
+	}
+	switch string(head[start:end]) {
+	case "webm":
+		return TypeWebM
+	case "matroska":
+		return TypeMatroska
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package mediainfo
+
+import (
+	"testing"
+)
+
+// makeEBML builds an EBML header declaring docType
+func makeEBML(docType string) []byte {
+	body := append([]byte{0x42, 0x86, 0x81, 0x01, 0x42, 0x82, 0x80 | byte(len(docType))}, docType...)
+	return append([]byte{0x1A, 0x45, 0xDF, 0xA3, 0x80 | byte(len(body))}, body...)
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"JPEG", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0, 0x10, 'J', 'F', 'I', 'F'}, TypeJPEG},
+		{"PNG", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), TypePNG},
+		{"GIF87a", []byte("GIF87a\x01\x00\x01\x00"), TypeGIF},
+		{"GIF89a", []byte("GIF89a\x01\x00\x01\x00"), TypeGIF},
+		{"WebP", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), TypeWebP},
+		{"AVI", []byte("RIFF\x24\x00\x00\x00AVI LIST"), TypeAVI},
+		{"other RIFF", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), ""},
+		{"WebM", makeEBML("webm"), TypeWebM},
+		{"Matroska", makeEBML("matroska"), TypeMatroska},
+		{"EBML without DocType", []byte{0x1A, 0x45, 0xDF, 0xA3, 0x84, 0x42, 0x86, 0x81, 0x01}, ""},
+		{"EBML with a truncated DocType", makeEBML("matroska")[:12], ""},
+		{"HEIC", makeFtyp("heic", "mif1", "heic"), TypeHEIC},
+		{"HEIF", makeFtyp("mif1", "mif1"), TypeHEIF},
+		{"AVIF", makeFtyp("avif", "mif1", "avif"), TypeAVIF},
+		{"MP4", makeFtyp("isom", "isom", "avc1"), TypeMP4},
+		{"QuickTime ftyp", makeFtyp("qt  ", "qt  "), TypeQuickTime},
+		{"3GPP", makeFtyp("3gp4", "isom"), Type3GPP},
+		{"unknown major brand", makeFtyp("zzzz", "zzzz", "mp42"), TypeMP4},
+		{"unknown brands", makeFtyp("zzzz", "yyyy"), ""},
+		{"QuickTime without ftyp", makeBox("moov", makeBox("mvhd")), TypeQuickTime},
+		{"QuickTime atom with a bad size", append(u32(4), "mdat"...), ""},
+		{"text", []byte("<!DOCTYPE html><html>"), ""},
+		{"empty", nil, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Detect(test.head); got != test.want {
+				t.Errorf("Detect = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDetectFtypStopsAtTheBoxEnd(t *testing.T) {
+	// Brands past the end of the ftyp box belong to the next box
+	head := append(makeFtyp("zzzz", "zzzz"), makeBox("heic")...)
+	if got := detectFtyp(head); got != "" {
+		t.Errorf("detectFtyp = %q, want no type", got)
+	}
+
+	// A truncated head is read as far as it goes
+	head = makeFtyp("zzzz", "yyyy", "mp42")
+	if got := detectFtyp(head[:len(head)-4]); got != "" {
+		t.Errorf("detectFtyp(truncated) = %q, want no type", got)
+	}
+	if got := detectFtyp(head); got != TypeMP4 {
+		t.Errorf("detectFtyp = %q, want %q", got, TypeMP4)
+	}
+}
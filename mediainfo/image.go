@@ -0,0 +1,89 @@
+package mediainfo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// imageSize decodes the header of an image, which confirms it is well-formed, and
+// returns its dimensions
+func imageSize(r io.ReaderAt, size int64, mimeType string) (int, int, error) {
+	section := io.NewSectionReader(r, 0, size)
+
+	var config image.Config
+	var err error
+	switch mimeType {
+	case TypeJPEG:
+		config, err = jpeg.DecodeConfig(section)
+	case TypePNG:
+		config, err = png.DecodeConfig(section)
+	case TypeGIF:
+		config, err = gif.DecodeConfig(section)
+	case TypeWebP:
+		return webpSize(r, size)
+	case TypeHEIC, TypeHEIF, TypeAVIF:
+		return heifSize(r, size)
+	default:
+		return 0, 0, fmt.Errorf("%w: %s", ErrUnknownFormat, mimeType)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	return config.Width, config.Height, nil
+}
+
+// webpSize reads the dimensions from the first chunk of a WebP file, which is a lossy
+// (VP8), lossless (VP8L) or extended (VP8X) header
+func webpSize(r io.ReaderAt, size int64) (int, int, error) {
+	if err := checkRIFF(r, size); err != nil {
+		return 0, 0, err
+	}
+
+	var header [30]byte
+	if size < int64(len(header)) {
+		return 0, 0, fmt.Errorf("%w: truncated WebP header", ErrMalformed)
+	}
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return 0, 0, err
+	}
+
+	data := header[20:]
+	switch string(header[12:16]) {
+	case "VP8 ":
+		if data[3] != 0x9D || data[4] != 0x01 || data[5] != 0x2A {
+			return 0, 0, fmt.Errorf("%w: bad VP8 start code", ErrMalformed)
+		}
+		width := int(binary.LittleEndian.Uint16(data[6:8]) & 0x3FFF)
+		height := int(binary.LittleEndian.Uint16(data[8:10]) & 0x3FFF)
+		return width, height, nil
+	case "VP8L":
+		if data[0] != 0x2F {
+			return 0, 0, fmt.Errorf("%w: bad VP8L signature", ErrMalformed)
+		}
+		bits := binary.LittleEndian.Uint32(data[1:5])
+		return int(bits&0x3FFF) + 1, int(bits>>14&0x3FFF) + 1, nil
+	case "VP8X":
+		width := int(data[4]) | int(data[5])<<8 | int(data[6])<<16
+		height := int(data[7]) | int(data[8])<<8 | int(data[9])<<16
+		return width + 1, height + 1, nil
+	}
+	return 0, 0, fmt.Errorf("%w: unknown WebP chunk %q", ErrMalformed, header[12:16])
+}
+
+// checkRIFF validates that the RIFF container (WebP, AVI) fits the file
+func checkRIFF(r io.ReaderAt, size int64) error {
+	var header [8]byte
+	if _, err := r.ReadAt(header[:], 0); err != nil {
+		return err
+	}
+	// The declared length excludes the 8-byte header; odd lengths are padded
+	if declared := int64(binary.LittleEndian.Uint32(header[4:8])) + 8; declared > size+1 {
+		return fmt.Errorf("%w: truncated RIFF file", ErrMalformed)
+	}
+	return nil
+}
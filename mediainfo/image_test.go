@@ -0,0 +1,131 @@
+package mediainfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// makeWebP builds a WebP file whose first chunk is fourCC holding data
+func makeWebP(fourCC string, data []byte) []byte {
+	chunk := append([]byte(fourCC), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	file := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(4+len(chunk)))...)
+	file = append(file, "WEBP"...)
+	return append(file, chunk...)
+}
+
+// vp8Frame is a lossy key frame header for the given size
+func vp8Frame(width, height uint16) []byte {
+	frame := []byte{0x50, 0x02, 0x00, 0x9D, 0x01, 0x2A}
+	frame = binary.LittleEndian.AppendUint16(frame, width)
+	return binary.LittleEndian.AppendUint16(frame, height)
+}
+
+// vp8lHeader is a lossless bitstream header for the given size, followed by the start of
+// the image data
+func vp8lHeader(width, height uint32) []byte {
+	bits := (width - 1) | (height-1)<<14
+	header := append([]byte{0x2F}, binary.LittleEndian.AppendUint32(nil, bits)...)
+	return append(header, make([]byte, 8)...)
+}
+
+// vp8xHeader is an extended header for the given canvas size
+func vp8xHeader(width, height uint32) []byte {
+	header := []byte{0x10, 0, 0, 0}
+	header = append(header, byte(width-1), byte((width-1)>>8), byte((width-1)>>16))
+	return append(header, byte(height-1), byte((height-1)>>8), byte((height-1)>>16))
+}
+
+// makeHEIF builds a HEIF file holding an ispe property for each size
+func makeHEIF(brand string, sizes ...[2]uint32) []byte {
+	var properties [][]byte
+	for _, size := range sizes {
+		properties = append(properties, makeFullBox("ispe", 0, u32(size[0], size[1])))
+	}
+	meta := makeFullBox("meta", 0,
+		makeFullBox("hdlr", 0, u32(0), []byte("pict"), make([]byte, 13)),
+		makeBox("iprp", makeBox("ipco", properties...)),
+	)
+	return bytes.Join([][]byte{makeFtyp(brand, "mif1", brand), meta, makeBox("mdat")}, nil)
+}
+
+func TestWebPSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		width, height int
+		wantErr       bool
+	}{
+		{name: "VP8", data: makeWebP("VP8 ", vp8Frame(640, 480)), width: 640, height: 480},
+		{name: "VP8 scale bits are ignored", data: makeWebP("VP8 ", vp8Frame(0x4000|320, 0xC000|200)), width: 320, height: 200},
+		{name: "VP8 bad start code", data: makeWebP("VP8 ", append([]byte{0x50, 0x02, 0x00, 0x9D, 0x01, 0x2B}, 0, 1, 0, 1)), wantErr: true},
+		{name: "VP8L", data: makeWebP("VP8L", vp8lHeader(1920, 1080)), width: 1920, height: 1080},
+		{name: "VP8L bad signature", data: makeWebP("VP8L", append([]byte{0x2E}, vp8lHeader(2, 2)[1:]...)), wantErr: true},
+		{name: "VP8X", data: makeWebP("VP8X", vp8xHeader(4000, 3000)), width: 4000, height: 3000},
+		{name: "VP8X larger than 16 bits", data: makeWebP("VP8X", vp8xHeader(70000, 1)), width: 70000, height: 1},
+		{name: "unknown chunk", data: makeWebP("ALPH", make([]byte, 10)), wantErr: true},
+		{name: "truncated file", data: makeWebP("VP8 ", append(vp8Frame(640, 480), make([]byte, 100)...))[:40], wantErr: true},
+		{name: "header shorter than a chunk", data: makeWebP("VP8 ", []byte{0x50, 0x02}), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			width, height, err := webpSize(bytes.NewReader(test.data), int64(len(test.data)))
+			if test.wantErr {
+				if !errors.Is(err, ErrMalformed) {
+					t.Fatalf("webpSize error = %v, want ErrMalformed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("webpSize: %v", err)
+			}
+			if width != test.width || height != test.height {
+				t.Errorf("webpSize = %dx%d, want %dx%d", width, height, test.width, test.height)
+			}
+		})
+	}
+}
+
+func TestHEIFSize(t *testing.T) {
+	noProperties := bytes.Join([][]byte{makeFtyp("heic"), makeFullBox("meta", 0, makeBox("iprp", makeBox("ipco")))}, nil)
+	shortIspe := bytes.Join([][]byte{makeFtyp("heic"), makeFullBox("meta", 0, makeBox("iprp", makeBox("ipco", makeFullBox("ispe", 0, u32(64)))))}, nil)
+	truncated := makeHEIF("heic", [2]uint32{4032, 3024})
+
+	tests := []struct {
+		name          string
+		data          []byte
+		width, height int
+		wantErr       bool
+	}{
+		{name: "single image", data: makeHEIF("heic", [2]uint32{4032, 3024}), width: 4032, height: 3024},
+		{name: "largest of tiles and thumbnail", data: makeHEIF("heic", [2]uint32{512, 512}, [2]uint32{4032, 3024}, [2]uint32{320, 240}), width: 4032, height: 3024},
+		{name: "AVIF", data: makeHEIF("avif", [2]uint32{1280, 720}), width: 1280, height: 720},
+		{name: "no meta box", data: append(makeFtyp("heic"), makeBox("mdat")...), wantErr: true},
+		{name: "no ispe", data: noProperties, wantErr: true},
+		{name: "short ispe", data: shortIspe, wantErr: true},
+		{name: "zero size", data: makeHEIF("heic", [2]uint32{0, 3024}), wantErr: true},
+		{name: "truncated file", data: truncated[:len(truncated)-20], wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			width, height, err := heifSize(bytes.NewReader(test.data), int64(len(test.data)))
+			if test.wantErr {
+				if !errors.Is(err, ErrMalformed) {
+					t.Fatalf("heifSize error = %v, want ErrMalformed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("heifSize: %v", err)
+			}
+			if width != test.width || height != test.height {
+				t.Errorf("heifSize = %dx%d, want %dx%d", width, height, test.width, test.height)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package mediainfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// ErrUnknownFormat is returned for content that is not a supported image or video
+	ErrUnknownFormat = errors.New("unrecognized file format")
+	// ErrMalformed is returned when the headers of a file are invalid or truncated
+	ErrMalformed = errors.New("malformed media file")
+	// ErrTypeMismatch is returned when the file extension names another format
+	ErrTypeMismatch = errors.New("file extension does not match its content")
+	// ErrPolyglot is returned for files that are also valid as another format, such as
+	// an image with an HTML page or ZIP archive appended
+	ErrPolyglot = errors.New("file contains embedded content of another format")
+)
+
+// scanWindow is how much of the start and the end of appended data, and of the end of a
+// file for a ZIP directory, is searched for embedded content
+const scanWindow = 64 * 1024
+
+// embeddedMarkup are markers of content browsers or interpreters would execute,
+// matched case-insensitively
+var embeddedMarkup = []string{
+	"<?php",
+	"<script",
+	"<html",
+	"<!doctype html",
+	"<svg",
+	"<iframe",
+}
+
+var (
+	zipEndOfCentralDirectory = []byte{'P', 'K', 0x05, 0x06}
+	pdfSignature             = []byte("%PDF-")
+)
+
+// Info describes a validated media file
+type Info struct {
+	MimeType string
+	Width    int // images only
+	Height   int
+}
+
+// Inspect identifies the format of a file from its content and validates it: the
+// extension of filename has to match, the headers have to be well-formed and no other
+// format may be embedded in it
+func Inspect(r io.ReaderAt, size int64, filename string) (*Info, error) {
+	head, err := readAt(r, 0, HeadSize, size)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{MimeType: Detect(head)}
+	if info.MimeType == "" {
+		return nil, ErrUnknownFormat
+	}
+	if !MatchesExtension(info.MimeType, filename) {
+		return nil, fmt.Errorf("%w: %s file named %q", ErrTypeMismatch, info.MimeType, filepath.Ext(filename))
+	}
+
+	if err := checkEmbedded(r, size, info.MimeType); err != nil {
+		return nil, err
+	}
+
+	switch families[info.MimeType] {
+	case "bmff":
+		err = checkMovie(r, size)
+	case "matroska":
+		// Matroska is EBML all the way down; the header recognized by Detect is the
+		// only part checked before playback
+	default:
+		if info.MimeType == TypeAVI {
+			err = checkRIFF(r, size)
+		} else {
+			info.Width, info.Height, err = imageSize(r, size, info.MimeType)
+			if err == nil && (info.Width <= 0 || info.Height <= 0) {
+				err = fmt.Errorf("%w: empty image", ErrMalformed)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// checkEmbedded looks for polyglot payloads. Markup is searched in the text segments of
+// the file and in bytes appended after the container; compressed image and video data
+// is not searched, as it matches short markers by chance.
+func checkEmbedded(r io.ReaderAt, size int64, mimeType string) error {
+	l, err := fileLayout(r, size, mimeType)
+	if err != nil {
+		return err
+	}
+	parts := l.Text
+	if l.End < size {
+		appended, err := readWindows(r, l.End, size)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, appended...)
+	}
+	for _, part := range parts {
+		lower := asciiLower(part)
+		for _, marker := range embeddedMarkup {
+			if bytes.Contains(lower, []byte(marker)) {
+				return fmt.Errorf("%w: %s", ErrPolyglot, strings.TrimPrefix(marker, "<"))
+			}
+		}
+	}
+
+	head, err := readAt(r, 0, 1024, size)
+	if err != nil {
+		return err
+	}
+	// PDF readers accept the header anywhere in the first kilobyte
+	if bytes.Contains(head, pdfSignature) {
+		return fmt.Errorf("%w: PDF", ErrPolyglot)
+	}
+
+	tailStart := max(size-scanWindow, 0)
+	tail, err := readAt(r, tailStart, scanWindow, size)
+	if err != nil {
+		return err
+	}
+	if hasZipDirectory(tail, tailStart) {
+		return fmt.Errorf("%w: ZIP archive", ErrPolyglot)
+	}
+	return nil
+}
+
+// readWindows reads the first and the last scanWindow bytes between start and end
+func readWindows(r io.ReaderAt, start, end int64) ([][]byte, error) {
+	first, err := readAt(r, start, scanWindow, end)
+	if err != nil {
+		return nil, err
+	}
+	if end-start <= scanWindow {
+		return [][]byte{first}, nil
+	}
+	last, err := readAt(r, max(end-scanWindow, start+scanWindow), scanWindow, end)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{first, last}, nil
+}
+
+// hasZipDirectory reports whether tail, which starts at offset tailStart of the file,
+// holds the end of central directory record ZIP readers locate an archive from, whatever
+// precedes it. The fields of the record have to be consistent, so that the signature
+// occurring by chance in compressed data does not count.
+func hasZipDirectory(tail []byte, tailStart int64) bool {
+	for end := len(tail); ; {
+		i := bytes.LastIndex(tail[:end], zipEndOfCentralDirectory)
+		if i < 0 {
+			return false
+		}
+		end = i
+
+		record := tail[i:]
+		if len(record) < 22 {
+			continue
+		}
+		disk := binary.LittleEndian.Uint16(record[4:6])
+		directoryDisk := binary.LittleEndian.Uint16(record[6:8])
+		diskEntries := binary.LittleEndian.Uint16(record[8:10])
+		entries := binary.LittleEndian.Uint16(record[10:12])
+		directorySize := int64(binary.LittleEndian.Uint32(record[12:16]))
+		commentLength := int(binary.LittleEndian.Uint16(record[20:22]))
+		if disk == 0 && directoryDisk == 0 && diskEntries == entries &&
+			directorySize <= tailStart+int64(i) && 22+commentLength <= len(record) {
+			return true
+		}
+	}
+}
+
+// readAt reads up to n bytes at offset of a file of the given size
+func readAt(r io.ReaderAt, offset, n, size int64) ([]byte, error) {
+	if offset+n > size {
+		n = size - offset
+	}
+	if n <= 0 {
+		return nil, nil
+	}
+
+	buffer := make([]byte, n)
+	read, err := r.ReadAt(buffer, offset)
+	if err != nil && !(err == io.EOF && int64(read) == n) {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// asciiLower lowercases ASCII letters and leaves every other byte alone
+func asciiLower(data []byte) []byte {
+	lower := make([]byte, len(data))
+	for i, c := range data {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lower[i] = c
+	}
+	return lower
+}
@@ -0,0 +1,173 @@
+package mediainfo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// encodedImage encodes a blank width×height image with encode
+func encodedImage(t *testing.T, width, height int, encode func(*bytes.Buffer, image.Image) error) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	if err := encode(&buffer, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("encode image: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+func TestInspect(t *testing.T) {
+	jpegFile := encodedImage(t, 32, 24, func(w *bytes.Buffer, m image.Image) error { return jpeg.Encode(w, m, nil) })
+	pngFile := encodedImage(t, 16, 8, func(w *bytes.Buffer, m image.Image) error { return png.Encode(w, m) })
+	gifFile := encodedImage(t, 5, 7, func(w *bytes.Buffer, m image.Image) error { return gif.Encode(w, m, nil) })
+	webpFile := makeWebP("VP8 ", vp8Frame(640, 480))
+	heicFile := makeHEIF("heic", [2]uint32{4032, 3024})
+	movie := append(makeFtyp("isom", "isom"), makeBox("moov", makeFullBox("mvhd", 0, make([]byte, 96)))...)
+
+	tests := []struct {
+		name          string
+		data          []byte
+		filename      string
+		mimeType      string
+		width, height int
+		wantErr       error
+	}{
+		{name: "JPEG", data: jpegFile, filename: "photo.jpg", mimeType: TypeJPEG, width: 32, height: 24},
+		{name: "PNG", data: pngFile, filename: "image.PNG", mimeType: TypePNG, width: 16, height: 8},
+		{name: "GIF", data: gifFile, filename: "anim.gif", mimeType: TypeGIF, width: 5, height: 7},
+		{name: "WebP", data: webpFile, filename: "image.webp", mimeType: TypeWebP, width: 640, height: 480},
+		{name: "HEIC", data: heicFile, filename: "IMG_0001.HEIC", mimeType: TypeHEIC, width: 4032, height: 3024},
+		{name: "HEIC labelled as HEIF", data: heicFile, filename: "IMG_0001.heif", mimeType: TypeHEIC, width: 4032, height: 3024},
+		{name: "MP4", data: movie, filename: "clip.mp4", mimeType: TypeMP4},
+		{name: "MP4 labelled as QuickTime", data: movie, filename: "clip.mov", mimeType: TypeMP4},
+		{name: "no extension", data: pngFile, filename: "upload", mimeType: TypePNG, width: 16, height: 8},
+
+		{name: "PNG renamed to JPEG", data: pngFile, filename: "photo.jpg", wantErr: ErrTypeMismatch},
+		{name: "JPEG renamed to MP4", data: jpegFile, filename: "clip.mp4", wantErr: ErrTypeMismatch},
+		{name: "HEIC renamed to MP4", data: heicFile, filename: "clip.mp4", wantErr: ErrTypeMismatch},
+		{name: "HTML renamed to JPEG", data: []byte("<!DOCTYPE html><html><body></body></html>"), filename: "photo.jpg", wantErr: ErrUnknownFormat},
+		{name: "empty file", data: nil, filename: "photo.jpg", wantErr: ErrUnknownFormat},
+
+		{name: "truncated JPEG", data: jpegFile[:8], filename: "photo.jpg", wantErr: ErrMalformed},
+		{name: "truncated PNG", data: pngFile[:20], filename: "image.png", wantErr: ErrMalformed},
+		{name: "truncated WebP", data: webpFile[:len(webpFile)-4], filename: "image.webp", wantErr: ErrMalformed},
+		{name: "truncated HEIC", data: heicFile[:len(heicFile)-12], filename: "image.heic", wantErr: ErrMalformed},
+		{name: "truncated MP4", data: movie[:len(movie)-10], filename: "clip.mp4", wantErr: ErrMalformed},
+		{name: "MP4 without a movie header", data: append(makeFtyp("isom", "isom"), makeBox("mdat")...), filename: "clip.mp4", wantErr: ErrMalformed},
+		{name: "empty VP8X canvas", data: makeWebP("VP8X", make([]byte, 10)), filename: "image.webp", mimeType: TypeWebP, width: 1, height: 1},
+
+		{name: "JPEG with an HTML page appended", data: append(bytes.Clone(jpegFile), "<html><script>alert(1)</script></html>"...), filename: "photo.jpg", wantErr: ErrPolyglot},
+		{name: "PNG with a ZIP archive appended", data: append(bytes.Clone(pngFile), zipArchive()...), filename: "image.png", wantErr: ErrPolyglot},
+		{name: "GIF with a PDF header", data: append(bytes.Clone(gifFile[:13]), append([]byte("%PDF-1.4"), gifFile[13:]...)...), filename: "anim.gif", wantErr: ErrPolyglot},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := Inspect(bytes.NewReader(test.data), int64(len(test.data)), test.filename)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("Inspect error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Inspect: %v", err)
+			}
+			want := Info{MimeType: test.mimeType, Width: test.width, Height: test.height}
+			if *info != want {
+				t.Errorf("Inspect = %+v, want %+v", *info, want)
+			}
+		})
+	}
+}
+
+// zipArchive is an empty ZIP archive: only the end of central directory record
+func zipArchive() []byte {
+	return append([]byte{'P', 'K', 0x05, 0x06}, make([]byte, 18)...)
+}
+
+// splice returns data with insert placed at offset
+func splice(data []byte, offset int, insert []byte) []byte {
+	return bytes.Join([][]byte{data[:offset], insert, data[offset:]}, nil)
+}
+
+// pngChunk builds a PNG chunk
+func pngChunk(typ string, data []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func TestCheckEmbedded(t *testing.T) {
+	jpegFile := encodedImage(t, 64, 64, func(w *bytes.Buffer, m image.Image) error { return jpeg.Encode(w, m, nil) })
+	pngFile := encodedImage(t, 16, 8, func(w *bytes.Buffer, m image.Image) error { return png.Encode(w, m) })
+	gifFile := encodedImage(t, 5, 7, func(w *bytes.Buffer, m image.Image) error { return gif.Encode(w, m, nil) })
+
+	// Markers inside the entropy-coded data of the scan
+	scan := bytes.Index(jpegFile, []byte{0xFF, 0xDA})
+	scanData := scan + 2 + int(binary.BigEndian.Uint16(jpegFile[scan+2:]))
+	jpegWithMarkersInData := bytes.Clone(jpegFile)
+	copy(jpegWithMarkersInData[scanData:], "<svg")
+	jpegComment := append([]byte{0xFF, 0xFE, 0, 2 + 8}, "<script>"...)
+	// Exif thumbnails are JPEG files, with an end of image marker of their own
+	thumbnail := append([]byte{0xFF, 0xE1, 0, 2 + 8}, "Exif\x00\x00\xFF\xD9"...)
+
+	pngIEND := len(pngFile) - 12
+	webpFile := makeWebP("VP8 ", append(vp8Frame(64, 64), "<iframe>"...))
+	movie := bytes.Join([][]byte{makeFtyp("isom", "isom"), makeBox("moov"), makeBox("mdat", []byte("<html><svg"))}, nil)
+	segment := append([]byte{0x18, 0x53, 0x80, 0x67, 0x80 | 10}, "<html><svg"...)
+	webmFile := append(makeEBML("webm"), segment...)
+	unknownSizeSegment := append([]byte{0x18, 0x53, 0x80, 0x67, 0xFF}, "<html><svg"...)
+
+	// A ZIP signature whose record does not fit the file
+	inconsistentZip := zipArchive()
+	binary.LittleEndian.PutUint16(inconsistentZip[20:22], 100)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		mimeType string
+		polyglot bool
+	}{
+		{name: "JPEG", data: jpegFile, mimeType: TypeJPEG},
+		{name: "JPEG with markup in compressed data", data: jpegWithMarkersInData, mimeType: TypeJPEG},
+		{name: "JPEG with markup in a comment", data: splice(jpegFile, 2, jpegComment), mimeType: TypeJPEG, polyglot: true},
+		{name: "JPEG with markup after an Exif thumbnail", data: splice(jpegWithMarkersInData, 2, thumbnail), mimeType: TypeJPEG},
+		{name: "JPEG with an HTML page appended", data: append(bytes.Clone(jpegFile), "<!DOCTYPE html>"...), mimeType: TypeJPEG, polyglot: true},
+		{name: "JPEG with a PHP script appended", data: append(bytes.Clone(jpegFile), "<?PHP echo 1; ?>"...), mimeType: TypeJPEG, polyglot: true},
+		{name: "JPEG with an inconsistent ZIP signature", data: append(bytes.Clone(jpegFile), inconsistentZip...), mimeType: TypeJPEG},
+
+		{name: "PNG with markup in a private chunk", data: splice(pngFile, pngIEND, pngChunk("prVt", []byte("<html><svg"))), mimeType: TypePNG},
+		{name: "PNG with markup in a text chunk", data: splice(pngFile, pngIEND, pngChunk("tEXt", []byte("Comment\x00<svg onload=x>"))), mimeType: TypePNG, polyglot: true},
+		{name: "PNG with markup in an international text chunk", data: splice(pngFile, pngIEND, pngChunk("iTXt", []byte("XML\x00\x00\x00\x00\x00<html>"))), mimeType: TypePNG, polyglot: true},
+		{name: "PNG with a ZIP archive appended", data: append(bytes.Clone(pngFile), zipArchive()...), mimeType: TypePNG, polyglot: true},
+		{name: "PNG with a ZIP archive in a chunk", data: splice(pngFile, pngIEND, pngChunk("prVt", zipArchive())), mimeType: TypePNG, polyglot: true},
+
+		{name: "GIF with markup in a comment", data: splice(gifFile, len(gifFile)-1, []byte("\x21\xFE\x08<script>\x00")), mimeType: TypeGIF, polyglot: true},
+		{name: "GIF with an HTML page appended", data: append(bytes.Clone(gifFile), "<html>"...), mimeType: TypeGIF, polyglot: true},
+
+		{name: "WebP with markup in compressed data", data: webpFile, mimeType: TypeWebP},
+		{name: "WebP with an HTML page appended", data: append(bytes.Clone(webpFile), "<html>"...), mimeType: TypeWebP, polyglot: true},
+		{name: "MP4 with markup in media data", data: movie, mimeType: TypeMP4},
+		{name: "WebM with markup in a segment", data: webmFile, mimeType: TypeWebM},
+		{name: "WebM with an HTML page appended", data: append(bytes.Clone(webmFile), "<html>"...), mimeType: TypeWebM, polyglot: true},
+		{name: "WebM with a segment of unknown size", data: append(makeEBML("webm"), unknownSizeSegment...), mimeType: TypeWebM},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkEmbedded(bytes.NewReader(test.data), int64(len(test.data)), test.mimeType)
+			if test.polyglot && !errors.Is(err, ErrPolyglot) {
+				t.Errorf("checkEmbedded error = %v, want ErrPolyglot", err)
+			}
+			if !test.polyglot && err != nil {
+				t.Errorf("checkEmbedded: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package mediainfo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// layout locates the parts of a file checkEmbedded searches for embedded content
+type layout struct {
+	Text [][]byte // segments holding comments or metadata, which may carry text
+	End  int64    // where the container ends; later bytes were appended to it
+}
+
+// fileLayout walks the structure of a file of the given type. A walk stops at the first
+// invalid or truncated structure and treats what follows as appended; the format checks
+// of Inspect reject files that are actually malformed.
+func fileLayout(r io.ReaderAt, size int64, mimeType string) (layout, error) {
+	switch {
+	case mimeType == TypeJPEG:
+		return jpegLayout(r, size)
+	case mimeType == TypePNG:
+		return pngLayout(r, size)
+	case mimeType == TypeGIF:
+		return gifLayout(r, size)
+	case mimeType == TypeWebP, mimeType == TypeAVI:
+		return riffLayout(r, size)
+	case families[mimeType] == "matroska":
+		return matroskaLayout(r, size)
+	}
+	// The top-level boxes of ISO base media files have to fill the file (see readBoxes)
+	return layout{End: size}, nil
+}
+
+// jpegLayout walks the marker segments of a JPEG file up to the end of image marker;
+// comments (COM) and application segments (APPn, which hold Exif and XMP) are text
+func jpegLayout(r io.ReaderAt, size int64) (layout, error) {
+	var l layout
+	offset := int64(2) // start of image
+	for offset+2 <= size {
+		var marker [4]byte
+		if _, err := r.ReadAt(marker[:2], offset); err != nil {
+			return l, err
+		}
+		if marker[0] != 0xFF {
+			break
+		}
+		switch code := marker[1]; {
+		case code == 0xFF:
+			// fill byte
+			offset++
+			continue
+		case code == 0xD9:
+			l.End = offset + 2
+			return l, nil
+		case code == 0x01 || 0xD0 <= code && code <= 0xD7:
+			// markers without a segment
+			offset += 2
+			continue
+		}
+
+		if offset+4 > size {
+			break
+		}
+		if _, err := r.ReadAt(marker[2:4], offset+2); err != nil {
+			return l, err
+		}
+		length := int64(binary.BigEndian.Uint16(marker[2:4]))
+		if length < 2 || offset+2+length > size {
+			break
+		}
+		if code := marker[1]; code == 0xFE || 0xE0 <= code && code <= 0xEF {
+			text, err := readAt(r, offset+4, length-2, size)
+			if err != nil {
+				return l, err
+			}
+			l.Text = append(l.Text, text)
+		}
+		offset += 2 + length
+
+		// The entropy-coded data of a scan runs up to the next marker
+		if marker[1] == 0xDA {
+			var err error
+			if offset, err = skipEntropyData(r, offset, size); err != nil {
+				return l, err
+			}
+		}
+	}
+	l.End = min(offset, size)
+	return l, nil
+}
+
+// skipEntropyData returns the offset of the marker that ends entropy-coded data starting
+// at offset, or size when the file ends first. 0xFF bytes of the data are followed by a
+// zero byte, and restart markers are part of the data.
+func skipEntropyData(r io.ReaderAt, offset, size int64) (int64, error) {
+	reader := bufio.NewReader(io.NewSectionReader(r, offset, size-offset))
+	for {
+		c, err := reader.ReadByte()
+		if err == io.EOF {
+			return size, nil
+		} else if err != nil {
+			return 0, err
+		}
+		offset++
+		if c != 0xFF {
+			continue
+		}
+
+		next, err := reader.Peek(1)
+		if err == io.EOF {
+			return size, nil
+		} else if err != nil {
+			return 0, err
+		}
+		if code := next[0]; code == 0x00 || code == 0xFF || 0xD0 <= code && code <= 0xD7 {
+			continue
+		}
+		return offset - 1, nil
+	}
+}
+
+// pngLayout walks the chunks of a PNG file up to IEND; tEXt and iTXt chunks are text
+func pngLayout(r io.ReaderAt, size int64) (layout, error) {
+	var l layout
+	offset := int64(len(pngSignature))
+	for offset+12 <= size {
+		var header [8]byte
+		if _, err := r.ReadAt(header[:], offset); err != nil {
+			return l, err
+		}
+		// length, type, data and CRC
+		next := offset + 12 + int64(binary.BigEndian.Uint32(header[0:4]))
+		if next > size {
+			break
+		}
+		switch string(header[4:8]) {
+		case "tEXt", "iTXt":
+			text, err := readAt(r, offset+8, next-offset-12, size)
+			if err != nil {
+				return l, err
+			}
+			l.Text = append(l.Text, text)
+		case "IEND":
+			l.End = next
+			return l, nil
+		}
+		offset = next
+	}
+	l.End = offset
+	return l, nil
+}
+
+// gifLayout walks the blocks of a GIF file up to the trailer; comment, plain text and
+// application (XMP) extensions are text
+func gifLayout(r io.ReaderAt, size int64) (layout, error) {
+	var l layout
+	g := &gifReader{reader: bufio.NewReader(io.NewSectionReader(r, 0, size))}
+
+	// header and logical screen descriptor, followed by the global color table
+	screen, err := g.read(13)
+	if err == nil && screen[10]&0x80 != 0 {
+		err = g.skip(3 << (screen[10]&0x07 + 1))
+	}
+	for err == nil {
+		start := g.offset
+		var block []byte
+		if block, err = g.read(1); err != nil {
+			break
+		}
+
+		switch block[0] {
+		case 0x21:
+			var label []byte
+			if label, err = g.read(1); err != nil {
+				break
+			}
+			var text []byte
+			text, err = g.subBlocks(label[0] == 0xFE || label[0] == 0x01 || label[0] == 0xFF)
+			if len(text) > 0 {
+				l.Text = append(l.Text, text)
+			}
+		case 0x2C:
+			// image descriptor, local color table, LZW code size and the image data
+			var descriptor []byte
+			if descriptor, err = g.read(9); err != nil {
+				break
+			}
+			if descriptor[8]&0x80 != 0 {
+				if err = g.skip(3 << (descriptor[8]&0x07 + 1)); err != nil {
+					break
+				}
+			}
+			if err = g.skip(1); err == nil {
+				_, err = g.subBlocks(false)
+			}
+		case 0x3B:
+			l.End = g.offset
+			return l, nil
+		default:
+			l.End = start
+			return l, nil
+		}
+	}
+	if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return l, err
+	}
+	l.End = size
+	return l, nil
+}
+
+// gifReader reads a GIF file sequentially, keeping track of the offset
+type gifReader struct {
+	reader *bufio.Reader
+	offset int64
+}
+
+func (g *gifReader) read(n int) ([]byte, error) {
+	data := make([]byte, n)
+	read, err := io.ReadFull(g.reader, data)
+	g.offset += int64(read)
+	return data, err
+}
+
+func (g *gifReader) skip(n int) error {
+	skipped, err := g.reader.Discard(n)
+	g.offset += int64(skipped)
+	return err
+}
+
+// subBlocks reads a sequence of data sub-blocks, returning their content when keep is set
+func (g *gifReader) subBlocks(keep bool) ([]byte, error) {
+	var content []byte
+	for {
+		length, err := g.read(1)
+		if err != nil {
+			return content, err
+		}
+		if length[0] == 0 {
+			return content, nil
+		}
+		if !keep {
+			if err := g.skip(int(length[0])); err != nil {
+				return content, err
+			}
+			continue
+		}
+		data, err := g.read(int(length[0]))
+		content = append(content, data...)
+		if err != nil {
+			return content, err
+		}
+	}
+}
+
+// riffLayout reads where a RIFF file (WebP, AVI) ends from its header
+func riffLayout(r io.ReaderAt, size int64) (layout, error) {
+	header, err := readAt(r, 0, 8, size)
+	if err != nil || len(header) < 8 {
+		return layout{End: size}, err
+	}
+	// The declared length excludes the 8-byte header; odd lengths are padded
+	end := int64(binary.LittleEndian.Uint32(header[4:8])) + 8
+	end += end % 2
+	return layout{End: min(end, size)}, nil
+}
+
+// EBML IDs of the elements a Matroska file consists of
+const (
+	ebmlHeaderID = 0x1A45DFA3
+	segmentID    = 0x18538067
+	voidID       = 0xEC
+)
+
+// matroskaLayout walks the top-level elements of a Matroska file: the EBML header and
+// the segments. A segment of unknown size runs to the end of the file.
+func matroskaLayout(r io.ReaderAt, size int64) (layout, error) {
+	offset := int64(0)
+	for offset < size {
+		header, err := readAt(r, offset, 12, size)
+		if err != nil {
+			return layout{}, err
+		}
+		id, idLength := ebmlVarInt(header)
+		if idLength == 0 || idLength > 4 {
+			break
+		}
+		id |= 1 << (7 * idLength) // IDs keep their length marker
+		if id != ebmlHeaderID && id != segmentID && id != voidID {
+			break
+		}
+		length, sizeLength := ebmlVarInt(header[idLength:])
+		if sizeLength == 0 || length == 1<<(7*sizeLength)-1 {
+			return layout{End: size}, nil
+		}
+		next := offset + int64(idLength+sizeLength) + int64(length)
+		if next < offset || next > size {
+			return layout{End: size}, nil
+		}
+		offset = next
+	}
+	return layout{End: offset}, nil
+}
+
+// ebmlVarInt decodes a variable-length integer without its length marker and returns
+// its value and length, or a length of 0 when data does not hold a valid one
+func ebmlVarInt(data []byte) (uint64, int) {
+	if len(data) == 0 || data[0] == 0 {
+		return 0, 0
+	}
+	length := 1
+	for data[0]&(0x80>>(length-1)) == 0 {
+		length++
+	}
+	if length > len(data) {
+		return 0, 0
+	}
+	value := uint64(data[0] & (0xFF >> length))
+	for _, c := range data[1:length] {
+		value = value<<8 | uint64(c)
+	}
+	return value, length
+}
@@ -0,0 +1,119 @@
+package mediainfo
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Media types recognized from file content
+const (
+	TypeJPEG      = "image/jpeg"
+	TypePNG       = "image/png"
+	TypeGIF       = "image/gif"
+	TypeWebP      = "image/webp"
+	TypeHEIC      = "image/heic"
+	TypeHEIF      = "image/heif"
+	TypeAVIF      = "image/avif"
+	TypeMP4       = "video/mp4"
+	TypeQuickTime = "video/quicktime"
+	Type3GPP      = "video/3gpp"
+	TypeWebM      = "video/webm"
+	TypeMatroska  = "video/x-matroska"
+	TypeAVI       = "video/x-msvideo"
+)
+
+// aliases maps non-standard names (some stored by earlier versions) to the types above
+var aliases = map[string]string{
+	"image/jpg":           TypeJPEG,
+	"image/pjpeg":         TypeJPEG,
+	"image/x-png":         TypePNG,
+	"image/heic-sequence": TypeHEIC,
+	"image/heif-sequence": TypeHEIF,
+	"video/mov":           TypeQuickTime,
+	"video/avi":           TypeAVI,
+	"video/msvideo":       TypeAVI,
+	"video/x-m4v":         TypeMP4,
+	"video/matroska":      TypeMatroska,
+}
+
+// extensions maps file extensions to the type they are expected to contain
+var extensions = map[string]string{
+	".jpg":  TypeJPEG,
+	".jpeg": TypeJPEG,
+	".jpe":  TypeJPEG,
+	".jfif": TypeJPEG,
+	".png":  TypePNG,
+	".gif":  TypeGIF,
+	".webp": TypeWebP,
+	".heic": TypeHEIC,
+	".heif": TypeHEIF,
+	".avif": TypeAVIF,
+	".mp4":  TypeMP4,
+	".m4v":  TypeMP4,
+	".mov":  TypeQuickTime,
+	".qt":   TypeQuickTime,
+	".3gp":  Type3GPP,
+	".webm": TypeWebM,
+	".mkv":  TypeMatroska,
+	".avi":  TypeAVI,
+}
+
+// canonicalExtensions is the extension given to stored files of each type
+var canonicalExtensions = map[string]string{
+	TypeJPEG:      ".jpg",
+	TypePNG:       ".png",
+	TypeGIF:       ".gif",
+	TypeWebP:      ".webp",
+	TypeHEIC:      ".heic",
+	TypeHEIF:      ".heif",
+	TypeAVIF:      ".avif",
+	TypeMP4:       ".mp4",
+	TypeQuickTime: ".mov",
+	Type3GPP:      ".3gp",
+	TypeWebM:      ".webm",
+	TypeMatroska:  ".mkv",
+	TypeAVI:       ".avi",
+}
+
+// families groups types that share a container format; devices label them loosely, so
+// their extensions are interchangeable
+var families = map[string]string{
+	TypeHEIC:      "heif",
+	TypeHEIF:      "heif",
+	TypeAVIF:      "heif",
+	TypeMP4:       "bmff",
+	TypeQuickTime: "bmff",
+	Type3GPP:      "bmff",
+	TypeWebM:      "matroska",
+	TypeMatroska:  "matroska",
+}
+
+// Normalize lowercases a media type, drops its parameters and resolves aliases
+func Normalize(mimeType string) string {
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	if canonical, ok := aliases[mimeType]; ok {
+		return canonical
+	}
+	return mimeType
+}
+
+// Extension returns the extension stored files of mimeType get, or "" for unknown types
+func Extension(mimeType string) string {
+	return canonicalExtensions[Normalize(mimeType)]
+}
+
+// MatchesExtension reports whether the extension of filename fits content of mimeType.
+// Names without an extension, or with one that is not a media extension, always fit;
+// stored files are renamed with the canonical extension anyway.
+func MatchesExtension(mimeType, filename string) bool {
+	expected, ok := extensions[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return true
+	}
+	if expected == mimeType {
+		return true
+	}
+	family := families[expected]
+	return family != "" && family == families[mimeType]
+}
@@ -0,0 +1,262 @@
+package mediainfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNoVideoMetadata is returned for videos whose container metadata cannot be read
+// (formats other than MP4/QuickTime)
+var ErrNoVideoMetadata = errors.New("video container metadata not supported")
+
+// VideoInfo describes the video track of a movie
+type VideoInfo struct {
+	Duration time.Duration
+	Width    int // display size, after rotation
+	Height   int
+	Codec    string // e.g. h264, hevc, av1; the sample entry type for other codecs
+	Rotation int    // clockwise degrees from the track matrix: 0, 90, 180 or 270
+}
+
+// codecNames maps sample entry types to codec names
+var codecNames = map[string]string{
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"dvh1": "hevc",
+	"dvhe": "hevc",
+	"av01": "av1",
+	"vp08": "vp8",
+	"vp09": "vp9",
+	"mp4v": "mpeg4",
+	"s263": "h263",
+	"jpeg": "mjpeg",
+	"apcn": "prores",
+	"apch": "prores",
+	"apcs": "prores",
+	"apco": "prores",
+	"ap4h": "prores",
+}
+
+// ParseVideo reads the duration, display size and codec of an MP4/QuickTime movie from
+// its movie header (moov/mvhd) and the first video track (moov/trak)
+func ParseVideo(r io.ReaderAt, size int64, mimeType string) (*VideoInfo, error) {
+	if families[Normalize(mimeType)] != "bmff" {
+		return nil, ErrNoVideoMetadata
+	}
+
+	boxes, err := readBoxes(r, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	moov, ok := findBox(boxes, "moov")
+	if !ok {
+		return nil, fmt.Errorf("%w: no movie header", ErrMalformed)
+	}
+	if boxes, err = childBoxes(r, moov, false); err != nil {
+		return nil, err
+	}
+
+	info := &VideoInfo{}
+	if mvhd, ok := findBox(boxes, "mvhd"); ok {
+		if info.Duration, err = movieDuration(r, mvhd); err != nil {
+			return nil, err
+		}
+	}
+
+	found := false
+	for _, b := range boxes {
+		if b.Type != "trak" {
+			continue
+		}
+		track, isVideo, err := videoTrack(r, b)
+		if err != nil {
+			return nil, err
+		}
+		if !isVideo {
+			continue
+		}
+		// Fragmented files leave the movie duration empty
+		if info.Duration == 0 {
+			info.Duration = track.Duration
+		}
+		info.Width, info.Height = track.Width, track.Height
+		info.Codec, info.Rotation = track.Codec, track.Rotation
+		found = true
+		break
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: no video track", ErrMalformed)
+	}
+	return info, nil
+}
+
+// movieDuration reads the duration of a movie (mvhd) or media (mdhd) header, which share
+// the layout of their start
+func movieDuration(r io.ReaderAt, header box) (time.Duration, error) {
+	version, err := boxBody(r, header, 1)
+	if err != nil {
+		return 0, err
+	}
+
+	// version 1 uses 64 bit times
+	if version[0] == 1 {
+		body, err := boxBody(r, header, 32)
+		if err != nil {
+			return 0, err
+		}
+		return scaledDuration(binary.BigEndian.Uint64(body[24:32]), binary.BigEndian.Uint32(body[20:24])), nil
+	}
+	body, err := boxBody(r, header, 20)
+	if err != nil {
+		return 0, err
+	}
+	return scaledDuration(uint64(binary.BigEndian.Uint32(body[16:20])), binary.BigEndian.Uint32(body[12:16])), nil
+}
+
+// videoTrack reads a track; isVideo is false for tracks other than video (sound, text)
+func videoTrack(r io.ReaderAt, trak box) (info *VideoInfo, isVideo bool, err error) {
+	boxes, err := childBoxes(r, trak, false)
+	if err != nil {
+		return nil, false, err
+	}
+	mdia, ok := findBox(boxes, "mdia")
+	if !ok {
+		return nil, false, nil
+	}
+	media, err := childBoxes(r, mdia, false)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hdlr, ok := findBox(media, "hdlr")
+	if !ok {
+		return nil, false, nil
+	}
+	handler, err := boxBody(r, hdlr, 12)
+	if err != nil {
+		return nil, false, err
+	}
+	if string(handler[8:12]) != "vide" {
+		return nil, false, nil
+	}
+
+	info = &VideoInfo{}
+	if tkhd, ok := findBox(boxes, "tkhd"); ok {
+		if err := readTrackHeader(r, tkhd, info); err != nil {
+			return nil, true, err
+		}
+	}
+	if mdhd, ok := findBox(media, "mdhd"); ok {
+		if info.Duration, err = movieDuration(r, mdhd); err != nil {
+			return nil, true, err
+		}
+	}
+	if info.Codec, err = sampleCodec(r, media); err != nil {
+		return nil, true, err
+	}
+	return info, true, nil
+}
+
+// readTrackHeader reads the display size and rotation of a track header (tkhd)
+func readTrackHeader(r io.ReaderAt, tkhd box, info *VideoInfo) error {
+	version, err := boxBody(r, tkhd, 1)
+	if err != nil {
+		return err
+	}
+	// matrix and size follow the times, which are 64 bit in version 1
+	offset := 40
+	if version[0] == 1 {
+		offset = 52
+	}
+	body, err := boxBody(r, tkhd, offset+44)
+	if err != nil {
+		return err
+	}
+
+	matrix := body[offset : offset+36]
+	a := int32(binary.BigEndian.Uint32(matrix[0:4]))
+	b := int32(binary.BigEndian.Uint32(matrix[4:8]))
+	switch {
+	case a == 0 && b > 0:
+		info.Rotation = 90
+	case a < 0 && b == 0:
+		info.Rotation = 180
+	case a == 0 && b < 0:
+		info.Rotation = 270
+	}
+
+	// 16.16 fixed point
+	info.Width = int(binary.BigEndian.Uint32(body[offset+36:offset+40]) >> 16)
+	info.Height = int(binary.BigEndian.Uint32(body[offset+40:offset+44]) >> 16)
+	if info.Rotation == 90 || info.Rotation == 270 {
+		info.Width, info.Height = info.Height, info.Width
+	}
+	return nil
+}
+
+// sampleCodec returns the codec of the first sample description (minf/stbl/stsd)
+func sampleCodec(r io.ReaderAt, media []box) (string, error) {
+	boxes := media
+	for _, typ := range []string{"minf", "stbl"} {
+		parent, ok := findBox(boxes, typ)
+		if !ok {
+			return "", nil
+		}
+		var err error
+		if boxes, err = childBoxes(r, parent, false); err != nil {
+			return "", err
+		}
+	}
+	stsd, ok := findBox(boxes, "stsd")
+	if !ok {
+		return "", nil
+	}
+
+	// version, flags and the entry count precede the sample entries
+	start, end := stsd.Body()
+	if end-start < 8 {
+		return "", fmt.Errorf("%w: short stsd box", ErrMalformed)
+	}
+	entries, err := readBoxes(r, start+8, end)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", nil
+	}
+
+	typ := entries[0].Type
+	if name, ok := codecNames[typ]; ok {
+		return name, nil
+	}
+	return strings.TrimSpace(strings.ToLower(typ)), nil
+}
+
+// boxBody reads the first n bytes of the content of b
+func boxBody(r io.ReaderAt, b box, n int) ([]byte, error) {
+	start, end := b.Body()
+	if end-start < int64(n) {
+		return nil, fmt.Errorf("%w: short %q box", ErrMalformed, b.Type)
+	}
+	body := make([]byte, n)
+	if _, err := r.ReadAt(body, start); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// scaledDuration converts a duration in timescale units per second
+func scaledDuration(duration uint64, timescale uint32) time.Duration {
+	if timescale == 0 || duration == 0 || duration == 1<<64-1 || duration == 1<<32-1 {
+		return 0
+	}
+	seconds := duration / uint64(timescale)
+	rest := duration % uint64(timescale)
+	return time.Duration(seconds)*time.Second + time.Duration(rest)*time.Second/time.Duration(timescale)
+}
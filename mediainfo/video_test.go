@@ -0,0 +1,240 @@
+package mediainfo
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Track matrices (16.16 fixed point a, b and 2.30 w, as written by encoders)
+var (
+	identityMatrix = [9]uint32{0x10000, 0, 0, 0, 0x10000, 0, 0, 0, 0x40000000}
+	rotate90       = [9]uint32{0, 0x10000, 0, 0xFFFF0000, 0, 0, 0, 0, 0x40000000}
+	rotate180      = [9]uint32{0xFFFF0000, 0, 0, 0, 0xFFFF0000, 0, 0, 0, 0x40000000}
+	rotate270      = [9]uint32{0, 0xFFFF0000, 0, 0x10000, 0, 0, 0, 0, 0x40000000}
+)
+
+// makeMediaHeader builds a movie (mvhd) or media (mdhd) header; version 1 uses 64 bit
+// times
+func makeMediaHeader(typ string, version byte, timescale uint32, duration uint64) []byte {
+	if version == 1 {
+		return makeFullBox(typ, 1, u64(0, 0), u32(timescale), u64(duration), make([]byte, 80))
+	}
+	return makeFullBox(typ, 0, u32(0, 0, timescale, uint32(duration)), make([]byte, 80))
+}
+
+// makeTrackHeader builds a track header with the given matrix and display size
+func makeTrackHeader(version byte, matrix [9]uint32, width, height uint32) []byte {
+	times := u32(0, 0, 1, 0, 0) // creation, modification, track ID, reserved, duration
+	if version == 1 {
+		times = bytes.Join([][]byte{u64(0, 0), u32(1, 0), u64(0)}, nil)
+	}
+	// reserved, layer, alternate group, volume and reserved precede the matrix
+	return makeFullBox("tkhd", version, times, make([]byte, 16), u32(matrix[:]...), u32(width<<16, height<<16))
+}
+
+// makeTrack builds a track of the given handler type (vide, soun) with a media header
+// and a sample description of type codec
+func makeTrack(handler string, tkhd, mdhd []byte, codec string) []byte {
+	hdlr := makeFullBox("hdlr", 0, u32(0), []byte(handler), make([]byte, 13))
+	stsd := makeFullBox("stsd", 0, u32(1), makeBox(codec, make([]byte, 78)))
+	minf := makeBox("minf", makeBox("stbl", stsd))
+	return makeBox("trak", tkhd, makeBox("mdia", mdhd, hdlr, minf))
+}
+
+// makeMovie builds an MP4 file holding the movie header and tracks
+func makeMovie(mvhd []byte, tracks ...[]byte) []byte {
+	moov := makeBox("moov", append([][]byte{mvhd}, tracks...)...)
+	return bytes.Join([][]byte{makeFtyp("isom", "isom"), moov, makeBox("mdat")}, nil)
+}
+
+func TestParseVideo(t *testing.T) {
+	videoV0 := makeTrack("vide", makeTrackHeader(0, identityMatrix, 1920, 1080), makeMediaHeader("mdhd", 0, 90000, 900000), "avc1")
+	audio := makeTrack("soun", makeTrackHeader(0, identityMatrix, 0, 0), makeMediaHeader("mdhd", 0, 44100, 441000), "mp4a")
+	truncatedTkhd := makeFullBox("tkhd", 0, make([]byte, 60))
+
+	tests := []struct {
+		name     string
+		data     []byte
+		mimeType string
+		want     VideoInfo
+		wantErr  error
+	}{
+		{
+			name: "version 0 headers",
+			data: makeMovie(makeMediaHeader("mvhd", 0, 1000, 10500), videoV0),
+			want: VideoInfo{Duration: 10500 * time.Millisecond, Width: 1920, Height: 1080, Codec: "h264"},
+		},
+		{
+			name: "version 1 headers",
+			data: makeMovie(makeMediaHeader("mvhd", 1, 600, 600*5000),
+				makeTrack("vide", makeTrackHeader(1, identityMatrix, 3840, 2160), makeMediaHeader("mdhd", 1, 600, 600*5000), "hvc1")),
+			want: VideoInfo{Duration: 5000 * time.Second, Width: 3840, Height: 2160, Codec: "hevc"},
+		},
+		{
+			name: "duration longer than 32 bits of units",
+			data: makeMovie(makeMediaHeader("mvhd", 1, 90000, 1<<33), videoV0),
+			want: VideoInfo{Duration: time.Duration(1<<33) * time.Second / 90000, Width: 1920, Height: 1080, Codec: "h264"},
+		},
+		{
+			name: "rotated 90 degrees",
+			data: makeMovie(makeMediaHeader("mvhd", 0, 1000, 3000),
+				makeTrack("vide", makeTrackHeader(0, rotate90, 1920, 1080), makeMediaHeader("mdhd", 0, 600, 1800), "avc1")),
+			want: VideoInfo{Duration: 3 * time.Second, Width: 1080, Height: 1920, Codec: "h264", Rotation: 90},
+		},
+		{
+			name: "rotated 180 degrees",
+			data: makeMovie(makeMediaHeader("mvhd", 0, 1000, 3000),
+				makeTrack("vide", makeTrackHeader(0, rotate180, 1920, 1080), makeMediaHeader("mdhd", 0, 600, 1800), "avc1")),
+			want: VideoInfo{Duration: 3 * time.Second, Width: 1920, Height: 1080, Codec: "h264", Rotation: 180},
+		},
+		{
+			name: "rotated 270 degrees in a version 1 track header",
+			data: makeMovie(makeMediaHeader("mvhd", 0, 1000, 3000),
+				makeTrack("vide", makeTrackHeader(1, rotate270, 1280, 720), makeMediaHeader("mdhd", 0, 600, 1800), "av01")),
+			want: VideoInfo{Duration: 3 * time.Second, Width: 720, Height: 1280, Codec: "av1", Rotation: 270},
+		},
+		{
+			name: "fragmented file with an empty movie duration",
+			data: makeMovie(makeMediaHeader("mvhd", 0, 1000, 0), videoV0),
+			want: VideoInfo{Duration: 10 * time.Second, Width: 1920, Height: 1080, Codec: "h264"},
+		},
+		{
+			name: "unknown movie duration",
+			data: makeMovie(makeMediaHeader("mvhd", 1, 1000, 1<<64-1), videoV0),
+			want: VideoInfo{Duration: 10 * time.Second, Width: 1920, Height: 1080, Codec: "h264"},
+		},
+		{
+			name: "sound track before the video track",
+			data: makeMovie(makeMediaHeader("mvhd", 0, 1000, 10000), audio, videoV0),
+			want: VideoInfo{Duration: 10 * time.Second, Width: 1920, Height: 1080, Codec: "h264"},
+		},
+		{
+			name: "unknown codec",
+			data: makeMovie(makeMediaHeader("mvhd", 0, 1000, 10000),
+				makeTrack("vide", makeTrackHeader(0, identityMatrix, 640, 480), makeMediaHeader("mdhd", 0, 1000, 10000), "XVID")),
+			want: VideoInfo{Duration: 10 * time.Second, Width: 640, Height: 480, Codec: "xvid"},
+		},
+		{
+			name:     "QuickTime",
+			data:     makeMovie(makeMediaHeader("mvhd", 0, 600, 1200), videoV0),
+			mimeType: TypeQuickTime,
+			want:     VideoInfo{Duration: 2 * time.Second, Width: 1920, Height: 1080, Codec: "h264"},
+		},
+		{name: "no video track", data: makeMovie(makeMediaHeader("mvhd", 0, 1000, 10000), audio), wantErr: ErrMalformed},
+		{name: "no movie header", data: append(makeFtyp("isom", "isom"), makeBox("mdat")...), wantErr: ErrMalformed},
+		{
+			name:    "truncated track header",
+			data:    makeMovie(makeMediaHeader("mvhd", 0, 1000, 10000), makeTrack("vide", truncatedTkhd, makeMediaHeader("mdhd", 0, 1000, 1000), "avc1")),
+			wantErr: ErrMalformed,
+		},
+		{name: "truncated file", data: makeMovie(makeMediaHeader("mvhd", 0, 1000, 10000), videoV0)[:100], wantErr: ErrMalformed},
+		{name: "WebM", data: makeEBML("webm"), mimeType: TypeWebM, wantErr: ErrNoVideoMetadata},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mimeType := test.mimeType
+			if mimeType == "" {
+				mimeType = TypeMP4
+			}
+			info, err := ParseVideo(bytes.NewReader(test.data), int64(len(test.data)), mimeType)
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("ParseVideo error = %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseVideo: %v", err)
+			}
+			if *info != test.want {
+				t.Errorf("ParseVideo = %+v, want %+v", *info, test.want)
+			}
+		})
+	}
+}
+
+// parseBox reads data, which holds a single box
+func parseBox(t *testing.T, data []byte) (*bytes.Reader, box) {
+	t.Helper()
+	r := bytes.NewReader(data)
+	boxes, err := readBoxes(r, 0, int64(len(data)))
+	if err != nil || len(boxes) != 1 {
+		t.Fatalf("readBoxes = %v, %v; want a single box", boxes, err)
+	}
+	return r, boxes[0]
+}
+
+func TestMovieDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  []byte
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "version 0", header: makeMediaHeader("mvhd", 0, 1000, 1500), want: 1500 * time.Millisecond},
+		{name: "version 1", header: makeMediaHeader("mdhd", 1, 48000, 48000*3600), want: time.Hour},
+		{name: "fractional seconds", header: makeMediaHeader("mdhd", 0, 30000, 1001), want: 1001 * time.Second / 30000},
+		{name: "zero timescale", header: makeMediaHeader("mvhd", 0, 0, 1500)},
+		{name: "unknown duration", header: makeMediaHeader("mvhd", 0, 1000, 1<<32-1)},
+		{name: "version 0 too short", header: makeFullBox("mvhd", 0, u32(0, 0, 1000)), wantErr: true},
+		{name: "version 1 too short", header: makeFullBox("mvhd", 1, u64(0, 0), u32(1000)), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, header := parseBox(t, test.header)
+			duration, err := movieDuration(r, header)
+			if test.wantErr {
+				if !errors.Is(err, ErrMalformed) {
+					t.Fatalf("movieDuration error = %v, want ErrMalformed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("movieDuration: %v", err)
+			}
+			if duration != test.want {
+				t.Errorf("movieDuration = %v, want %v", duration, test.want)
+			}
+		})
+	}
+}
+
+func TestReadTrackHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		tkhd    []byte
+		want    VideoInfo
+		wantErr bool
+	}{
+		{name: "version 0", tkhd: makeTrackHeader(0, identityMatrix, 1920, 1080), want: VideoInfo{Width: 1920, Height: 1080}},
+		{name: "version 1", tkhd: makeTrackHeader(1, identityMatrix, 1920, 1080), want: VideoInfo{Width: 1920, Height: 1080}},
+		{name: "rotated 90 degrees", tkhd: makeTrackHeader(0, rotate90, 1920, 1080), want: VideoInfo{Width: 1080, Height: 1920, Rotation: 90}},
+		{name: "rotated 90 degrees, version 1", tkhd: makeTrackHeader(1, rotate90, 1920, 1080), want: VideoInfo{Width: 1080, Height: 1920, Rotation: 90}},
+		{name: "rotated 180 degrees", tkhd: makeTrackHeader(0, rotate180, 1920, 1080), want: VideoInfo{Width: 1920, Height: 1080, Rotation: 180}},
+		{name: "rotated 270 degrees", tkhd: makeTrackHeader(0, rotate270, 1920, 1080), want: VideoInfo{Width: 1080, Height: 1920, Rotation: 270}},
+		{name: "version 0 too short", tkhd: makeFullBox("tkhd", 0, make([]byte, 60)), wantErr: true},
+		// A version 1 header is 12 bytes longer than a version 0 one
+		{name: "version 1 with a version 0 length", tkhd: makeFullBox("tkhd", 1, make([]byte, 80)), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, tkhd := parseBox(t, test.tkhd)
+			var info VideoInfo
+			err := readTrackHeader(r, tkhd, &info)
+			if test.wantErr {
+				if !errors.Is(err, ErrMalformed) {
+					t.Fatalf("readTrackHeader error = %v, want ErrMalformed", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readTrackHeader: %v", err)
+			}
+			if info != test.want {
+				t.Errorf("readTrackHeader = %+v, want %+v", info, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"map-memories-api/database"
+	"map-memories-api/models"
+	"map-memories-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errInvalidAPIToken = errors.New("invalid or expired API token")
+
+// apiTokenTouchInterval limits how often a token's last use is written
+const apiTokenTouchInterval = time.Minute
+
+// impliedScopes lists the read scope each write scope includes
+var impliedScopes = map[string]string{
+	models.ScopeMemoriesWrite:  models.ScopeMemoriesRead,
+	models.ScopeLocationsWrite: models.ScopeLocationsRead,
+	models.ScopeMediaWrite:     models.ScopeMediaRead,
+}
+
+// authenticateAPIToken looks up a personal API token and its owner and records its use
+func authenticateAPIToken(token, clientIP string) (*models.APIToken, *models.User, error) {
+	var apiToken models.APIToken
+	if err := database.DB.Where("token_hash = ?", utils.HashToken(token)).First(&apiToken).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, errInvalidAPIToken
+		}
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if apiToken.IsExpired(now) {
+		return nil, nil, errInvalidAPIToken
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, apiToken.UserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil, errInvalidAPIToken
+		}
+		return nil, nil, err
+	}
+
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) >= apiTokenTouchInterval {
+		if err := database.DB.Model(&apiToken).Updates(map[string]interface{}{
+			"last_used_at": now,
+			"last_used_ip": clientIP,
+		}).Error; err != nil {
+			log.Printf("Failed to update last use of API token %d: %v", apiToken.ID, err)
+		}
+	}
+
+	return &apiToken, &user, nil
+}
+
+// setAPITokenContext sets the same user context as a JWT plus the token's scopes
+func setAPITokenContext(c *gin.Context, apiToken *models.APIToken, user *models.User) {
+	c.Set("user_id", user.ID)
+	c.Set("user_uuid", user.UUID.String())
+	c.Set("user_email", user.Email)
+	c.Set("user_username", user.Username)
+	c.Set("user_role", user.Role)
+	c.Set("api_token_id", apiToken.ID)
+	c.Set("token_scopes", []string(apiToken.Scopes))
+}
+
+// GetTokenScopes returns the scopes of the API token the request was authenticated with.
+// The second value is false for requests that are not authenticated with an API token.
+func GetTokenScopes(c *gin.Context) ([]string, bool) {
+	value, exists := c.Get("token_scopes")
+	if !exists {
+		return nil, false
+	}
+
+	scopes, ok := value.([]string)
+	return scopes, ok
+}
+
+// IsAPITokenRequest reports whether the request was authenticated with a personal API token
+func IsAPITokenRequest(c *gin.Context) bool {
+	_, exists := c.Get("api_token_id")
+	return exists
+}
+
+// HasScope reports whether the request may act with the given scope. Requests
+// authenticated with a session (or not at all) are not limited by scopes.
+func HasScope(c *gin.Context, scope string) bool {
+	scopes, isToken := GetTokenScopes(c)
+	if !isToken {
+		return true
+	}
+
+	for _, granted := range scopes {
+		if granted == scope || impliedScopes[granted] == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope limits API tokens on a route group: read requests (GET, HEAD) need the
+// read scope and everything else the write scope. Pass an empty write scope for groups
+// that only read.
+func RequireScope(readScope, writeScope string) gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		scope := writeScope
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			scope = readScope
+		}
+
+		allowed := HasScope(c, scope)
+		if scope == "" {
+			allowed = !IsAPITokenRequest(c)
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, models.ErrorResponseWithCode(
+				"API token is missing the required scope",
+				"INSUFFICIENT_SCOPE",
+				map[string]interface{}{"required_scope": scope},
+			))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	})
+}
+
+// RequireSessionAuth rejects API tokens on routes that manage the account itself
+// (passwords, two-factor authentication, tokens) or need admin access
+func RequireSessionAuth() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		if IsAPITokenRequest(c) {
+			c.JSON(http.StatusForbidden, models.ErrorResponseWithCode(
+				"This endpoint cannot be used with an API token",
+				"API_TOKEN_NOT_ALLOWED",
+				nil,
+			))
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	})
+}
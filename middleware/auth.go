@@ -1,9 +1,11 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"map-memories-api/models"
 	"map-memories-api/utils"
@@ -11,7 +13,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware validates JWT tokens and sets user context
+// AuthMiddleware validates JWTs or personal API tokens and sets user context
 func AuthMiddleware() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
@@ -47,6 +49,32 @@ func AuthMiddleware() gin.HandlerFunc {
 			}
 		}
 
+		// Personal API tokens are accepted alongside JWTs; their scopes are checked per route group
+		if utils.IsAPIToken(token) {
+			apiToken, user, err := authenticateAPIToken(token, c.ClientIP())
+			if err != nil {
+				if err == errInvalidAPIToken {
+					c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+						"Invalid or expired API token",
+						"UNAUTHORIZED",
+						nil,
+					))
+				} else {
+					c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+						"Failed to verify API token",
+						"INTERNAL_ERROR",
+						nil,
+					))
+				}
+				c.Abort()
+				return
+			}
+
+			setAPITokenContext(c, apiToken, user)
+			c.Next()
+			return
+		}
+
 		claims, err := utils.VerifyJWT(token)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
@@ -58,12 +86,32 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Tokens stop working once their session is revoked (logout, password change)
+		user, err := sessionUser(claims)
+		if err == errSessionRevoked {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
+				"Session has been revoked",
+				"SESSION_REVOKED",
+				nil,
+			))
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponseWithCode(
+				"Failed to verify session",
+				"INTERNAL_ERROR",
+				nil,
+			))
+			c.Abort()
+			return
+		}
+		if err := touchSession(claims, time.Now()); err != nil {
+			log.Printf("Failed to update last use of session of user %d: %v", claims.UserID, err)
+		}
+
 		// Set user information in context
-		c.Set("user_id", claims.UserID)
-		c.Set("user_uuid", claims.UserUUID)
-		c.Set("user_email", claims.Email)
-		c.Set("user_username", claims.Username)
-		c.Set("claims", claims)
+		setSessionContext(c, claims, user)
 
 		c.Next()
 	})
@@ -76,15 +124,20 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 		
 		if authHeader != "" {
 			token, err := utils.ExtractBearerToken(authHeader)
-			if err == nil {
+			if err == nil && utils.IsAPIToken(token) {
+				if apiToken, user, err := authenticateAPIToken(token, c.ClientIP()); err == nil {
+					setAPITokenContext(c, apiToken, user)
+					c.Set("authenticated", true)
+				}
+			} else if err == nil {
 				claims, err := utils.VerifyJWT(token)
+				var user *models.User
+				if err == nil {
+					user, err = sessionUser(claims)
+				}
 				if err == nil {
 					// Set user information in context if token is valid
-					c.Set("user_id", claims.UserID)
-					c.Set("user_uuid", claims.UserUUID)
-					c.Set("user_email", claims.Email)
-					c.Set("user_username", claims.Username)
-					c.Set("claims", claims)
+					setSessionContext(c, claims, user)
 					c.Set("authenticated", true)
 				}
 			}
@@ -94,6 +147,27 @@ func OptionalAuthMiddleware() gin.HandlerFunc {
 	})
 }
 
+// setSessionContext sets the user context of a request authenticated with a JWT
+func setSessionContext(c *gin.Context, claims *utils.JWTClaims, user *models.User) {
+	c.Set("user_id", user.ID)
+	c.Set("user_uuid", user.UUID.String())
+	c.Set("user_email", user.Email)
+	c.Set("user_username", user.Username)
+	c.Set("user_role", user.Role)
+	c.Set("claims", claims)
+}
+
+// GetCurrentClaims extracts the JWT claims of the current request
+func GetCurrentClaims(c *gin.Context) (*utils.JWTClaims, bool) {
+	value, exists := c.Get("claims")
+	if !exists {
+		return nil, false
+	}
+
+	claims, ok := value.(*utils.JWTClaims)
+	return claims, ok
+}
+
 // GetCurrentUserID extracts the current user ID from context
 func GetCurrentUserID(c *gin.Context) (uint, bool) {
 	userID, exists := c.Get("user_id")
@@ -127,6 +201,40 @@ func GetCurrentUserEmail(c *gin.Context) (string, bool) {
 	return email, ok
 }
 
+// GetCurrentUserRole extracts the current user role from context
+func GetCurrentUserRole(c *gin.Context) (string, bool) {
+	userRole, exists := c.Get("user_role")
+	if !exists {
+		return "", false
+	}
+
+	role, ok := userRole.(string)
+	return role, ok
+}
+
+// GetViewer describes the current caller for rendering responses; anonymous callers get the zero Viewer
+func GetViewer(c *gin.Context) models.Viewer {
+	userID, _ := GetCurrentUserID(c)
+	return models.Viewer{
+		UserID:  userID,
+		IsAdmin: IsAdmin(c),
+	}
+}
+
+// IsAdmin checks if the current user has the admin role
+func IsAdmin(c *gin.Context) bool {
+	role, ok := GetCurrentUserRole(c)
+	return ok && role == models.RoleAdmin
+}
+
+// IsModerator checks if the current user can moderate content (moderators and admins)
+func IsModerator(c *gin.Context) bool {
+	if role, ok := GetCurrentUserRole(c); ok && role == models.RoleModerator {
+		return true
+	}
+	return IsAdmin(c)
+}
+
 // IsAuthenticated checks if the current request is authenticated
 func IsAuthenticated(c *gin.Context) bool {
 	_, exists := c.Get("user_id")
@@ -170,10 +278,7 @@ func RequireOwnership() gin.HandlerFunc {
 // AdminMiddleware ensures only admin users can access the endpoint
 func AdminMiddleware() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
-		// For now, we'll implement a simple admin check
-		// In a real application, you'd check user roles from the database
-		userEmail, exists := GetCurrentUserEmail(c)
-		if !exists {
+		if !IsAuthenticated(c) {
 			c.JSON(http.StatusUnauthorized, models.ErrorResponseWithCode(
 				"Authentication required",
 				"UNAUTHORIZED",
@@ -183,17 +288,7 @@ func AdminMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Simple admin check - in production, use proper role-based access control
-		adminEmails := []string{"admin@mapmemories.com", "administrator@mapmemories.com"}
-		isAdmin := false
-		for _, adminEmail := range adminEmails {
-			if strings.EqualFold(userEmail, adminEmail) {
-				isAdmin = true
-				break
-			}
-		}
-
-		if !isAdmin {
+		if !IsAdmin(c) {
 			c.JSON(http.StatusForbidden, models.ErrorResponseWithCode(
 				"Admin access required",
 				"FORBIDDEN",
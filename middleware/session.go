@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"errors"
+	"time"
+
+	"map-memories-api/database"
+	"map-memories-api/models"
+	"map-memories-api/utils"
+
+	"gorm.io/gorm"
+)
+
+var errSessionRevoked = errors.New("session has been revoked")
+
+// sessionTouchInterval limits how often a session's last use is written
+const sessionTouchInterval = time.Minute
+
+// SessionTokenHash returns the key a token's session is stored under
+func SessionTokenHash(claims *utils.JWTClaims) string {
+	return utils.HashToken(claims.ID)
+}
+
+// sessionUser loads the user of a token whose session still exists and has not expired.
+// Role, email and username come from the user row, so a changed role applies to the
+// next request rather than when the token expires.
+func sessionUser(claims *utils.JWTClaims) (*models.User, error) {
+	var user models.User
+	err := database.DB.Select("id", "uuid", "email", "username", "role").
+		Where("id = ? AND EXISTS (?)", claims.UserID,
+			database.DB.Model(&models.UserSession{}).Select("1").
+				Where("user_id = mm_users.id AND token_hash = ? AND expires_at > ?", SessionTokenHash(claims), time.Now())).
+		Take(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errSessionRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// touchSession records that the token's session was just used, at most once per sessionTouchInterval
+func touchSession(claims *utils.JWTClaims, now time.Time) error {
+	return database.DB.Model(&models.UserSession{}).
+		Where("user_id = ? AND token_hash = ?", claims.UserID, SessionTokenHash(claims)).
+		Where("last_used_at IS NULL OR last_used_at < ?", now.Add(-sessionTouchInterval)).
+		Update("last_used_at", now).Error
+}
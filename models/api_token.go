@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// API token scopes. A write scope also grants the matching read scope.
+const (
+	ScopeProfileRead    = "profile:read"
+	ScopeMemoriesRead   = "memories:read"
+	ScopeMemoriesWrite  = "memories:write"
+	ScopeLocationsRead  = "locations:read"
+	ScopeLocationsWrite = "locations:write"
+	ScopeMediaRead      = "media:read"
+	ScopeMediaWrite     = "media:write"
+)
+
+// APIToken is a long-lived personal access token for scripts and integrations.
+// Only the SHA-256 hash of the token is stored; the token itself is shown once.
+type APIToken struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	UUID       uuid.UUID      `json:"uuid" gorm:"type:uuid;uniqueIndex;not null"`
+	UserID     uint           `json:"user_id" gorm:"not null;index"`
+	Name       string         `json:"name" gorm:"size:100;not null"`
+	Prefix     string         `json:"prefix" gorm:"size:20;not null"` // start of the token, to recognize it in lists
+	TokenHash  string         `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Scopes     pq.StringArray `json:"scopes" gorm:"type:text[]"`
+	ExpiresAt  *time.Time     `json:"expires_at"` // nil means the token does not expire
+	LastUsedAt *time.Time     `json:"last_used_at"`
+	LastUsedIP string         `json:"last_used_ip" gorm:"size:45"`
+	CreatedAt  time.Time      `json:"created_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+func (APIToken) TableName() string {
+	return "mm_api_tokens"
+}
+
+// BeforeCreate sets UUID before creating API token
+func (t *APIToken) BeforeCreate(tx *gorm.DB) error {
+	if t.UUID == uuid.Nil {
+		t.UUID = uuid.New()
+	}
+	return nil
+}
+
+// IsExpired reports whether the token has passed its expiry
+func (t *APIToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// APITokenCreateRequest represents the request for creating an API token
+type APITokenCreateRequest struct {
+	Name          string   `json:"name" validate:"required,min=1,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=profile:read memories:read memories:write locations:read locations:write media:read media:write"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=3650"` // omit for a token that does not expire
+}
+
+// APITokenResponse represents an API token in API responses
+type APITokenResponse struct {
+	UUID       uuid.UUID  `json:"uuid"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// APITokenCreatedResponse includes the token itself, which is only returned on creation
+type APITokenCreatedResponse struct {
+	APITokenResponse
+	Token string `json:"token"`
+}
+
+// ToResponse converts APIToken to APITokenResponse
+func (t *APIToken) ToResponse() APITokenResponse {
+	return APITokenResponse{
+		UUID:       t.UUID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     t.Scopes,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		LastUsedIP: t.LastUsedIP,
+		CreatedAt:  t.CreatedAt,
+	}
+}
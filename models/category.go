@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+)
+
+// Category represents a point-of-interest type such as "restaurant" or "beach".
+// Categories form a two-level taxonomy through ParentID.
+type Category struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	Slug         string    `json:"slug" gorm:"size:50;uniqueIndex;not null"`
+	Name         string    `json:"name" gorm:"size:100;not null"`
+	Icon         string    `json:"icon" gorm:"size:50"`
+	ParentID     *uint     `json:"parent_id" gorm:"index"`
+	DisplayOrder int       `json:"display_order" gorm:"default:0"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// Relationships
+	Parent   *Category  `json:"-" gorm:"foreignKey:ParentID"`
+	Children []Category `json:"children,omitempty" gorm:"foreignKey:ParentID"`
+}
+
+func (Category) TableName() string {
+	return "mm_categories"
+}
+
+// CategoryResponse represents the category response
+type CategoryResponse struct {
+	ID       uint               `json:"id"`
+	Slug     string             `json:"slug"`
+	Name     string             `json:"name"`
+	Icon     string             `json:"icon"`
+	ParentID *uint              `json:"parent_id,omitempty"`
+	Children []CategoryResponse `json:"children,omitempty"`
+}
+
+// ToResponse converts Category to CategoryResponse
+func (c *Category) ToResponse() CategoryResponse {
+	response := CategoryResponse{
+		ID:       c.ID,
+		Slug:     c.Slug,
+		Name:     c.Name,
+		Icon:     c.Icon,
+		ParentID: c.ParentID,
+	}
+
+	if len(c.Children) > 0 {
+		children := make([]CategoryResponse, len(c.Children))
+		for i, child := range c.Children {
+			children[i] = child.ToResponse()
+		}
+		response.Children = children
+	}
+
+	return response
+}
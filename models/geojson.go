@@ -0,0 +1,50 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+)
+
+// GeoJSON holds a raw GeoJSON geometry stored in a jsonb column
+type GeoJSON []byte
+
+// Value implements driver.Valuer
+func (g GeoJSON) Value() (driver.Value, error) {
+	if len(g) == 0 {
+		return nil, nil
+	}
+	return string(g), nil
+}
+
+// Scan implements sql.Scanner
+func (g *GeoJSON) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*g = nil
+	case []byte:
+		*g = append(GeoJSON(nil), v...)
+	case string:
+		*g = GeoJSON(v)
+	default:
+		return fmt.Errorf("cannot scan %T into GeoJSON", value)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (g GeoJSON) MarshalJSON() ([]byte, error) {
+	if len(g) == 0 {
+		return []byte("null"), nil
+	}
+	return g, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (g *GeoJSON) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*g = nil
+		return nil
+	}
+	*g = append(GeoJSON(nil), data...)
+	return nil
+}
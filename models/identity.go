@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+)
+
+// UserIdentity links an account at an external identity provider to a user
+type UserIdentity struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	Provider    string     `json:"provider" gorm:"size:50;not null;uniqueIndex:idx_mm_user_identities_provider_subject"`
+	Subject     string     `json:"subject" gorm:"size:255;not null;uniqueIndex:idx_mm_user_identities_provider_subject"`
+	Email       string     `json:"email" gorm:"size:255"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+func (UserIdentity) TableName() string {
+	return "mm_user_identities"
+}
+
+// OAuthState remembers an authorization request until the provider redirects back.
+// The state itself is only stored hashed; the PKCE verifier never leaves the server.
+type OAuthState struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	StateHash    string    `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Provider     string    `json:"provider" gorm:"size:50;not null"`
+	CodeVerifier string    `json:"-" gorm:"size:128;not null"`
+	Nonce        string    `json:"-" gorm:"size:128;not null"`
+	UserID       *uint     `json:"user_id"` // set when a signed-in user links a provider
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (OAuthState) TableName() string {
+	return "mm_oauth_states"
+}
+
+// OAuthStartResponse carries the URL the client sends the user to
+type OAuthStartResponse struct {
+	Provider         string `json:"provider"`
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+// OAuthCallbackRequest represents the parameters the provider redirected back with,
+// sent as JSON or as the form a provider with response_mode=form_post submits
+type OAuthCallbackRequest struct {
+	Code  string `json:"code" form:"code" validate:"required"`
+	State string `json:"state" form:"state" validate:"required"`
+	// User is the JSON Apple adds on the first sign in only; it is the only place
+	// Apple shares the user's name
+	User string `json:"user" form:"user"`
+}
+
+// UserIdentityResponse represents a linked identity
+type UserIdentityResponse struct {
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ToResponse converts UserIdentity to UserIdentityResponse
+func (i *UserIdentity) ToResponse() UserIdentityResponse {
+	return UserIdentityResponse{
+		Provider:    i.Provider,
+		Email:       i.Email,
+		LastLoginAt: i.LastLoginAt,
+		CreatedAt:   i.CreatedAt,
+	}
+}
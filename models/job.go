@@ -0,0 +1,88 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job states: queued jobs wait for RunAt (retries wait for their backoff there too),
+// dead jobs failed for good and stay until an admin retries them
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDead      = "dead"
+)
+
+// Job is a unit of background work, see the jobs package
+type Job struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UUID        uuid.UUID  `json:"uuid" gorm:"type:uuid;default:gen_random_uuid();uniqueIndex"`
+	Type        string     `json:"type" gorm:"size:100;not null;index"`
+	Payload     string     `json:"payload" gorm:"type:jsonb;not null;default:'{}'"`
+	Status      string     `json:"status" gorm:"size:20;not null;index:idx_mm_jobs_status_run_at,priority:1"`
+	RunAt       time.Time  `json:"run_at" gorm:"not null;index:idx_mm_jobs_status_run_at,priority:2"`
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	MaxAttempts int        `json:"max_attempts" gorm:"not null"`
+	LastError   string     `json:"last_error" gorm:"type:text"`
+	LockedBy    string     `json:"locked_by" gorm:"size:100"` // worker running the job
+	LockedAt    *time.Time `json:"locked_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (Job) TableName() string {
+	return "mm_jobs"
+}
+
+// JobResponse represents a job for admins
+type JobResponse struct {
+	UUID        uuid.UUID       `json:"uuid"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	RunAt       time.Time       `json:"run_at"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	LockedBy    string          `json:"locked_by,omitempty"`
+	LockedAt    *time.Time      `json:"locked_at,omitempty"`
+	FinishedAt  *time.Time      `json:"finished_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+// ToResponse converts Job to JobResponse
+func (j *Job) ToResponse() JobResponse {
+	payload := json.RawMessage(j.Payload)
+	if len(payload) == 0 {
+		payload = json.RawMessage("{}")
+	}
+	return JobResponse{
+		UUID:        j.UUID,
+		Type:        j.Type,
+		Payload:     payload,
+		Status:      j.Status,
+		RunAt:       j.RunAt,
+		Attempts:    j.Attempts,
+		MaxAttempts: j.MaxAttempts,
+		LastError:   j.LastError,
+		LockedBy:    j.LockedBy,
+		LockedAt:    j.LockedAt,
+		FinishedAt:  j.FinishedAt,
+		CreatedAt:   j.CreatedAt,
+		UpdatedAt:   j.UpdatedAt,
+	}
+}
+
+// JobStats counts the jobs of one type by state
+type JobStats struct {
+	Type      string `json:"type"`
+	Queued    int64  `json:"queued"`
+	Running   int64  `json:"running"`
+	Succeeded int64  `json:"succeeded"`
+	Dead      int64  `json:"dead"`
+}
@@ -16,11 +16,15 @@ type Location struct {
 	Address     string    `json:"address" gorm:"type:text"`
 	Country     string    `json:"country" gorm:"size:100"`
 	City        string    `json:"city" gorm:"size:100"`
+	Boundary    GeoJSON   `json:"boundary,omitempty" gorm:"type:jsonb"` // optional Polygon/MultiPolygon for area locations
+	CreatedBy   *uint     `json:"created_by" gorm:"index"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 
 	// Relationships
-	Memories []Memory `json:"memories,omitempty" gorm:"foreignKey:LocationID"`
+	Creator    *User      `json:"creator,omitempty" gorm:"foreignKey:CreatedBy"`
+	Memories   []Memory   `json:"memories,omitempty" gorm:"foreignKey:LocationID"`
+	Categories []Category `json:"categories,omitempty" gorm:"many2many:mm_location_categories;"`
 }
 
 func (Location) TableName() string {
@@ -29,44 +33,58 @@ func (Location) TableName() string {
 
 // LocationCreateRequest represents the request for creating a location
 type LocationCreateRequest struct {
-	Name        string  `json:"name" validate:"required,max=255"`
-	Description string  `json:"description"`
-	Latitude    float64 `json:"latitude" validate:"required,min=-90,max=90"`
-	Longitude   float64 `json:"longitude" validate:"required,min=-180,max=180"`
-	Address     string  `json:"address"`
-	Country     string  `json:"country"`
-	City        string  `json:"city"`
+	Name        string   `json:"name" validate:"required,max=255"`
+	Description string   `json:"description"`
+	Latitude    float64  `json:"latitude" validate:"required,min=-90,max=90"`
+	Longitude   float64  `json:"longitude" validate:"required,min=-180,max=180"`
+	Address     string   `json:"address"`
+	Country     string   `json:"country"`
+	City        string   `json:"city"`
+	Boundary    GeoJSON  `json:"boundary" swaggertype:"object"` // GeoJSON Polygon or MultiPolygon
+	Categories  []string `json:"categories"`                    // category slugs
 }
 
 // LocationUpdateRequest represents the request for updating a location
 type LocationUpdateRequest struct {
-	Name        string  `json:"name" validate:"max=255"`
-	Description string  `json:"description"`
-	Latitude    float64 `json:"latitude" validate:"min=-90,max=90"`
-	Longitude   float64 `json:"longitude" validate:"min=-180,max=180"`
-	Address     string  `json:"address"`
-	Country     string  `json:"country"`
-	City        string  `json:"city"`
+	Name          string   `json:"name" validate:"max=255"`
+	Description   string   `json:"description"`
+	Latitude      float64  `json:"latitude" validate:"min=-90,max=90"`
+	Longitude     float64  `json:"longitude" validate:"min=-180,max=180"`
+	Address       string   `json:"address"`
+	Country       string   `json:"country"`
+	City          string   `json:"city"`
+	Boundary      GeoJSON  `json:"boundary" swaggertype:"object"` // GeoJSON Polygon or MultiPolygon
+	ClearBoundary bool     `json:"clear_boundary"`                // remove the boundary, turning the location back into a point
+	Categories    []string `json:"categories"`                    // category slugs; replaces existing categories when provided
 }
 
 // LocationResponse represents the location response with memory count
 type LocationResponse struct {
-	ID          uint      `json:"id"`
-	UUID        uuid.UUID `json:"uuid"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Latitude    float64   `json:"latitude"`
-	Longitude   float64   `json:"longitude"`
-	Address     string    `json:"address"`
-	Country     string    `json:"country"`
-	City        string    `json:"city"`
-	MemoryCount int64     `json:"memory_count"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint               `json:"id"`
+	UUID        uuid.UUID          `json:"uuid"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Latitude    float64            `json:"latitude"`
+	Longitude   float64            `json:"longitude"`
+	Address     string             `json:"address"`
+	Country     string             `json:"country"`
+	City        string             `json:"city"`
+	Boundary    GeoJSON            `json:"boundary,omitempty" swaggertype:"object"`
+	DistanceKm  *float64           `json:"distance_km,omitempty"` // distance to the point or boundary, for nearby searches
+	CreatedBy   *uint              `json:"created_by"`
+	Categories  []CategoryResponse `json:"categories"`
+	MemoryCount int64              `json:"memory_count"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
 }
 
 // ToResponse converts Location to LocationResponse
 func (l *Location) ToResponse() LocationResponse {
+	categories := make([]CategoryResponse, len(l.Categories))
+	for i, category := range l.Categories {
+		categories[i] = category.ToResponse()
+	}
+
 	return LocationResponse{
 		ID:          l.ID,
 		UUID:        l.UUID,
@@ -77,15 +95,28 @@ func (l *Location) ToResponse() LocationResponse {
 		Address:     l.Address,
 		Country:     l.Country,
 		City:        l.City,
+		Boundary:    l.Boundary,
+		CreatedBy:   l.CreatedBy,
+		Categories:  categories,
 		CreatedAt:   l.CreatedAt,
 		UpdatedAt:   l.UpdatedAt,
 	}
 }
 
+// IsArea reports whether the location has a polygon boundary rather than just a point
+func (l *Location) IsArea() bool {
+	return len(l.Boundary) > 0
+}
+
+// IsOwnedBy reports whether the location was created by the given user
+func (l *Location) IsOwnedBy(userID uint) bool {
+	return l.CreatedBy != nil && *l.CreatedBy == userID
+}
+
 // LocationSearchRequest represents the request for searching locations near a point
 type LocationSearchRequest struct {
 	Latitude  float64 `json:"latitude" validate:"required,min=-90,max=90"`
 	Longitude float64 `json:"longitude" validate:"required,min=-180,max=180"`
 	Radius    float64 `json:"radius" validate:"min=0,max=100"` // in kilometers
 	Limit     int     `json:"limit" validate:"min=1,max=100"`
-}
\ No newline at end of file
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Suggestion statuses
+const (
+	SuggestionStatusPending  = "pending"
+	SuggestionStatusApproved = "approved"
+	SuggestionStatusRejected = "rejected"
+)
+
+// LocationEditSuggestion represents a change to a location proposed by a user who does not own it.
+// Empty strings and nil coordinates mean "leave unchanged".
+type LocationEditSuggestion struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UUID        uuid.UUID  `json:"uuid" gorm:"type:uuid;default:gen_random_uuid();uniqueIndex"`
+	LocationID  uint       `json:"location_id" gorm:"not null;index"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	Name        string     `json:"name" gorm:"size:255"`
+	Description string     `json:"description" gorm:"type:text"`
+	Latitude    *float64   `json:"latitude" gorm:"type:decimal(10,8)"`
+	Longitude   *float64   `json:"longitude" gorm:"type:decimal(11,8)"`
+	Address     string     `json:"address" gorm:"type:text"`
+	Country     string     `json:"country" gorm:"size:100"`
+	City        string     `json:"city" gorm:"size:100"`
+	Reason      string     `json:"reason" gorm:"type:text"`
+	Status      string     `json:"status" gorm:"size:20;not null;default:'pending';index;check:status IN ('pending', 'approved', 'rejected')"`
+	ReviewedBy  *uint      `json:"reviewed_by"`
+	ReviewedAt  *time.Time `json:"reviewed_at"`
+	ReviewNote  string     `json:"review_note" gorm:"type:text"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Relationships
+	Location Location `json:"location,omitempty" gorm:"foreignKey:LocationID"`
+	User     User     `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+func (LocationEditSuggestion) TableName() string {
+	return "mm_location_edit_suggestions"
+}
+
+// LocationAuditLog records every change to a location's coordinates
+type LocationAuditLog struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	LocationID   uint      `json:"location_id" gorm:"not null;index"`
+	UserID       uint      `json:"user_id" gorm:"not null"`
+	SuggestionID *uint     `json:"suggestion_id"`
+	OldLatitude  float64   `json:"old_latitude" gorm:"type:decimal(10,8)"`
+	OldLongitude float64   `json:"old_longitude" gorm:"type:decimal(11,8)"`
+	NewLatitude  float64   `json:"new_latitude" gorm:"type:decimal(10,8)"`
+	NewLongitude float64   `json:"new_longitude" gorm:"type:decimal(11,8)"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// Relationships
+	User       User                    `json:"-" gorm:"foreignKey:UserID"`
+	Suggestion *LocationEditSuggestion `json:"-" gorm:"foreignKey:SuggestionID"`
+}
+
+func (LocationAuditLog) TableName() string {
+	return "mm_location_audit_logs"
+}
+
+// LocationAuditLogResponse represents a coordinate change in API responses
+type LocationAuditLogResponse struct {
+	ID             uint               `json:"id"`
+	User           PublicUserResponse `json:"user"`                      // who moved the location, or approved the suggestion
+	SuggestionUUID *uuid.UUID         `json:"suggestion_uuid,omitempty"` // set when the move came from a suggestion
+	OldLatitude    float64            `json:"old_latitude"`
+	OldLongitude   float64            `json:"old_longitude"`
+	NewLatitude    float64            `json:"new_latitude"`
+	NewLongitude   float64            `json:"new_longitude"`
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+// ToResponse converts LocationAuditLog to LocationAuditLogResponse for the given viewer
+func (l *LocationAuditLog) ToResponse(viewer Viewer) LocationAuditLogResponse {
+	response := LocationAuditLogResponse{
+		ID:           l.ID,
+		User:         l.User.ToPublicResponse(viewer),
+		OldLatitude:  l.OldLatitude,
+		OldLongitude: l.OldLongitude,
+		NewLatitude:  l.NewLatitude,
+		NewLongitude: l.NewLongitude,
+		CreatedAt:    l.CreatedAt,
+	}
+	if l.Suggestion != nil {
+		response.SuggestionUUID = &l.Suggestion.UUID
+	}
+	return response
+}
+
+// LocationSuggestionRequest represents the request for proposing a location edit
+type LocationSuggestionRequest struct {
+	Name        string   `json:"name" validate:"max=255"`
+	Description string   `json:"description"`
+	Latitude    *float64 `json:"latitude" validate:"omitempty,min=-90,max=90"`
+	Longitude   *float64 `json:"longitude" validate:"omitempty,min=-180,max=180"`
+	Address     string   `json:"address"`
+	Country     string   `json:"country"`
+	City        string   `json:"city"`
+	Reason      string   `json:"reason" validate:"max=1000"`
+}
+
+// IsEmpty reports whether the request proposes no change at all
+func (r *LocationSuggestionRequest) IsEmpty() bool {
+	return r.Name == "" && r.Description == "" && r.Latitude == nil && r.Longitude == nil &&
+		r.Address == "" && r.Country == "" && r.City == ""
+}
+
+// SuggestionReviewRequest represents the request for approving or rejecting a suggestion
+type SuggestionReviewRequest struct {
+	Note string `json:"note" validate:"max=1000"`
+}
+
+// LocationSuggestionResponse represents a suggestion in API responses
+type LocationSuggestionResponse struct {
+	UUID         uuid.UUID  `json:"uuid"`
+	LocationUUID uuid.UUID  `json:"location_uuid"`
+	UserID       uint       `json:"user_id"`
+	Username     string     `json:"username,omitempty"`
+	Name         string     `json:"name,omitempty"`
+	Description  string     `json:"description,omitempty"`
+	Latitude     *float64   `json:"latitude,omitempty"`
+	Longitude    *float64   `json:"longitude,omitempty"`
+	Address      string     `json:"address,omitempty"`
+	Country      string     `json:"country,omitempty"`
+	City         string     `json:"city,omitempty"`
+	Reason       string     `json:"reason,omitempty"`
+	Status       string     `json:"status"`
+	ReviewedBy   *uint      `json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote   string     `json:"review_note,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ToResponse converts LocationEditSuggestion to LocationSuggestionResponse
+func (s *LocationEditSuggestion) ToResponse() LocationSuggestionResponse {
+	return LocationSuggestionResponse{
+		UUID:         s.UUID,
+		LocationUUID: s.Location.UUID,
+		UserID:       s.UserID,
+		Username:     s.User.Username,
+		Name:         s.Name,
+		Description:  s.Description,
+		Latitude:     s.Latitude,
+		Longitude:    s.Longitude,
+		Address:      s.Address,
+		Country:      s.Country,
+		City:         s.City,
+		Reason:       s.Reason,
+		Status:       s.Status,
+		ReviewedBy:   s.ReviewedBy,
+		ReviewedAt:   s.ReviewedAt,
+		ReviewNote:   s.ReviewNote,
+		CreatedAt:    s.CreatedAt,
+	}
+}
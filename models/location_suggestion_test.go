@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestAuditLogResponseShowsOnlyThePublicUser(t *testing.T) {
+	author := testAuthor()
+	suggestion := LocationEditSuggestion{ID: 4, UUID: uuid.New()}
+	auditLog := LocationAuditLog{
+		ID:           9,
+		LocationID:   3,
+		UserID:       author.ID,
+		SuggestionID: &suggestion.ID,
+		NewLatitude:  10.77,
+		NewLongitude: 106.7,
+		User:         author,
+		Suggestion:   &suggestion,
+	}
+
+	response := auditLog.ToResponse(Viewer{})
+	assertNoPrivateFields(t, response, author.Email)
+	if response.User.Username != author.Username {
+		t.Errorf("user = %+v, want %s", response.User, author.Username)
+	}
+	if response.SuggestionUUID == nil || *response.SuggestionUUID != suggestion.UUID {
+		t.Errorf("suggestion_uuid = %v, want %s", response.SuggestionUUID, suggestion.UUID)
+	}
+
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	for _, field := range []string{`"user_id"`, `"location_id"`, `"suggestion_id"`} {
+		if strings.Contains(string(encoded), field) {
+			t.Errorf("response has internal field %s: %s", field, encoded)
+		}
+	}
+}
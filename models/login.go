@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+// Login methods recorded in login events
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodTwoFactor = "two_factor"
+	LoginMethodOAuth     = "oauth"
+)
+
+// LoginEvent records a login attempt for auditing
+type LoginEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    *uint     `json:"user_id" gorm:"index"` // nil when the email matched no account
+	Email     string    `json:"email" gorm:"size:255;index"`
+	Method    string    `json:"method" gorm:"size:50;not null"`
+	Success   bool      `json:"success" gorm:"not null"`
+	Reason    string    `json:"reason,omitempty" gorm:"size:50"`
+	IPAddress string    `json:"ip_address" gorm:"size:45;index"`
+	UserAgent string    `json:"user_agent" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+func (LoginEvent) TableName() string {
+	return "mm_login_events"
+}
+
+// LoginThrottle counts recent failed logins for an account ("account:<email>") or a
+// client address ("ip:<address>")
+type LoginThrottle struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	ThrottleKey   string     `json:"throttle_key" gorm:"size:300;not null;uniqueIndex"`
+	Failures      int        `json:"failures" gorm:"not null;default:0"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (LoginThrottle) TableName() string {
+	return "mm_login_throttles"
+}
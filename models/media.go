@@ -7,17 +7,27 @@ import (
 )
 
 type Media struct {
-	ID               uint      `json:"id" gorm:"primaryKey"`
-	UUID             uuid.UUID `json:"uuid" gorm:"type:uuid;default:gen_random_uuid();uniqueIndex"`
-	MemoryID         uint      `json:"memory_id" gorm:"not null"`
-	Filename         string    `json:"filename" gorm:"not null"`
-	OriginalFilename string    `json:"original_filename" gorm:"not null"`
-	FilePath         string    `json:"file_path" gorm:"type:text;not null"`
-	FileSize         int64     `json:"file_size" gorm:"not null"`
-	MimeType         string    `json:"mime_type" gorm:"not null"`
-	MediaType        string    `json:"media_type" gorm:"not null;check:media_type IN ('image', 'video')"`
-	DisplayOrder     int       `json:"display_order" gorm:"default:0"`
-	CreatedAt        time.Time `json:"created_at"`
+	ID               uint       `json:"id" gorm:"primaryKey"`
+	UUID             uuid.UUID  `json:"uuid" gorm:"type:uuid;default:gen_random_uuid();uniqueIndex"`
+	MemoryID         uint       `json:"memory_id" gorm:"not null"`
+	Filename         string     `json:"filename" gorm:"not null"`
+	OriginalFilename string     `json:"original_filename" gorm:"not null"`
+	StorageKey       string     `json:"-" gorm:"size:500;index"` // key in the media storage backend
+	BlobID           *uint      `json:"-" gorm:"index"`          // shared content, nil for uploads before deduplication
+	FilePath         string     `json:"-" gorm:"type:text"`      // legacy absolute path, cleared by cmd/migrate-storage
+	FileSize         int64      `json:"file_size" gorm:"not null"`
+	MimeType         string     `json:"mime_type" gorm:"not null"`
+	MediaType        string     `json:"media_type" gorm:"not null;check:media_type IN ('image', 'video')"`
+	Checksum         string     `json:"checksum" gorm:"size:64;index"` // SHA-256 of the content, hex encoded
+	Width            int        `json:"width"`
+	Height           int        `json:"height"`
+	DurationMs       int64      `json:"duration_ms"`                            // videos only
+	VideoCodec       string     `json:"video_codec" gorm:"size:32"`             // videos only
+	PosterKey        string     `json:"-" gorm:"size:500"`                      // poster frame in the media storage
+	ProcessingStatus string     `json:"processing_status" gorm:"size:20;index"` // videos: pending, processing, ready or failed
+	UploadUUID       *uuid.UUID `json:"-" gorm:"type:uuid;uniqueIndex"`         // resumable upload the media was created from
+	DisplayOrder     int        `json:"display_order" gorm:"default:0"`
+	CreatedAt        time.Time  `json:"created_at"`
 
 	// Relationships
 	Memory Memory `json:"memory,omitempty" gorm:"foreignKey:MemoryID"`
@@ -33,12 +43,17 @@ type MediaResponse struct {
 	UUID             uuid.UUID `json:"uuid"`
 	Filename         string    `json:"filename"`
 	OriginalFilename string    `json:"original_filename"`
-	FilePath         string    `json:"file_path"`
 	FileSize         int64     `json:"file_size"`
 	MimeType         string    `json:"mime_type"`
 	MediaType        string    `json:"media_type"`
+	Checksum         string    `json:"checksum,omitempty"`
+	Width            int       `json:"width,omitempty"`
+	Height           int       `json:"height,omitempty"`
+	DurationMs       int64     `json:"duration_ms,omitempty"`
+	VideoCodec       string    `json:"video_codec,omitempty"`
+	ProcessingStatus string    `json:"processing_status,omitempty"` // pending, processing, ready or failed
 	DisplayOrder     int       `json:"display_order"`
-	URL              string    `json:"url"` // Full URL for accessing the file
+	URL              string    `json:"url"`                     // Full URL for accessing the file
 	ThumbnailURL     string    `json:"thumbnail_url,omitempty"` // For images/videos
 	CreatedAt        time.Time `json:"created_at"`
 }
@@ -50,16 +65,70 @@ func (m *Media) ToResponse() MediaResponse {
 		UUID:             m.UUID,
 		Filename:         m.Filename,
 		OriginalFilename: m.OriginalFilename,
-		FilePath:         m.FilePath,
 		FileSize:         m.FileSize,
 		MimeType:         m.MimeType,
 		MediaType:        m.MediaType,
+		Checksum:         m.Checksum,
+		Width:            m.Width,
+		Height:           m.Height,
+		DurationMs:       m.DurationMs,
+		VideoCodec:       m.VideoCodec,
+		ProcessingStatus: m.ProcessingStatus,
 		DisplayOrder:     m.DisplayOrder,
 		URL:              "/api/v1/media/" + m.UUID.String(),
+		ThumbnailURL:     m.thumbnailURL(),
 		CreatedAt:        m.CreatedAt,
 	}
 }
 
+// thumbnailURL returns the URL of the poster frame of a video, once there is one
+func (m *Media) thumbnailURL() string {
+	if m.PosterKey == "" {
+		return ""
+	}
+	return "/api/v1/media/" + m.UUID.String() + "/poster"
+}
+
+// Processing states of videos
+const (
+	ProcessingPending    = "pending"
+	ProcessingProcessing = "processing"
+	ProcessingReady      = "ready"
+	ProcessingFailed     = "failed"
+)
+
+// MediaUploadResponse is returned for a new upload. AlreadyUploaded lists media of the
+// same user with identical content, so clients can point out the duplicate.
+type MediaUploadResponse struct {
+	MediaResponse
+	AlreadyUploaded []MediaDuplicate `json:"already_uploaded,omitempty"`
+}
+
+// MediaDuplicate is an earlier upload with the same content
+type MediaDuplicate struct {
+	UUID        uuid.UUID `json:"uuid"`
+	MemoryUUID  uuid.UUID `json:"memory_uuid"`
+	MemoryTitle string    `json:"memory_title"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// BatchUploadResponse reports the outcome of every file of a batch upload, in the order
+// the files were sent
+type BatchUploadResponse struct {
+	Uploaded int                 `json:"uploaded"`
+	Failed   int                 `json:"failed"`
+	Results  []BatchUploadResult `json:"results"`
+}
+
+// BatchUploadResult is the outcome of one file of a batch upload
+type BatchUploadResult struct {
+	Index    int                  `json:"index"`
+	Filename string               `json:"filename"`
+	Success  bool                 `json:"success"`
+	Media    *MediaUploadResponse `json:"media,omitempty"`
+	Error    *ErrorResponse       `json:"error,omitempty"`
+}
+
 // MediaUploadRequest represents the request for uploading media
 type MediaUploadRequest struct {
 	MemoryID     uint `json:"memory_id" validate:"required"`
@@ -77,4 +146,4 @@ type MediaListRequest struct {
 	MediaType string `json:"media_type" validate:"oneof=image video"`
 	Limit     int    `json:"limit" validate:"min=1,max=100"`
 	Offset    int    `json:"offset" validate:"min=0"`
-}
\ No newline at end of file
+}
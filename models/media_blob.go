@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// MediaBlob is a stored file shared by every Media row with the same content. Files are
+// stored under their SHA-256 (see storage.BlobKey) and removed when the last
+// referencing Media row is deleted.
+type MediaBlob struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	Checksum   string    `json:"checksum" gorm:"size:64;uniqueIndex;not null"` // SHA-256 of the content, hex encoded
+	StorageKey string    `json:"-" gorm:"size:500;not null"`
+	Size       int64     `json:"size" gorm:"not null"`
+	MimeType   string    `json:"mime_type" gorm:"not null"`
+	RefCount   int       `json:"ref_count" gorm:"not null;default:0"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (MediaBlob) TableName() string {
+	return "mm_media_blobs"
+}
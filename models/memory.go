@@ -82,25 +82,31 @@ type MemoryUpdateRequest struct {
 
 // MemoryResponse represents the memory response with additional data
 type MemoryResponse struct {
-	ID         uint              `json:"id"`
-	UUID       uuid.UUID         `json:"uuid"`
-	Title      string            `json:"title"`
-	Content    string            `json:"content"`
-	VisitDate  *time.Time        `json:"visit_date"`
-	IsPublic   bool              `json:"is_public"`
-	Tags       []string          `json:"tags"`
-	LikeCount  int64             `json:"like_count"`
-	MediaCount int64             `json:"media_count"`
-	IsLiked    bool              `json:"is_liked,omitempty"` // for current user
-	User       UserResponse      `json:"user"`
-	Location   LocationResponse  `json:"location"`
-	Media      []MediaResponse   `json:"media,omitempty"`
-	CreatedAt  time.Time         `json:"created_at"`
-	UpdatedAt  time.Time         `json:"updated_at"`
+	ID         uint               `json:"id"`
+	UUID       uuid.UUID          `json:"uuid"`
+	Title      string             `json:"title"`
+	Content    string             `json:"content"`
+	VisitDate  *time.Time         `json:"visit_date"`
+	IsPublic   bool               `json:"is_public"`
+	Tags       []string           `json:"tags"`
+	LikeCount  int64              `json:"like_count"`
+	MediaCount int64              `json:"media_count"`
+	IsLiked    bool               `json:"is_liked,omitempty"` // for current user
+	User       PublicUserResponse `json:"user"`
+	Location   LocationResponse   `json:"location"`
+	Media      []MediaResponse    `json:"media,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+	UpdatedAt  time.Time          `json:"updated_at"`
 }
 
-// ToResponse converts Memory to MemoryResponse
+// ToResponse converts Memory to MemoryResponse as seen by an anonymous caller
 func (m *Memory) ToResponse() MemoryResponse {
+	return m.ToResponseFor(Viewer{})
+}
+
+// ToResponseFor converts Memory to MemoryResponse, exposing the author's private
+// fields only to the author themself and admins
+func (m *Memory) ToResponseFor(viewer Viewer) MemoryResponse {
 	tags := make([]string, 0)
 	if m.Tags != nil {
 		tags = []string(m.Tags)
@@ -119,7 +125,7 @@ func (m *Memory) ToResponse() MemoryResponse {
 	}
 
 	if m.User.ID != 0 {
-		response.User = m.User.ToResponse()
+		response.User = m.User.ToPublicResponse(viewer)
 	}
 
 	if m.Location.ID != 0 {
@@ -6,11 +6,15 @@ import (
 
 // UserSession represents user authentication sessions
 type UserSession struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	UserID    uint      `json:"user_id" gorm:"not null"`
-	TokenHash string    `json:"-" gorm:"not null"`
-	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"not null"`
+	TokenHash   string     `json:"-" gorm:"not null;index"`
+	IPAddress   string     `json:"ip_address" gorm:"size:45"`
+	UserAgent   string     `json:"user_agent" gorm:"type:text"`
+	LoginMethod string     `json:"login_method" gorm:"size:50"` // how the session was signed in, empty when re-issued after an account change
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"not null"`
+	CreatedAt   time.Time  `json:"created_at"`
 
 	// Relationships
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
@@ -20,6 +24,32 @@ func (UserSession) TableName() string {
 	return "mm_user_sessions"
 }
 
+// SessionResponse represents an active session in API responses
+type SessionResponse struct {
+	ID          uint       `json:"id"`
+	IPAddress   string     `json:"ip_address"`
+	UserAgent   string     `json:"user_agent"`
+	LoginMethod string     `json:"login_method,omitempty"`
+	Current     bool       `json:"current"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ToResponse converts UserSession to SessionResponse; current marks the session of the request
+func (s *UserSession) ToResponse(current bool) SessionResponse {
+	return SessionResponse{
+		ID:          s.ID,
+		IPAddress:   s.IPAddress,
+		UserAgent:   s.UserAgent,
+		LoginMethod: s.LoginMethod,
+		Current:     current,
+		LastUsedAt:  s.LastUsedAt,
+		ExpiresAt:   s.ExpiresAt,
+		CreatedAt:   s.CreatedAt,
+	}
+}
+
 // MemoryLike represents user likes on memories
 type MemoryLike struct {
 	ID       uint      `json:"id" gorm:"primaryKey"`
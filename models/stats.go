@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CountryVisit summarizes a user's memories in one country
+type CountryVisit struct {
+	CountryCode string    `json:"country_code"`
+	CountryName string    `json:"country_name,omitempty"`
+	MemoryCount int       `json:"memory_count"`
+	CityCount   int       `json:"city_count"`
+	FirstVisit  time.Time `json:"first_visit"`
+	LastVisit   time.Time `json:"last_visit"`
+}
+
+// CityVisit summarizes a user's memories in one city
+type CityVisit struct {
+	City        string `json:"city"`
+	CountryCode string `json:"country_code"`
+	MemoryCount int    `json:"memory_count"`
+}
+
+// VisitedCountriesMap is a compact payload for drawing a map of visited countries
+type VisitedCountriesMap struct {
+	Countries []string       `json:"countries"`          // ISO 3166-1 alpha-2 codes
+	Counts    map[string]int `json:"counts"`             // memories per country code
+	Username  string         `json:"username,omitempty"` // for share captions
+}
+
+// UserTravelStats represents travel statistics computed from a user's memories
+type UserTravelStats struct {
+	UserUUID         uuid.UUID           `json:"user_uuid"`
+	Username         string              `json:"username"`
+	IncludesPrivate  bool                `json:"includes_private"` // true when the owner is viewing their own stats
+	MemoryCount      int                 `json:"memory_count"`
+	LocationCount    int                 `json:"location_count"`
+	CountryCount     int                 `json:"country_count"`
+	CityCount        int                 `json:"city_count"`
+	TotalDistanceKm  float64             `json:"total_distance_km"` // great-circle distance between chronologically ordered memories
+	FirstVisit       *time.Time          `json:"first_visit,omitempty"`
+	LastVisit        *time.Time          `json:"last_visit,omitempty"`
+	Countries        []CountryVisit      `json:"countries"`
+	Cities           []CityVisit         `json:"cities"`
+	MemoriesPerYear  map[string]int      `json:"memories_per_year"`
+	MemoriesPerMonth map[string]int      `json:"memories_per_month"` // keyed by YYYY-MM
+	Map              VisitedCountriesMap `json:"map"`
+}
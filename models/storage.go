@@ -0,0 +1,39 @@
+package models
+
+import "github.com/google/uuid"
+
+// StorageBreakdown is the usage of one kind of media
+type StorageBreakdown struct {
+	Bytes int64 `json:"bytes"`
+	Files int64 `json:"files"`
+}
+
+// StorageUsageResponse represents the storage a user consumes and may consume. Limits
+// are null when unlimited.
+type StorageUsageResponse struct {
+	UsedBytes    int64            `json:"used_bytes"`
+	UsedFiles    int64            `json:"used_files"`
+	PendingBytes int64            `json:"pending_bytes"` // reserved by unfinished resumable uploads
+	LimitBytes   *int64           `json:"limit_bytes"`
+	LimitFiles   *int64           `json:"limit_files"`
+	Images       StorageBreakdown `json:"images"`
+	Videos       StorageBreakdown `json:"videos"`
+}
+
+// StorageConsumerResponse is an entry of the admin report of the largest consumers
+type StorageConsumerResponse struct {
+	UserUUID   uuid.UUID `json:"user_uuid"`
+	Username   string    `json:"username"`
+	Role       string    `json:"role"`
+	UsedBytes  int64     `json:"used_bytes"`
+	UsedFiles  int64     `json:"used_files"`
+	LimitBytes *int64    `json:"limit_bytes"`
+	LimitFiles *int64    `json:"limit_files"`
+}
+
+// QuotaUpdateRequest sets the storage limits of a single user; null restores the role
+// limit and 0 means unlimited
+type QuotaUpdateRequest struct {
+	MaxBytes *int64 `json:"max_bytes" validate:"omitempty,min=0"`
+	MaxFiles *int64 `json:"max_files" validate:"omitempty,min=0"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+)
+
+// RecoveryCode is a hashed single-use code that replaces a TOTP code when the
+// authenticator device is lost
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"size:64;not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "mm_user_recovery_codes"
+}
+
+// TwoFactorSetupResponse carries the secret for enrolling an authenticator app
+type TwoFactorSetupResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI to render as a QR code
+}
+
+// TwoFactorCodeRequest represents a request confirmed with a TOTP code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// TwoFactorDisableRequest represents the request for turning off two-factor authentication
+type TwoFactorDisableRequest struct {
+	Password string `json:"password"`                 // accounts without a password need a recent social login instead
+	Code     string `json:"code" validate:"required"` // TOTP or recovery code
+}
+
+// RecoveryCodesResponse lists freshly generated recovery codes; they are only shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// TwoFactorChallengeResponse is returned by login when a second factor is required
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+	ExpiresIn         int64  `json:"expires_in"` // seconds
+}
+
+// TwoFactorLoginRequest represents the second step of login
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"` // TOTP or recovery code
+}
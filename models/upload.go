@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UploadSession tracks a resumable upload; the received bytes are staged on disk until
+// the upload is completed and turned into a Media record
+type UploadSession struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	UUID         uuid.UUID `json:"uuid" gorm:"type:uuid;uniqueIndex;not null"`
+	UserID       uint      `json:"user_id" gorm:"not null;index"`
+	MemoryID     uint      `json:"memory_id" gorm:"not null"`
+	Filename     string    `json:"filename" gorm:"size:255;not null"`
+	Size         int64     `json:"size" gorm:"not null"`
+	Offset       int64     `json:"offset" gorm:"not null;default:0"`
+	DisplayOrder int       `json:"display_order" gorm:"default:0"`
+	Checksum     string    `json:"checksum" gorm:"size:64"` // optional SHA-256 (hex) of the whole file
+	ExpiresAt    time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	// Relationships
+	User   User   `json:"-" gorm:"foreignKey:UserID"`
+	Memory Memory `json:"-" gorm:"foreignKey:MemoryID"`
+}
+
+func (UploadSession) TableName() string {
+	return "mm_upload_sessions"
+}
+
+// BeforeCreate sets UUID before creating upload session
+func (u *UploadSession) BeforeCreate(tx *gorm.DB) error {
+	if u.UUID == uuid.Nil {
+		u.UUID = uuid.New()
+	}
+	return nil
+}
+
+// UploadCreateRequest represents the request for starting a resumable upload
+type UploadCreateRequest struct {
+	MemoryID     uint   `json:"memory_id" validate:"required"`
+	Filename     string `json:"filename" validate:"required,max=255"`
+	Size         int64  `json:"size" validate:"required,min=1"`
+	DisplayOrder int    `json:"display_order"`
+	Checksum     string `json:"checksum" validate:"omitempty,len=64,hexadecimal"` // SHA-256 of the whole file, verified on completion
+}
+
+// UploadSessionResponse represents a resumable upload in API responses
+type UploadSessionResponse struct {
+	UUID      uuid.UUID `json:"uuid"`
+	MemoryID  uint      `json:"memory_id"`
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size"`
+	Offset    int64     `json:"offset"`
+	ChunkSize int64     `json:"chunk_size"` // recommended size of each PATCH
+	ExpiresAt time.Time `json:"expires_at"`
+	UploadURL string    `json:"upload_url"`
+}
+
+// ToResponse converts UploadSession to UploadSessionResponse
+func (u *UploadSession) ToResponse(chunkSize int64) UploadSessionResponse {
+	return UploadSessionResponse{
+		UUID:      u.UUID,
+		MemoryID:  u.MemoryID,
+		Filename:  u.Filename,
+		Size:      u.Size,
+		Offset:    u.Offset,
+		ChunkSize: chunkSize,
+		ExpiresAt: u.ExpiresAt,
+		UploadURL: "/api/v1/media/uploads/" + u.UUID.String(),
+	}
+}
@@ -8,27 +8,76 @@ import (
 )
 
 type User struct {
-	ID           uint           `json:"id" gorm:"primaryKey"`
-	UUID         uuid.UUID      `json:"uuid" gorm:"type:uuid;default:gen_random_uuid();uniqueIndex"`
-	Username     string         `json:"username" gorm:"uniqueIndex;not null" validate:"required,min=3,max=50"`
-	Email        string         `json:"email" gorm:"uniqueIndex;not null" validate:"required,email"`
-	PasswordHash string         `json:"-" gorm:"not null"`
-	FullName     string         `json:"full_name" gorm:"size:255"`
-	AvatarURL    string         `json:"avatar_url" gorm:"type:text"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+	ID                  uint           `json:"id" gorm:"primaryKey"`
+	UUID                uuid.UUID      `json:"uuid" gorm:"type:uuid;default:gen_random_uuid();uniqueIndex"`
+	Username            string         `json:"username" gorm:"uniqueIndex;not null" validate:"required,min=3,max=50"`
+	Email               string         `json:"email" gorm:"uniqueIndex;not null" validate:"required,email"`
+	EmailVerifiedAt     *time.Time     `json:"email_verified_at"`
+	PendingEmail        string         `json:"pending_email" gorm:"size:255"` // new address awaiting verification
+	PasswordHash        string         `json:"-" gorm:"not null"`
+	FullName            string         `json:"full_name" gorm:"size:255"`
+	AvatarURL           string         `json:"avatar_url" gorm:"type:text"`
+	Bio                 string         `json:"bio" gorm:"type:text"`
+	ProfileVisibility   string         `json:"profile_visibility" gorm:"size:20;not null;default:'public'"`
+	Role                string         `json:"role" gorm:"size:20;not null;default:'user'"`
+	DeletionScheduledAt *time.Time     `json:"deletion_scheduled_at" gorm:"index"` // account is purged after this time
+	TwoFactorSecret     string         `json:"-" gorm:"type:text"`                 // encrypted TOTP secret, set during enrollment
+	TwoFactorEnabledAt  *time.Time     `json:"two_factor_enabled_at"`
+	TwoFactorLastStep   int64          `json:"-" gorm:"not null;default:0"` // last accepted TOTP time step, prevents replay
+	StorageQuotaBytes   *int64         `json:"-"`                           // overrides the role quota, 0 = unlimited
+	StorageQuotaFiles   *int64         `json:"-"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 
 	// Relationships
-	Memories []Memory `json:"memories,omitempty" gorm:"foreignKey:UserID"`
+	Memories []Memory      `json:"memories,omitempty" gorm:"foreignKey:UserID"`
 	Sessions []UserSession `json:"-" gorm:"foreignKey:UserID"`
-	Likes    []MemoryLike `json:"-" gorm:"foreignKey:UserID"`
+	Likes    []MemoryLike  `json:"-" gorm:"foreignKey:UserID"`
 }
 
 func (User) TableName() string {
 	return "mm_users"
 }
 
+// User roles
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Profile visibility settings
+const (
+	ProfileVisibilityPublic  = "public"
+	ProfileVisibilityPrivate = "private"
+)
+
+// IsProfilePublic reports whether other users may view the profile and its memory map
+func (u *User) IsProfilePublic() bool {
+	return u.ProfileVisibility != ProfileVisibilityPrivate
+}
+
+// IsEmailVerified reports whether the user confirmed their email address
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// IsTwoFactorEnabled reports whether login requires a second factor
+func (u *User) IsTwoFactorEnabled() bool {
+	return u.TwoFactorEnabledAt != nil
+}
+
+// IsAdmin reports whether the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == RoleAdmin
+}
+
+// IsModerator reports whether the user can moderate content (moderators and admins)
+func (u *User) IsModerator() bool {
+	return u.Role == RoleModerator || u.Role == RoleAdmin
+}
+
 // UserRegistrationRequest represents the request for user registration
 type UserRegistrationRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
@@ -45,32 +94,141 @@ type UserLoginRequest struct {
 
 // UserResponse represents the user response (without sensitive data)
 type UserResponse struct {
+	ID                  uint       `json:"id"`
+	UUID                uuid.UUID  `json:"uuid"`
+	Username            string     `json:"username"`
+	Email               string     `json:"email"`
+	EmailVerified       bool       `json:"email_verified"`
+	PendingEmail        string     `json:"pending_email,omitempty"`
+	FullName            string     `json:"full_name"`
+	AvatarURL           string     `json:"avatar_url"`
+	Bio                 string     `json:"bio"`
+	Role                string     `json:"role"`
+	ProfileVisibility   string     `json:"profile_visibility"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	TwoFactorEnabled    bool       `json:"two_factor_enabled"`
+	HasPassword         bool       `json:"has_password"` // false for accounts created by social login until a password is set
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// ToResponse converts User to UserResponse
+func (u *User) ToResponse() UserResponse {
+	return UserResponse{
+		ID:                  u.ID,
+		UUID:                u.UUID,
+		Username:            u.Username,
+		Email:               u.Email,
+		EmailVerified:       u.IsEmailVerified(),
+		PendingEmail:        u.PendingEmail,
+		FullName:            u.FullName,
+		AvatarURL:           u.AvatarURL,
+		Bio:                 u.Bio,
+		Role:                u.Role,
+		ProfileVisibility:   u.ProfileVisibility,
+		DeletionScheduledAt: u.DeletionScheduledAt,
+		TwoFactorEnabled:    u.IsTwoFactorEnabled(),
+		HasPassword:         u.PasswordHash != "",
+		CreatedAt:           u.CreatedAt,
+		UpdatedAt:           u.UpdatedAt,
+	}
+}
+
+// ChangePasswordRequest represents the request for changing the password of the current user
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"` // not needed to set the first password of an account without one
+	NewPassword     string `json:"new_password" validate:"required,min=6"`
+	Code            string `json:"code"` // TOTP code, for accounts without a password
+}
+
+// ChangeEmailRequest represents the request for changing the email of the current user
+type ChangeEmailRequest struct {
+	NewEmail        string `json:"new_email" validate:"required,email"`
+	CurrentPassword string `json:"current_password"`
+	Code            string `json:"code"` // TOTP code, for accounts without a password
+}
+
+// DeleteAccountRequest represents the request for deleting the current user's account
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"` // TOTP code, for accounts without a password
+}
+
+// AccountDeletionResponse tells when a scheduled account deletion takes effect
+type AccountDeletionResponse struct {
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
+// PublicUserResponse is the projection of a user embedded in other users' content.
+// Email is only filled when the viewer is the user themself or an admin.
+type PublicUserResponse struct {
 	ID        uint      `json:"id"`
 	UUID      uuid.UUID `json:"uuid"`
 	Username  string    `json:"username"`
-	Email     string    `json:"email"`
 	FullName  string    `json:"full_name"`
 	AvatarURL string    `json:"avatar_url"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Email     string    `json:"email,omitempty"`
 }
 
-// ToResponse converts User to UserResponse
-func (u *User) ToResponse() UserResponse {
-	return UserResponse{
+// ToPublicResponse converts User to PublicUserResponse for the given viewer
+func (u *User) ToPublicResponse(viewer Viewer) PublicUserResponse {
+	response := PublicUserResponse{
 		ID:        u.ID,
 		UUID:      u.UUID,
 		Username:  u.Username,
-		Email:     u.Email,
 		FullName:  u.FullName,
 		AvatarURL: u.AvatarURL,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
 	}
+
+	if viewer.CanSeePrivateFieldsOf(u.ID) {
+		response.Email = u.Email
+	}
+
+	return response
+}
+
+// Viewer identifies the caller a response is rendered for; the zero value is an anonymous caller
+type Viewer struct {
+	UserID  uint
+	IsAdmin bool
+}
+
+// CanSeePrivateFieldsOf reports whether the viewer may see private fields of the given user
+func (v Viewer) CanSeePrivateFieldsOf(userID uint) bool {
+	return v.IsAdmin || (v.UserID != 0 && v.UserID == userID)
 }
 
 // UserUpdateRequest represents the request for updating user profile
 type UserUpdateRequest struct {
-	FullName  string `json:"full_name"`
-	AvatarURL string `json:"avatar_url"`
-}
\ No newline at end of file
+	FullName          string `json:"full_name"`
+	AvatarURL         string `json:"avatar_url"`
+	Bio               string `json:"bio" validate:"max=1000"`
+	ProfileVisibility string `json:"profile_visibility" validate:"omitempty,oneof=public private"`
+}
+
+// UserProfileResponse represents the public profile of a user
+type UserProfileResponse struct {
+	UUID          uuid.UUID `json:"uuid"`
+	Username      string    `json:"username"`
+	FullName      string    `json:"full_name"`
+	AvatarURL     string    `json:"avatar_url"`
+	Bio           string    `json:"bio"`
+	IsPrivate     bool      `json:"is_private"`
+	MemoryCount   int64     `json:"memory_count"`
+	LocationCount int64     `json:"location_count"`
+	CountryCount  int64     `json:"country_count"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ToProfileResponse converts User to UserProfileResponse; counts are filled by the caller
+func (u *User) ToProfileResponse() UserProfileResponse {
+	return UserProfileResponse{
+		UUID:      u.UUID,
+		Username:  u.Username,
+		FullName:  u.FullName,
+		AvatarURL: u.AvatarURL,
+		Bio:       u.Bio,
+		IsPrivate: !u.IsProfilePublic(),
+		CreatedAt: u.CreatedAt,
+	}
+}
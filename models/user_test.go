@@ -0,0 +1,133 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func testAuthor() User {
+	now := time.Now()
+	return User{
+		ID:                 7,
+		UUID:               uuid.New(),
+		Username:           "ada",
+		Email:              "ada@example.com",
+		EmailVerifiedAt:    &now,
+		PendingEmail:       "ada@new.example.com",
+		FullName:           "Ada Lovelace",
+		AvatarURL:          "https://example.com/ada.png",
+		Role:               RoleModerator,
+		TwoFactorEnabledAt: &now,
+	}
+}
+
+// privateUserFields are the JSON fields of a user no other viewer may see
+var privateUserFields = []string{
+	"email", "pending_email", "email_verified", "email_verified_at", "role",
+	"two_factor_enabled", "two_factor_enabled_at", "deletion_scheduled_at", "last_login_at",
+}
+
+// assertNoPrivateFields fails when the JSON of value carries a private field of a user
+// anywhere in it, or the user's email address in any field
+func assertNoPrivateFields(t *testing.T, value interface{}, email string) {
+	t.Helper()
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(encoded), email) {
+		t.Errorf("response contains the email address %s: %s", email, encoded)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	var walk func(path string, node interface{})
+	walk = func(path string, node interface{}) {
+		switch node := node.(type) {
+		case map[string]interface{}:
+			for key, child := range node {
+				for _, field := range privateUserFields {
+					if key == field {
+						t.Errorf("response has private field %s.%s", path, key)
+					}
+				}
+				walk(path+"."+key, child)
+			}
+		case []interface{}:
+			for _, child := range node {
+				walk(path+"[]", child)
+			}
+		}
+	}
+	walk("", decoded)
+}
+
+func TestPublicResponseHidesEmailFromOtherViewers(t *testing.T) {
+	author := testAuthor()
+
+	for name, viewer := range map[string]Viewer{
+		"anonymous":  {},
+		"other user": {UserID: 8},
+	} {
+		t.Run(name, func(t *testing.T) {
+			response := author.ToPublicResponse(viewer)
+			assertNoPrivateFields(t, response, author.Email)
+			if response.Username != "ada" || response.FullName != "Ada Lovelace" || response.AvatarURL == "" {
+				t.Errorf("public fields missing: %+v", response)
+			}
+		})
+	}
+}
+
+func TestPublicResponseShowsEmailToOwnerAndAdmin(t *testing.T) {
+	author := testAuthor()
+
+	for name, viewer := range map[string]Viewer{
+		"owner": {UserID: author.ID},
+		"admin": {UserID: 1, IsAdmin: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := author.ToPublicResponse(viewer).Email; got != author.Email {
+				t.Errorf("email = %q, want %q", got, author.Email)
+			}
+		})
+	}
+}
+
+func TestMemoryResponseEmbedsPublicAuthor(t *testing.T) {
+	author := testAuthor()
+	memory := Memory{
+		ID:       1,
+		UUID:     uuid.New(),
+		UserID:   author.ID,
+		Title:    "Harbour walk",
+		IsPublic: true,
+		User:     author,
+		Location: Location{ID: 3, Name: "Harbour", CreatedBy: &author.ID},
+	}
+
+	response := memory.ToResponseFor(Viewer{})
+	assertNoPrivateFields(t, response, author.Email)
+	if response.User.Username != author.Username {
+		t.Errorf("author username = %q, want %q", response.User.Username, author.Username)
+	}
+
+	// ToResponse renders for an anonymous viewer
+	assertNoPrivateFields(t, memory.ToResponse(), author.Email)
+
+	if got := memory.ToResponseFor(Viewer{UserID: author.ID}).User.Email; got != author.Email {
+		t.Errorf("author sees email %q, want %q", got, author.Email)
+	}
+}
+
+func TestProfileResponseHasNoPrivateFields(t *testing.T) {
+	author := testAuthor()
+	assertNoPrivateFields(t, author.ToProfileResponse(), author.Email)
+}
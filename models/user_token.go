@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+// Account token purposes
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeLoginChallenge    = "login_challenge"
+)
+
+// UserToken is a single-use, expiring token emailed to a user. Only the hash of the
+// token is stored; the token itself is signed so forged values are rejected before
+// touching the database.
+type UserToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Purpose   string     `json:"purpose" gorm:"size:32;not null;index"`
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Email     string     `json:"email" gorm:"not null"` // address the token was sent to
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+func (UserToken) TableName() string {
+	return "mm_user_tokens"
+}
+
+// IsExpired reports whether the token can no longer be used
+func (t *UserToken) IsExpired() bool {
+	return time.Now().After(t.ExpiresAt)
+}
+
+// VerifyEmailRequest represents the request for confirming an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ForgotPasswordRequest represents the request for a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest represents the request for choosing a new password with a reset token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=6"`
+}
@@ -0,0 +1,317 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"map-memories-api/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// discoveryDocument is the subset of the OpenID provider metadata in use
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// idTokenClaims are the ID token claims in use. email_verified is a string for some
+// providers (Apple), so it is decoded separately.
+type idTokenClaims struct {
+	Nonce         string          `json:"nonce"`
+	Email         string          `json:"email"`
+	EmailVerified json.RawMessage `json:"email_verified"`
+	Name          string          `json:"name"`
+	Picture       string          `json:"picture"`
+	jwt.RegisteredClaims
+}
+
+// keyRefreshInterval limits how often a token with an unknown key ID can make us download
+// the JWKS again, so made-up key IDs cannot be used to hammer the provider
+const keyRefreshInterval = time.Minute
+
+// OIDCProvider implements Provider for any OpenID Connect compliant server
+// (Google, Apple, Keycloak, a local mock, ...) using discovery and the JWKS endpoint
+type OIDCProvider struct {
+	config config.OAuthProviderConfig
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *discoveryDocument
+	keys          *keySet
+	keysFetchedAt time.Time
+
+	// refreshMu lets only one request at a time download the JWKS
+	refreshMu sync.Mutex
+}
+
+// NewOIDCProvider creates a provider; metadata is discovered on first use
+func NewOIDCProvider(cfg config.OAuthProviderConfig, client *http.Client) *OIDCProvider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &OIDCProvider{config: cfg, client: client}
+}
+
+// Name implements Provider
+func (p *OIDCProvider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL implements Provider
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, request AuthRequest) (string, error) {
+	discovery, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.config.ClientID)
+	params.Set("redirect_uri", p.config.RedirectURL)
+	params.Set("scope", strings.Join(p.config.Scopes, " "))
+	params.Set("state", request.State)
+	params.Set("nonce", request.Nonce)
+	params.Set("code_challenge", request.CodeChallenge)
+	params.Set("code_challenge_method", "S256")
+	if p.config.ResponseMode != "" {
+		params.Set("response_mode", p.config.ResponseMode)
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+// Exchange implements Provider
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error) {
+	discovery, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokenResponse struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.doJSON(req, &tokenResponse); err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+	if tokenResponse.Error != "" {
+		return nil, fmt.Errorf("token exchange failed: %s %s", tokenResponse.Error, tokenResponse.ErrorDescription)
+	}
+	if tokenResponse.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	claims, err := p.verifyIDToken(ctx, tokenResponse.IDToken, discovery.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("id_token nonce does not match")
+	}
+
+	return &Identity{
+		Provider:      p.config.Name,
+		Subject:       claims.Subject,
+		Email:         strings.TrimSpace(claims.Email),
+		EmailVerified: parseEmailVerified(claims.EmailVerified),
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+// verifyIDToken checks the signature, issuer, audience and expiry of an ID token
+func (p *OIDCProvider) verifyIDToken(ctx context.Context, rawToken, issuer string) (*idTokenClaims, error) {
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.publicKey(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id_token has no subject")
+	}
+	return claims, nil
+}
+
+// publicKey returns the signing key with the given ID, refreshing the key set when the
+// key is unknown (providers rotate keys), at most once per keyRefreshInterval
+func (p *OIDCProvider) publicKey(ctx context.Context, kid string) (interface{}, error) {
+	if key, ok, fresh := p.cachedKey(kid); ok {
+		return key, nil
+	} else if fresh {
+		return nil, fmt.Errorf("no signing key with id %q", kid)
+	}
+
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+
+	// Another request may have refreshed the keys while we waited
+	if key, ok, fresh := p.cachedKey(kid); ok {
+		return key, nil
+	} else if fresh {
+		return nil, fmt.Errorf("no signing key with id %q", kid)
+	}
+
+	keys, err := p.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := keys.find(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("no signing key with id %q", kid)
+}
+
+// cachedKey looks a key up in the cached key set and reports whether the set was
+// fetched too recently to be refreshed
+func (p *OIDCProvider) cachedKey(kid string) (interface{}, bool, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys == nil {
+		return nil, false, false
+	}
+	if key, ok := p.keys.find(kid); ok {
+		return key, true, true
+	}
+	return nil, false, time.Since(p.keysFetchedAt) < keyRefreshInterval
+}
+
+// fetchKeys downloads the provider's JWKS
+func (p *OIDCProvider) fetchKeys(ctx context.Context) (*keySet, error) {
+	discovery, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var document jwksDocument
+	if err := p.doJSON(req, &document); err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+
+	keys, err := parseKeySet(document)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	p.mu.Unlock()
+	return keys, nil
+}
+
+// metadata returns the discovery document, fetching it on first use
+func (p *OIDCProvider) metadata(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	discovery := p.discovery
+	p.mu.Unlock()
+	if discovery != nil {
+		return discovery, nil
+	}
+
+	endpoint := strings.TrimRight(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	discovery = &discoveryDocument{}
+	if err := p.doJSON(req, discovery); err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed for %s: %w", p.config.Name, err)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery document of %s is incomplete", p.config.Name)
+	}
+	// The issuer in the document must be the one we trust, or its ID tokens would be
+	// accepted for another issuer (OpenID Connect Discovery 1.0, section 4.3)
+	if strings.TrimRight(discovery.Issuer, "/") != strings.TrimRight(p.config.Issuer, "/") {
+		return nil, fmt.Errorf("OIDC discovery document of %s names issuer %q instead of %q", p.config.Name, discovery.Issuer, p.config.Issuer)
+	}
+
+	p.mu.Lock()
+	p.discovery = discovery
+	p.mu.Unlock()
+	return discovery, nil
+}
+
+// doJSON performs a request and decodes a JSON response body
+func (p *OIDCProvider) doJSON(req *http.Request, target interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		return fmt.Errorf("unexpected response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("provider returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// parseEmailVerified accepts both boolean and string ("true") values
+func parseEmailVerified(raw json.RawMessage) bool {
+	var verified bool
+	if err := json.Unmarshal(raw, &verified); err == nil {
+		return verified
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return strings.EqualFold(text, "true")
+	}
+	return false
+}
@@ -0,0 +1,372 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"map-memories-api/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testClientID = "test-client"
+
+// mockAuthorization is what the mock provider remembers about an issued code
+type mockAuthorization struct {
+	challenge string
+	nonce     string
+}
+
+// mockOIDCServer is an OpenID provider serving discovery, JWKS and the token endpoint.
+// Codes are handed out by authorize, standing in for the user signing in.
+type mockOIDCServer struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+
+	// issuer is the issuer the discovery document names; the server URL by default
+	issuer string
+	// tokenClaims lets a test change the ID token before it is signed
+	tokenClaims func(claims jwt.MapClaims)
+	// signingKid overrides the key ID in the ID token header
+	signingKid string
+
+	jwksRequests atomic.Int32
+
+	mu    sync.Mutex
+	codes map[string]mockAuthorization
+}
+
+func newMockOIDCServer(t *testing.T) *mockOIDCServer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	m := &mockOIDCServer{t: t, key: key, kid: "key-1", codes: map[string]mockAuthorization{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discovery)
+	mux.HandleFunc("/jwks", m.jwks)
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	m.issuer = m.server.URL
+	return m
+}
+
+func (m *mockOIDCServer) provider(mutate func(cfg *config.OAuthProviderConfig)) *OIDCProvider {
+	cfg := config.OAuthProviderConfig{
+		Name:        "mock",
+		Issuer:      m.server.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://app.test/oauth/mock/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+	}
+	if mutate != nil {
+		mutate(&cfg)
+	}
+	return NewOIDCProvider(cfg, m.server.Client())
+}
+
+func (m *mockOIDCServer) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 m.issuer,
+		"authorization_endpoint": m.server.URL + "/authorize",
+		"token_endpoint":         m.server.URL + "/token",
+		"jwks_uri":               m.server.URL + "/jwks",
+	})
+}
+
+func (m *mockOIDCServer) jwks(w http.ResponseWriter, r *http.Request) {
+	m.jwksRequests.Add(1)
+	json.NewEncoder(w).Encode(jwksDocument{Keys: []jwk{{
+		Kty: "RSA",
+		Kid: m.kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+	}}})
+}
+
+func (m *mockOIDCServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	authorization, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	if !ok || r.PostForm.Get("client_id") != testClientID ||
+		CodeChallenge(r.PostForm.Get("code_verifier")) != authorization.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            m.issuer,
+		"aud":            testClientID,
+		"sub":            "subject-1",
+		"email":          "ada@example.com",
+		"email_verified": "true", // Apple sends a string
+		"name":           "Ada Lovelace",
+		"nonce":          authorization.nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+	if m.tokenClaims != nil {
+		m.tokenClaims(claims)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = m.kid
+	if m.signingKid != "" {
+		token.Header["kid"] = m.signingKid
+	}
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		m.t.Errorf("sign id_token: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer"})
+}
+
+// authorize starts a login with p and returns the code the provider redirects back
+// with, along with the verifier and nonce the server keeps for the callback
+func (m *mockOIDCServer) authorize(p *OIDCProvider) (code, verifier, nonce string) {
+	m.t.Helper()
+
+	state, _ := RandomString()
+	nonce, _ = RandomString()
+	verifier, _ = RandomString()
+	authURL, err := p.AuthCodeURL(context.Background(), AuthRequest{
+		State:         state,
+		Nonce:         nonce,
+		CodeChallenge: CodeChallenge(verifier),
+	})
+	if err != nil {
+		m.t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatalf("parse authorization URL: %v", err)
+	}
+	query := parsed.Query()
+
+	code, _ = RandomString()
+	m.mu.Lock()
+	m.codes[code] = mockAuthorization{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	m.mu.Unlock()
+	return code, verifier, nonce
+}
+
+func TestExchangeReturnsVerifiedIdentity(t *testing.T) {
+	m := newMockOIDCServer(t)
+	p := m.provider(nil)
+
+	code, verifier, nonce := m.authorize(p)
+	identity, err := p.Exchange(context.Background(), code, verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	want := Identity{
+		Provider:      "mock",
+		Subject:       "subject-1",
+		Email:         "ada@example.com",
+		EmailVerified: true,
+		Name:          "Ada Lovelace",
+	}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	m := newMockOIDCServer(t)
+	p := m.provider(func(cfg *config.OAuthProviderConfig) {
+		cfg.Scopes = []string{"name", "email"}
+		cfg.ResponseMode = "form_post"
+	})
+
+	authURL, err := p.AuthCodeURL(context.Background(), AuthRequest{State: "s", Nonce: "n", CodeChallenge: "c"})
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	if !strings.HasPrefix(authURL, m.server.URL+"/authorize?") {
+		t.Fatalf("authorization URL %q does not use the discovered endpoint", authURL)
+	}
+
+	parsed, _ := url.Parse(authURL)
+	query := parsed.Query()
+	for name, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          "http://app.test/oauth/mock/callback",
+		"scope":                 "name email",
+		"state":                 "s",
+		"nonce":                 "n",
+		"code_challenge":        "c",
+		"code_challenge_method": "S256",
+		"response_mode":         "form_post",
+	} {
+		if got := query.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestExchangeRejectsNonceMismatch(t *testing.T) {
+	m := newMockOIDCServer(t)
+	p := m.provider(nil)
+
+	code, verifier, _ := m.authorize(p)
+	if _, err := p.Exchange(context.Background(), code, verifier, "another-login"); err == nil {
+		t.Fatal("Exchange accepted an ID token issued for another login")
+	}
+}
+
+func TestExchangeRejectsWrongCodeVerifier(t *testing.T) {
+	m := newMockOIDCServer(t)
+	p := m.provider(nil)
+
+	code, _, nonce := m.authorize(p)
+	wrongVerifier, _ := RandomString()
+	if _, err := p.Exchange(context.Background(), code, wrongVerifier, nonce); err == nil {
+		t.Fatal("Exchange succeeded with a code verifier that does not match the challenge")
+	}
+}
+
+func TestExchangeRejectsInvalidIDTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(claims jwt.MapClaims)
+	}{
+		{"other audience", func(claims jwt.MapClaims) { claims["aud"] = "another-client" }},
+		{"expired", func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-10 * time.Minute).Unix() }},
+		{"no expiry", func(claims jwt.MapClaims) { delete(claims, "exp") }},
+		{"other issuer", func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example" }},
+		{"no subject", func(claims jwt.MapClaims) { delete(claims, "sub") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockOIDCServer(t)
+			m.tokenClaims = tt.mutate
+			p := m.provider(nil)
+
+			code, verifier, nonce := m.authorize(p)
+			if _, err := p.Exchange(context.Background(), code, verifier, nonce); err == nil {
+				t.Fatal("Exchange accepted the ID token")
+			}
+		})
+	}
+}
+
+func TestExchangeRejectsTokenSignedWithAnotherKey(t *testing.T) {
+	m := newMockOIDCServer(t)
+	p := m.provider(nil)
+
+	code, verifier, nonce := m.authorize(p)
+	if _, err := p.Exchange(context.Background(), code, verifier, nonce); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	// Same key ID, different key: the signature must not verify
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	published := m.key
+	m.key = other
+	code, verifier, nonce = m.authorize(p)
+	if _, err := p.Exchange(context.Background(), code, verifier, nonce); err == nil {
+		t.Fatal("Exchange accepted an ID token with a forged signature")
+	}
+	m.key = published
+}
+
+func TestDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	m := newMockOIDCServer(t)
+	m.issuer = "https://accounts.example"
+	p := m.provider(nil)
+
+	_, err := p.AuthCodeURL(context.Background(), AuthRequest{State: "s", Nonce: "n", CodeChallenge: "c"})
+	if err == nil {
+		t.Fatal("AuthCodeURL used a discovery document naming another issuer")
+	}
+}
+
+func TestUnknownKeyIDRefreshIsRateLimited(t *testing.T) {
+	m := newMockOIDCServer(t)
+	p := m.provider(nil)
+
+	code, verifier, nonce := m.authorize(p)
+	if _, err := p.Exchange(context.Background(), code, verifier, nonce); err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if got := m.jwksRequests.Load(); got != 1 {
+		t.Fatalf("JWKS requests = %d, want 1", got)
+	}
+
+	// Tokens with made-up key IDs must not trigger a download each
+	m.signingKid = "unknown"
+	for i := 0; i < 3; i++ {
+		code, verifier, nonce := m.authorize(p)
+		if _, err := p.Exchange(context.Background(), code, verifier, nonce); err == nil {
+			t.Fatal("Exchange accepted an ID token with an unknown key ID")
+		}
+	}
+	if got := m.jwksRequests.Load(); got != 1 {
+		t.Errorf("JWKS requests = %d after unknown key IDs, want 1", got)
+	}
+
+	// Once the interval has passed a rotated key is picked up
+	m.kid = "key-2"
+	m.signingKid = ""
+	p.mu.Lock()
+	p.keysFetchedAt = time.Now().Add(-keyRefreshInterval)
+	p.mu.Unlock()
+
+	code, verifier, nonce = m.authorize(p)
+	if _, err := p.Exchange(context.Background(), code, verifier, nonce); err != nil {
+		t.Fatalf("Exchange after key rotation: %v", err)
+	}
+	if got := m.jwksRequests.Load(); got != 2 {
+		t.Errorf("JWKS requests = %d after key rotation, want 2", got)
+	}
+}
+
+func TestNameFromUserParam(t *testing.T) {
+	tests := map[string]string{
+		`{"name":{"firstName":"Ada","lastName":"Lovelace"},"email":"ada@example.com"}`: "Ada Lovelace",
+		`{"name":{"firstName":"Ada"}}`: "Ada",
+		``:                             "",
+		`not json`:                     "",
+	}
+	for raw, want := range tests {
+		if got := NameFromUserParam(raw); got != want {
+			t.Errorf("NameFromUserParam(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"map-memories-api/config"
+)
+
+// ErrUnknownProvider is returned for provider names that are not configured
+var ErrUnknownProvider = errors.New("unknown identity provider")
+
+// Identity is the verified information an identity provider returns about a user
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Picture       string
+}
+
+// AuthRequest holds the per-login values bound into the authorization URL
+type AuthRequest struct {
+	State         string
+	Nonce         string
+	CodeChallenge string // S256 PKCE challenge
+}
+
+// Provider is an external identity provider using the authorization code flow
+type Provider interface {
+	Name() string
+	// AuthCodeURL returns the URL the user is sent to for signing in
+	AuthCodeURL(ctx context.Context, request AuthRequest) (string, error)
+	// Exchange redeems an authorization code and returns the verified identity; the
+	// ID token must carry the nonce of the login
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error)
+}
+
+var providers = map[string]Provider{}
+
+// Init registers the providers from the application configuration
+func Init() error {
+	cfg := config.AppConfig.OAuth
+	registered := map[string]Provider{}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, providerConfig := range cfg.Providers {
+		registered[providerConfig.Name] = NewOIDCProvider(providerConfig, client)
+	}
+
+	providers = registered
+	if len(providers) > 0 {
+		log.Printf("Social login enabled for: %v", Names())
+	}
+	return nil
+}
+
+// Register adds or replaces a provider, e.g. one pointing at a mock OIDC server
+func Register(provider Provider) {
+	providers[provider.Name()] = provider
+}
+
+// Get returns the provider with the given name
+func Get(name string) (Provider, error) {
+	provider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, name)
+	}
+	return provider, nil
+}
+
+// Names returns the configured provider names in sorted order
+func Names() []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NameFromUserParam reads the name from the "user" JSON Apple posts along with the code
+// on the first sign in. The email in it is ignored: unlike the ID token it is not signed.
+func NameFromUserParam(raw string) string {
+	var user struct {
+		Name struct {
+			FirstName string `json:"firstName"`
+			LastName  string `json:"lastName"`
+		} `json:"name"`
+	}
+	if raw == "" || json.Unmarshal([]byte(raw), &user) != nil {
+		return ""
+	}
+	return strings.TrimSpace(user.Name.FirstName + " " + user.Name.LastName)
+}
+
+// RandomString returns a URL-safe random string for states, nonces and PKCE verifiers
+func RandomString() (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(random), nil
+}
+
+// CodeChallenge derives the S256 PKCE challenge of a verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,142 @@
+package quota
+
+import (
+	"fmt"
+	"time"
+
+	"map-memories-api/config"
+	"map-memories-api/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Limits are the storage limits of a user; zero means unlimited
+type Limits struct {
+	MaxBytes int64
+	MaxFiles int64
+}
+
+// Usage is the storage a user consumes. Media of deleted memories count until the
+// account is purged, since their files are kept until then.
+type Usage struct {
+	Bytes        int64
+	Files        int64
+	PendingBytes int64 // declared size of unfinished resumable uploads
+	ImageBytes   int64
+	ImageFiles   int64
+	VideoBytes   int64
+	VideoFiles   int64
+}
+
+// ExceededError is returned when an upload would take a user over a limit
+type ExceededError struct {
+	Limits
+	Usage
+	RequestedBytes int64
+}
+
+func (e *ExceededError) Error() string {
+	if e.MaxFiles > 0 && e.Files+1 > e.MaxFiles {
+		return fmt.Sprintf("storage quota exceeded: %d of %d files used", e.Files, e.MaxFiles)
+	}
+	return fmt.Sprintf("storage quota exceeded: %d of %d bytes used, %d more requested",
+		e.Bytes+e.PendingBytes, e.MaxBytes, e.RequestedBytes)
+}
+
+// Details describes the exceeded quota for API responses
+func (e *ExceededError) Details() map[string]interface{} {
+	return map[string]interface{}{
+		"limit_bytes":     e.MaxBytes,
+		"used_bytes":      e.Bytes,
+		"pending_bytes":   e.PendingBytes,
+		"requested_bytes": e.RequestedBytes,
+		"limit_files":     e.MaxFiles,
+		"used_files":      e.Files,
+	}
+}
+
+// LimitsFor returns the limits of a user: their own limits when an admin set them,
+// otherwise the limits of their role, otherwise the defaults
+func LimitsFor(user *models.User) Limits {
+	quotaConfig := config.AppConfig.Quota
+	limits := Limits{MaxBytes: quotaConfig.MaxBytes, MaxFiles: quotaConfig.MaxFiles}
+
+	if maxBytes, ok := quotaConfig.RoleMaxBytes[user.Role]; ok {
+		limits.MaxBytes = maxBytes
+	}
+	if maxFiles, ok := quotaConfig.RoleMaxFiles[user.Role]; ok {
+		limits.MaxFiles = maxFiles
+	}
+	if user.StorageQuotaBytes != nil {
+		limits.MaxBytes = *user.StorageQuotaBytes
+	}
+	if user.StorageQuotaFiles != nil {
+		limits.MaxFiles = *user.StorageQuotaFiles
+	}
+	return limits
+}
+
+// UsageOf adds up the media of a user
+func UsageOf(tx *gorm.DB, userID uint) (Usage, error) {
+	var rows []struct {
+		MediaType string
+		Files     int64
+		Bytes     int64
+	}
+	err := tx.Table("mm_media").
+		Select("mm_media.media_type, COUNT(*) AS files, COALESCE(SUM(mm_media.file_size), 0) AS bytes").
+		Joins("JOIN mm_memories ON mm_memories.id = mm_media.memory_id").
+		Where("mm_memories.user_id = ?", userID).
+		Group("mm_media.media_type").
+		Scan(&rows).Error
+	if err != nil {
+		return Usage{}, err
+	}
+
+	var usage Usage
+	for _, row := range rows {
+		usage.Bytes += row.Bytes
+		usage.Files += row.Files
+		switch row.MediaType {
+		case "image":
+			usage.ImageBytes, usage.ImageFiles = row.Bytes, row.Files
+		case "video":
+			usage.VideoBytes, usage.VideoFiles = row.Bytes, row.Files
+		}
+	}
+
+	err = tx.Model(&models.UploadSession{}).
+		Where("user_id = ? AND expires_at > ?", userID, time.Now()).
+		Select("COALESCE(SUM(size), 0)").Scan(&usage.PendingBytes).Error
+	return usage, err
+}
+
+// Check returns an *ExceededError when adding a file of size bytes would exceed the
+// limits. It does not lock; use Reserve when the file is about to be recorded.
+func Check(tx *gorm.DB, user *models.User, size int64) error {
+	usage, err := UsageOf(tx, user.ID)
+	if err != nil {
+		return err
+	}
+	return check(LimitsFor(user), usage, size)
+}
+
+// Reserve locks the user row until tx ends and then checks the limits, so concurrent
+// uploads of the same user are counted one after the other. The Media row has to be
+// created in the same transaction.
+func Reserve(tx *gorm.DB, user *models.User, size int64) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id").First(&models.User{}, user.ID).Error; err != nil {
+		return err
+	}
+	return Check(tx, user, size)
+}
+
+func check(limits Limits, usage Usage, size int64) error {
+	if (limits.MaxBytes > 0 && usage.Bytes+usage.PendingBytes+size > limits.MaxBytes) ||
+		(limits.MaxFiles > 0 && usage.Files+1 > limits.MaxFiles) {
+		return &ExceededError{Limits: limits, Usage: usage, RequestedBytes: size}
+	}
+	return nil
+}
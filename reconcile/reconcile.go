@@ -0,0 +1,459 @@
+package reconcile
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"map-memories-api/blobs"
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/jobs"
+	"map-memories-api/models"
+	"map-memories-api/storage"
+	"map-memories-api/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrTooManyMissing is returned when a purge would delete the media rows of most
+	// files: that usually means the storage is not mounted or points at the wrong place
+	ErrTooManyMissing = errors.New("most media files are missing from storage")
+	// ErrTooManyOrphans is returned when a purge would delete most stored files: that
+	// usually means the database is not the one the storage belongs to
+	ErrTooManyOrphans = errors.New("most stored files are not referenced by the database")
+)
+
+// batchSize is how many media rows are loaded at a time
+const batchSize = 500
+
+// mediaColumns are the columns needed to check and release the file of a media row
+var mediaColumns = []string{"id", "memory_id", "storage_key", "file_path", "blob_id", "poster_key", "file_size"}
+
+// Options controls a reconciliation run
+type Options struct {
+	// Purge deletes what was found; otherwise it is only reported
+	Purge bool
+	// GracePeriod protects files and blobs younger than it, which uploads in progress
+	// store before their media row exists
+	GracePeriod time.Duration
+	// DeletedMemoryRetention is how long media of deleted memories are kept; 0 keeps them
+	DeletedMemoryRetention time.Duration
+	// Force purges even when most stored files look orphaned or most media rows have no
+	// file, which usually means a misconfiguration rather than something to clean up
+	Force bool
+	// Logf receives a line for every finding; nil reports the counts only
+	Logf func(format string, args ...interface{})
+}
+
+// OptionsFrom returns the options of the scheduled job
+func OptionsFrom(cfg config.ReconcileConfig) Options {
+	return Options{
+		Purge:                  cfg.Purge,
+		GracePeriod:            cfg.GracePeriod,
+		DeletedMemoryRetention: cfg.DeletedMemoryRetention,
+	}
+}
+
+// Report counts what a run found and, when purging, removed
+type Report struct {
+	Purged bool `json:"purged"`
+	// Stored files no media row or blob references
+	OrphanedFiles int   `json:"orphaned_files"`
+	OrphanedBytes int64 `json:"orphaned_bytes"`
+	// Media rows whose file is gone
+	MissingFiles int `json:"missing_files"`
+	// Media of memories deleted longer than the retention ago
+	DeletedMemoryMedia int   `json:"deleted_memory_media"`
+	DeletedMemoryBytes int64 `json:"deleted_memory_bytes"`
+	// Blobs counting fewer references than media rows use them, which would delete
+	// content still in use
+	UndercountedBlobs int `json:"undercounted_blobs"`
+	// Blobs no media row uses
+	UnreferencedBlobs int   `json:"unreferenced_blobs"`
+	UnreferencedBytes int64 `json:"unreferenced_bytes"`
+	// Blobs counting references of media rows that are gone; they are removed once
+	// their last media row is deleted
+	OvercountedBlobs int `json:"overcounted_blobs"`
+	// Deletions that failed
+	Failures int `json:"failures"`
+}
+
+// String summarizes the report in one line
+func (r *Report) String() string {
+	verb := "found"
+	if r.Purged {
+		verb = "removed"
+	}
+	return fmt.Sprintf("%s %d orphaned files (%d bytes), %d media with missing files, "+
+		"%d media of deleted memories (%d bytes), %d unreferenced blobs (%d bytes); "+
+		"%d undercounted and %d overcounted blobs; %d failures",
+		verb, r.OrphanedFiles, r.OrphanedBytes, r.MissingFiles,
+		r.DeletedMemoryMedia, r.DeletedMemoryBytes, r.UnreferencedBlobs, r.UnreferencedBytes,
+		r.UndercountedBlobs, r.OvercountedBlobs, r.Failures)
+}
+
+// runner holds the state of one run
+type runner struct {
+	opts   Options
+	now    time.Time
+	cutoff time.Time // files and blobs after it are in the grace period
+	report *Report
+	// stored holds the keys found in the storage, removed the media rows this run deleted
+	stored  map[string]bool
+	removed map[uint]bool
+}
+
+// Run compares the media storage with the database. It finds media of memories deleted
+// longer than the retention ago, blobs whose reference count is off, stored files
+// nothing references and media rows whose file is gone. With Purge, the media rows and
+// files are deleted and the reference counts fixed; the returned report counts them
+// either way.
+func Run(ctx context.Context, opts Options) (*Report, error) {
+	r := &runner{
+		opts:    opts,
+		now:     time.Now(),
+		report:  &Report{Purged: opts.Purge},
+		stored:  make(map[string]bool),
+		removed: make(map[uint]bool),
+	}
+	r.cutoff = r.now.Add(-opts.GracePeriod)
+
+	// Releasing the media of deleted memories and unused blobs first leaves fewer
+	// files to list
+	steps := []func(context.Context) error{
+		r.deletedMemoryMedia,
+		r.blobReferences,
+		r.orphanedFiles,
+		r.missingFiles,
+	}
+	for _, step := range steps {
+		if err := step(ctx); err != nil {
+			return r.report, err
+		}
+	}
+	return r.report, nil
+}
+
+// deletedMemoryMedia finds the media of memories soft-deleted before the retention
+func (r *runner) deletedMemoryMedia(ctx context.Context) error {
+	if r.opts.DeletedMemoryRetention <= 0 {
+		return nil
+	}
+
+	deletedMemories := database.DB.Unscoped().Model(&models.Memory{}).Select("id").
+		Where("deleted_at < ?", r.now.Add(-r.opts.DeletedMemoryRetention))
+
+	var batch []models.Media
+	return database.DB.WithContext(ctx).Select(mediaColumns).
+		Where("memory_id IN (?)", deletedMemories).
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			for i := range batch {
+				media := &batch[i]
+				r.report.DeletedMemoryMedia++
+				r.report.DeletedMemoryBytes += media.FileSize
+				r.logf("Media %d belongs to deleted memory %d (%d bytes)", media.ID, media.MemoryID, media.FileSize)
+				if r.opts.Purge {
+					r.deleteMedia(ctx, media)
+				}
+			}
+			return ctx.Err()
+		}).Error
+}
+
+// blobReferences compares the reference count of every blob with the media rows using it
+func (r *runner) blobReferences(ctx context.Context) error {
+	var rows []struct {
+		ID        uint
+		Checksum  string
+		Size      int64
+		RefCount  int
+		UpdatedAt time.Time
+		Used      int
+	}
+	if err := database.DB.WithContext(ctx).Table("mm_media_blobs AS b").
+		Select("b.id, b.checksum, b.size, b.ref_count, b.updated_at, COUNT(m.id) AS used").
+		Joins("LEFT JOIN mm_media m ON m.blob_id = b.id").
+		Group("b.id").
+		Having("COUNT(m.id) <> b.ref_count").
+		Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		switch {
+		case row.Used > row.RefCount:
+			r.report.UndercountedBlobs++
+			r.logf("Blob %d (%s) counts %d references but %d media use it", row.ID, row.Checksum, row.RefCount, row.Used)
+			if r.opts.Purge {
+				if err := blobs.FixRefCount(ctx, row.ID); err != nil {
+					r.fail(fmt.Sprintf("fix the reference count of blob %d", row.ID), err)
+				}
+			}
+		case row.Used == 0:
+			if !row.UpdatedAt.Before(r.cutoff) {
+				// An upload may be about to create its media row
+				continue
+			}
+			r.report.UnreferencedBlobs++
+			r.report.UnreferencedBytes += row.Size
+			r.logf("Blob %d (%s) is used by no media (%d bytes)", row.ID, row.Checksum, row.Size)
+			if r.opts.Purge {
+				if _, err := blobs.RemoveUnreferenced(ctx, row.ID, r.cutoff); err != nil {
+					r.fail(fmt.Sprintf("remove blob %d", row.ID), err)
+				}
+			}
+		default:
+			r.report.OvercountedBlobs++
+			r.logf("Blob %d (%s) counts %d references but %d media use it", row.ID, row.Checksum, row.RefCount, row.Used)
+		}
+	}
+	return nil
+}
+
+// orphanedFiles lists the storage and finds the files no media row or blob references.
+// They are only deleted when most files are referenced, unless forced.
+func (r *runner) orphanedFiles(ctx context.Context) error {
+	referenced, err := r.referencedKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Keys loaded before the listing cover every file older than the grace period
+	var orphaned []string
+	if err := storage.Default.List(ctx, "", func(object *storage.Object) error {
+		r.stored[object.Key] = true
+		if referenced[object.Key] || !object.ModTime.Before(r.cutoff) {
+			return nil
+		}
+
+		orphaned = append(orphaned, object.Key)
+		r.report.OrphanedFiles++
+		r.report.OrphanedBytes += object.Size
+		r.logf("File %s is not referenced (%d bytes, modified %s)", object.Key, object.Size, object.ModTime.Format(time.RFC3339))
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if !r.opts.Purge || len(orphaned) == 0 {
+		return nil
+	}
+	if len(orphaned)*2 > len(r.stored) && !r.opts.Force {
+		return fmt.Errorf("%w: %d of %d, not deleting them", ErrTooManyOrphans, len(orphaned), len(r.stored))
+	}
+	for _, key := range orphaned {
+		if err := deleteObject(ctx, key); err != nil {
+			r.fail("delete file "+key, err)
+		}
+	}
+	return nil
+}
+
+// referencedKeys returns the keys of every stored file the database knows: blobs and
+// their poster frames, media stored on their own, and uploads made before storage keys
+func (r *runner) referencedKeys(ctx context.Context) (map[string]bool, error) {
+	referenced := make(map[string]bool)
+
+	var blobBatch []models.MediaBlob
+	if err := database.DB.WithContext(ctx).Select("id", "checksum", "storage_key").
+		FindInBatches(&blobBatch, batchSize, func(tx *gorm.DB, _ int) error {
+			for _, blob := range blobBatch {
+				referenced[blob.StorageKey] = true
+				referenced[storage.PosterKey(blob.Checksum)] = true
+			}
+			return nil
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	local, _ := storage.Default.(*storage.LocalStorage)
+	var mediaBatch []models.Media
+	if err := database.DB.WithContext(ctx).Select("id", "storage_key", "file_path", "poster_key").
+		FindInBatches(&mediaBatch, batchSize, func(tx *gorm.DB, _ int) error {
+			for _, media := range mediaBatch {
+				if media.StorageKey != "" {
+					referenced[media.StorageKey] = true
+				}
+				if media.PosterKey != "" {
+					referenced[media.PosterKey] = true
+				}
+				if media.FilePath != "" && local != nil {
+					if key, ok := local.KeyOf(media.FilePath); ok {
+						referenced[key] = true
+					}
+				}
+			}
+			return nil
+		}).Error; err != nil {
+		return nil, err
+	}
+
+	return referenced, nil
+}
+
+// missingFiles finds the media rows whose file is gone. Rows are only deleted when most
+// files are still there, unless forced.
+func (r *runner) missingFiles(ctx context.Context) error {
+	checked := 0
+	var missing []models.Media
+	var batch []models.Media
+	if err := database.DB.WithContext(ctx).Select(mediaColumns).
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			for i := range batch {
+				media := batch[i]
+				if r.removed[media.ID] {
+					continue
+				}
+				exists, err := r.fileExists(ctx, &media)
+				if err != nil {
+					// Unknown is not missing
+					r.fail(fmt.Sprintf("check the file of media %d", media.ID), err)
+					continue
+				}
+				checked++
+				if !exists {
+					missing = append(missing, media)
+				}
+			}
+			return ctx.Err()
+		}).Error; err != nil {
+		return err
+	}
+
+	r.report.MissingFiles = len(missing)
+	for _, media := range missing {
+		r.logf("Media %d of memory %d has no file (%s)", media.ID, media.MemoryID, fileLocation(&media))
+	}
+	if !r.opts.Purge || len(missing) == 0 {
+		return nil
+	}
+	if len(missing)*2 > checked && !r.opts.Force {
+		return fmt.Errorf("%w: %d of %d, not deleting their media rows", ErrTooManyMissing, len(missing), checked)
+	}
+
+	for i := range missing {
+		r.deleteMedia(ctx, &missing[i])
+	}
+	return nil
+}
+
+// fileExists checks the file of a media row against the listing, asking the storage
+// about files stored after it
+func (r *runner) fileExists(ctx context.Context, media *models.Media) (bool, error) {
+	if media.StorageKey == "" {
+		if media.FilePath == "" {
+			return false, nil
+		}
+		return utils.FileExists(media.FilePath), nil
+	}
+
+	if r.stored[media.StorageKey] {
+		return true, nil
+	}
+	if _, err := storage.Default.Stat(ctx, media.StorageKey); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	r.stored[media.StorageKey] = true
+	return true, nil
+}
+
+// deleteMedia deletes a media row and releases its file the way deleting media does
+func (r *runner) deleteMedia(ctx context.Context, media *models.Media) {
+	result := database.DB.WithContext(ctx).Delete(&models.Media{}, media.ID)
+	if result.Error != nil {
+		r.fail(fmt.Sprintf("delete media %d", media.ID), result.Error)
+		return
+	}
+	r.removed[media.ID] = true
+	if result.RowsAffected == 0 {
+		// Deleted in the meantime by someone who releases the file as well
+		return
+	}
+	if err := blobs.ReleaseMedia(ctx, media); err != nil {
+		r.fail(fmt.Sprintf("release the file of media %d", media.ID), err)
+	}
+}
+
+func (r *runner) logf(format string, args ...interface{}) {
+	if r.opts.Logf != nil {
+		r.opts.Logf(format, args...)
+	}
+}
+
+// fail counts a failed deletion; failures are logged even without Logf
+func (r *runner) fail(action string, err error) {
+	r.report.Failures++
+	log.Printf("Reconcile: failed to %s: %v", action, err)
+}
+
+// deleteObject deletes an orphaned file. Content-addressed files go through blobs, which
+// keeps them when the same content is stored again meanwhile.
+func deleteObject(ctx context.Context, key string) error {
+	if checksum, ok := contentChecksum(key); ok {
+		return blobs.DeleteOrphan(ctx, checksum)
+	}
+	return storage.Default.Delete(ctx, key)
+}
+
+// contentChecksum returns the checksum of a blob or blob poster key
+func contentChecksum(key string) (string, bool) {
+	checksum := strings.TrimSuffix(path.Base(key), ".jpg")
+	if len(checksum) != 64 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(checksum); err != nil {
+		return "", false
+	}
+	if key != storage.BlobKey(checksum) && key != storage.PosterKey(checksum) {
+		return "", false
+	}
+	return checksum, true
+}
+
+// fileLocation describes where a media row expects its file
+func fileLocation(media *models.Media) string {
+	switch {
+	case media.StorageKey != "":
+		return media.StorageKey
+	case media.FilePath != "":
+		return media.FilePath
+	default:
+		return "no storage key or path"
+	}
+}
+
+// ReconcileJob is the job type of the periodic reconciliation
+const ReconcileJob = "storage.reconcile"
+
+// ScheduleJobs registers the reconciliation as a job that runs every cfg.Interval. A
+// purging job logs every deletion; a reporting one only its summary.
+func ScheduleJobs(cfg config.ReconcileConfig) {
+	jobs.Register(ReconcileJob, func(ctx context.Context, _ json.RawMessage) error {
+		opts := OptionsFrom(cfg)
+		if opts.Purge {
+			opts.Logf = func(format string, args ...interface{}) {
+				log.Printf("Reconcile: "+format, args...)
+			}
+		}
+
+		report, err := Run(ctx, opts)
+		log.Printf("Reconcile: %s", report)
+		if errors.Is(err, ErrTooManyMissing) || errors.Is(err, ErrTooManyOrphans) {
+			// Needs a look at the configuration, not another attempt
+			return jobs.Permanent(err)
+		}
+		return err
+	})
+	jobs.Every(ReconcileJob, cfg.Interval)
+}
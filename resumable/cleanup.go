@@ -0,0 +1,55 @@
+package resumable
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"map-memories-api/database"
+	"map-memories-api/jobs"
+	"map-memories-api/models"
+)
+
+// PurgeExpired deletes abandoned uploads and their staged data and returns how many
+// were removed
+func PurgeExpired(now time.Time) (int, error) {
+	var sessions []models.UploadSession
+	if err := database.DB.Where("expires_at < ?", now).Find(&sessions).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, session := range sessions {
+		unlock := Lock(session.UUID)
+		result := database.DB.Where("id = ? AND expires_at < ?", session.ID, now).Delete(&models.UploadSession{})
+		if result.Error == nil && result.RowsAffected > 0 {
+			if err := Remove(session.UUID); err != nil {
+				log.Printf("Failed to remove staged upload %s: %v", session.UUID, err)
+			}
+			purged++
+		}
+		unlock()
+
+		if result.Error != nil {
+			return purged, result.Error
+		}
+	}
+
+	return purged, nil
+}
+
+// CleanupJob is the job type of the periodic removal of abandoned uploads
+const CleanupJob = "uploads.cleanup"
+
+// ScheduleJobs registers the removal of expired uploads as a job that runs every interval
+func ScheduleJobs(interval time.Duration) {
+	jobs.Register(CleanupJob, func(ctx context.Context, _ json.RawMessage) error {
+		purged, err := PurgeExpired(time.Now())
+		if purged > 0 {
+			log.Printf("Removed %d expired uploads", purged)
+		}
+		return err
+	})
+	jobs.Every(CleanupJob, interval)
+}
@@ -0,0 +1,156 @@
+package resumable
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrChecksumMismatch is returned when a chunk does not match its checksum; the
+	// chunk is discarded and can be sent again
+	ErrChecksumMismatch = errors.New("chunk checksum mismatch")
+	// ErrOffsetMismatch is returned when a chunk does not start where the staged data ends
+	ErrOffsetMismatch = errors.New("upload offset mismatch")
+	// ErrChunkTooLarge is returned when a chunk goes past the declared upload size
+	ErrChunkTooLarge = errors.New("chunk exceeds the upload size")
+)
+
+// uploadLock serializes the requests of one upload
+type uploadLock struct {
+	sync.Mutex
+	refs int
+}
+
+var (
+	locksMu sync.Mutex
+	locks   = make(map[uuid.UUID]*uploadLock)
+)
+
+// Lock takes the write lock of an upload and returns the function releasing it. The
+// lock is held in process: the staged data is on the local disk, so every request of
+// an upload has to reach the same instance anyway. The offset is also guarded in the
+// database by AdvanceOffset.
+func Lock(id uuid.UUID) func() {
+	locksMu.Lock()
+	lock := locks[id]
+	if lock == nil {
+		lock = &uploadLock{}
+		locks[id] = lock
+	}
+	lock.refs++
+	locksMu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		locksMu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(locks, id)
+		}
+		locksMu.Unlock()
+	}
+}
+
+// AdvanceOffset moves the offset of an upload past a received chunk and extends its
+// expiry. It fails with ErrOffsetMismatch when the offset was changed since the session
+// was loaded.
+func AdvanceOffset(session *models.UploadSession, offset int64, expiresAt time.Time) error {
+	result := database.DB.Model(&models.UploadSession{}).
+		Where(`id = ? AND "offset" = ?`, session.ID, session.Offset).
+		Updates(map[string]interface{}{
+			"offset":     offset,
+			"expires_at": expiresAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrOffsetMismatch
+	}
+
+	session.Offset = offset
+	session.ExpiresAt = expiresAt
+	return nil
+}
+
+// PartPath is where the received bytes of an upload are staged
+func PartPath(id uuid.UUID) string {
+	return filepath.Join(config.AppConfig.Upload.StagingPath, id.String()+".part")
+}
+
+// AppendChunk writes a chunk at offset and returns the new offset. At most limit bytes
+// are read from r. When checksum (a SHA-256 digest) does not match, the staged data is
+// truncated back to offset.
+func AppendChunk(id uuid.UUID, offset, limit int64, r io.Reader, checksum []byte) (int64, error) {
+	if err := os.MkdirAll(config.AppConfig.Upload.StagingPath, 0755); err != nil {
+		return offset, err
+	}
+
+	file, err := os.OpenFile(PartPath(id), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return offset, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return offset, err
+	}
+	switch {
+	case info.Size() < offset:
+		return offset, ErrOffsetMismatch
+	case info.Size() > offset:
+		// Leftovers of a chunk that failed halfway
+		if err := file.Truncate(offset); err != nil {
+			return offset, err
+		}
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return offset, err
+	}
+
+	hasher := sha256.New()
+	written, err := io.Copy(io.MultiWriter(file, hasher), io.LimitReader(r, limit+1))
+	if err == nil && written > limit {
+		err = ErrChunkTooLarge
+	}
+	if err == nil && !bytes.Equal(hasher.Sum(nil), checksum) {
+		err = ErrChecksumMismatch
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		file.Truncate(offset)
+		return offset, err
+	}
+
+	return offset + written, nil
+}
+
+// Open opens the staged data of an upload for reading
+func Open(id uuid.UUID) (*os.File, error) {
+	return os.Open(PartPath(id))
+}
+
+// Remove deletes the staged data of an upload
+func Remove(id uuid.UUID) error {
+	if err := os.Remove(PartPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -19,6 +19,11 @@ func SetupRoutes(r *gin.Engine) {
 	memoryController := &controllers.MemoryController{}
 	locationController := &controllers.LocationController{}
 	mediaController := &controllers.MediaController{}
+	storageController := &controllers.StorageController{}
+	jobController := &controllers.JobController{}
+	categoryController := &controllers.CategoryController{}
+	userController := &controllers.UserController{}
+	wellKnownController := &controllers.WellKnownController{}
 
 	// CORS middleware
 	r.Use(middleware.CORSMiddleware())
@@ -44,6 +49,9 @@ func SetupRoutes(r *gin.Engine) {
 		))
 	})
 
+	// Public keys for verifying access tokens
+	r.GET("/.well-known/jwks.json", wellKnownController.GetJWKS)
+
 	// API version 1
 	v1 := r.Group("/api/v1")
 	{
@@ -55,29 +63,62 @@ func SetupRoutes(r *gin.Engine) {
 			{
 				auth.POST("/register", authController.Register)
 				auth.POST("/login", authController.Login)
+				auth.POST("/login/2fa", authController.LoginTwoFactor)
+				auth.POST("/verify-email", authController.VerifyEmail)
+				auth.POST("/forgot-password", authController.ForgotPassword)
+				auth.POST("/reset-password", authController.ResetPassword)
 				auth.GET("/test-header", authController.TestAuthHeader) // Test endpoint
+
+				// Social login; starting while signed in links the provider to the account
+				auth.GET("/oauth/providers", authController.GetOAuthProviders)
+				auth.POST("/oauth/:provider/start", middleware.OptionalAuthMiddleware(), authController.StartOAuth)
+				auth.POST("/oauth/:provider/callback", middleware.OptionalAuthMiddleware(), authController.OAuthCallback)
 			}
 
-			// Public locations (read-only)
+			// Public locations (read-only, viewer identity is optional)
 			locations := public.Group("locations")
+			locations.Use(middleware.OptionalAuthMiddleware())
+			locations.Use(middleware.RequireScope(models.ScopeLocationsRead, ""))
 			{
 				locations.GET("", locationController.GetLocations)
 				locations.GET("/:uuid", locationController.GetLocation)
 				locations.GET("/nearby", locationController.SearchNearbyLocations)
+				locations.GET("/containing", locationController.GetContainingLocations)
 				locations.GET("/:uuid/memories", locationController.GetLocationMemories)
+				locations.GET("/:uuid/history", locationController.GetLocationHistory)
+			}
+
+			// Location categories
+			public.GET("categories", categoryController.GetCategories)
+
+			// Public user data (viewer identity is optional)
+			users := public.Group("users")
+			users.Use(middleware.OptionalAuthMiddleware())
+			users.Use(middleware.RequireScope(models.ScopeProfileRead, ""))
+			{
+				users.GET("/:username", userController.GetUserProfile)
+				users.GET("/:username/memories", userController.GetUserMemories)
+				users.GET("/:username/locations", userController.GetUserLocations)
+				users.GET("/:username/stats", userController.GetUserStats)
 			}
 
-			// Public memories (read-only, public memories only)
+			// Public memories (read-only, private memories only for their owner)
 			memories := public.Group("memories")
+			memories.Use(middleware.OptionalAuthMiddleware())
+			memories.Use(middleware.RequireScope(models.ScopeMemoriesRead, ""))
 			{
 				memories.GET("", memoryController.GetMemories) // Will filter public memories
 				memories.GET("/:uuid", memoryController.GetMemory)
+				memories.GET("/:uuid/regions", memoryController.GetMemoryRegions)
 			}
 
 			// Public media (serve files)
 			media := public.Group("media")
 			{
 				media.GET("/:uuid/file", mediaController.ServeMediaFile)
+				media.HEAD("/:uuid/file", mediaController.ServeMediaFile)
+				media.GET("/:uuid/poster", mediaController.ServeMediaPoster)
+				media.HEAD("/:uuid/poster", mediaController.ServeMediaPoster)
 			}
 		}
 
@@ -88,13 +129,41 @@ func SetupRoutes(r *gin.Engine) {
 			// Authentication profile routes
 			auth := protected.Group("auth")
 			{
-				auth.GET("/profile", authController.GetProfile)
-				auth.PUT("/profile", authController.UpdateProfile)
-				auth.POST("/logout", authController.Logout)
+				auth.GET("/profile", middleware.RequireScope(models.ScopeProfileRead, ""), authController.GetProfile)
+				auth.GET("/storage", middleware.RequireScope(models.ScopeMediaRead, ""), storageController.GetStorageUsage)
+
+				// Managing the account itself needs a real session, not an API token
+				account := auth.Group("")
+				account.Use(middleware.RequireSessionAuth())
+				{
+					account.PUT("/profile", authController.UpdateProfile)
+					account.POST("/logout", authController.Logout)
+					account.POST("/verify-email/resend", authController.ResendVerificationEmail)
+					account.PUT("/password", authController.ChangePassword)
+					account.PUT("/email", authController.ChangeEmail)
+					account.DELETE("/account", authController.DeleteAccount)
+					account.GET("/export", authController.ExportAccount)
+					account.GET("/identities", authController.GetIdentities)
+					account.DELETE("/identities/:provider", authController.UnlinkIdentity)
+					account.GET("/sessions", authController.GetSessions)
+					account.DELETE("/sessions/:id", authController.RevokeSession)
+
+					// Personal API tokens
+					account.GET("/tokens", authController.GetAPITokens)
+					account.POST("/tokens", authController.CreateAPIToken)
+					account.DELETE("/tokens/:uuid", authController.RevokeAPIToken)
+
+					// Two-factor authentication
+					account.POST("/2fa/setup", authController.SetupTwoFactor)
+					account.POST("/2fa/enable", authController.EnableTwoFactor)
+					account.POST("/2fa/disable", authController.DisableTwoFactor)
+					account.POST("/2fa/recovery-codes", authController.RegenerateRecoveryCodes)
+				}
 			}
 
 			// Memory management
 			memories := protected.Group("memories")
+			memories.Use(middleware.RequireScope(models.ScopeMemoriesRead, models.ScopeMemoriesWrite))
 			{
 				memories.POST("", memoryController.CreateMemory)
 				memories.PUT("/:uuid", memoryController.UpdateMemory)
@@ -104,16 +173,37 @@ func SetupRoutes(r *gin.Engine) {
 
 			// Location management
 			locations := protected.Group("locations")
+			locations.Use(middleware.RequireScope(models.ScopeLocationsRead, models.ScopeLocationsWrite))
 			{
 				locations.POST("", locationController.CreateLocation)
 				locations.PUT("/:uuid", locationController.UpdateLocation)
 				// Delete is admin only - will be added below
+
+				// Suggested edits for locations owned by other users
+				locations.POST("/:uuid/suggestions", locationController.SuggestLocationEdit)
+				locations.GET("/:uuid/suggestions", locationController.GetLocationSuggestions)
+			}
+
+			// Reviewing suggested edits is a moderation action and needs a real session
+			suggestions := protected.Group("locations/suggestions")
+			suggestions.Use(middleware.RequireSessionAuth())
+			{
+				suggestions.GET("", locationController.GetSuggestionQueue)
+				suggestions.POST("/:uuid/approve", locationController.ApproveSuggestion)
+				suggestions.POST("/:uuid/reject", locationController.RejectSuggestion)
 			}
 
 			// Media management
 			media := protected.Group("media")
+			media.Use(middleware.RequireScope(models.ScopeMediaRead, models.ScopeMediaWrite))
 			{
 				media.POST("/upload", mediaController.UploadMedia)
+				media.POST("/upload/batch", mediaController.UploadMediaBatch)
+				media.POST("/uploads", mediaController.CreateUpload)
+				media.GET("/uploads/:uuid", mediaController.GetUpload)
+				media.PATCH("/uploads/:uuid", mediaController.UploadChunk)
+				media.POST("/uploads/:uuid/complete", mediaController.CompleteUpload)
+				media.DELETE("/uploads/:uuid", mediaController.CancelUpload)
 				media.GET("", mediaController.GetMedia)
 				media.GET("/:uuid", mediaController.GetMediaFile)
 				media.PUT("/:uuid", mediaController.UpdateMedia)
@@ -125,6 +215,7 @@ func SetupRoutes(r *gin.Engine) {
 		admin := v1.Group("/admin")
 		admin.Use(middleware.AuthMiddleware())
 		admin.Use(middleware.AdminMiddleware())
+		admin.Use(middleware.RequireSessionAuth())
 		{
 			// Admin location management
 			locations := admin.Group("locations")
@@ -132,6 +223,13 @@ func SetupRoutes(r *gin.Engine) {
 				locations.DELETE("/:uuid", locationController.DeleteLocation)
 			}
 
+			// Admin user management
+			users := admin.Group("users")
+			{
+				users.DELETE("/:uuid/2fa", authController.ResetUserTwoFactor)
+				users.PUT("/:uuid/quota", storageController.UpdateUserQuota)
+			}
+
 			// Admin can access all memories
 			memories := admin.Group("memories")
 			{
@@ -143,6 +241,21 @@ func SetupRoutes(r *gin.Engine) {
 			{
 				media.GET("", mediaController.GetMedia) // All media
 			}
+
+			// Admin storage reports
+			storage := admin.Group("storage")
+			{
+				storage.GET("/top", storageController.GetTopConsumers)
+			}
+
+			// Admin background jobs
+			jobs := admin.Group("jobs")
+			{
+				jobs.GET("", jobController.GetJobs)
+				jobs.GET("/stats", jobController.GetJobStats)
+				jobs.GET("/:uuid", jobController.GetJob)
+				jobs.POST("/:uuid/retry", jobController.RetryJob)
+			}
 		}
 	}
 
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// limitedReadCloser reads at most a limited number of bytes and closes the underlying reader
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// ReadSeeker reads an object of any backend as an io.ReadSeeker. Reads are served by
+// ranged reads from the current offset, so seeking costs nothing until the next read and
+// only the requested parts of the object are transferred.
+type ReadSeeker struct {
+	ctx     context.Context
+	storage Storage
+	key     string
+	size    int64
+	offset  int64
+	body    io.ReadCloser
+}
+
+// NewReadSeeker returns a seekable reader over the object stored under key, whose size
+// has to be known (see Stat)
+func NewReadSeeker(ctx context.Context, s Storage, key string, size int64) *ReadSeeker {
+	return &ReadSeeker{ctx: ctx, storage: s, key: key, size: size}
+}
+
+// Open stats an object and returns a seekable reader over it
+func Open(ctx context.Context, s Storage, key string) (*ReadSeeker, *Object, error) {
+	object, err := s.Stat(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return NewReadSeeker(ctx, s, key, object.Size), object, nil
+}
+
+func (r *ReadSeeker) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.storage.GetRange(r.ctx, r.key, r.offset, r.size-r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if err == io.EOF && r.offset < r.size {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+func (r *ReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("storage: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("storage: negative position")
+	}
+
+	if offset != r.offset {
+		r.closeBody()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+// Close releases the pending ranged read, if any
+func (r *ReadSeeker) Close() error {
+	return r.closeBody()
+}
+
+func (r *ReadSeeker) closeBody() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"map-memories-api/config"
+)
+
+var (
+	// ErrNotFound is returned for keys that have no object
+	ErrNotFound = errors.New("object not found")
+	// ErrNotSupported is returned by backends that cannot create signed URLs
+	ErrNotSupported = errors.New("operation not supported by storage backend")
+	// ErrInvalidKey is returned for keys that are empty or escape the storage root
+	ErrInvalidKey = errors.New("invalid storage key")
+)
+
+// Object describes a stored object
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+	ETag        string
+}
+
+// Storage stores media files under slash-separated keys such as "media/20240101_ab12cd34.jpg"
+type Storage interface {
+	// Put stores the content of r (size bytes) under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object stored under key. The reader also implements io.Seeker when
+	// the backend can seek (local files).
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	// GetRange opens length bytes of the object starting at offset
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Delete removes the object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// Stat returns the object's metadata
+	Stat(ctx context.Context, key string) (*Object, error)
+	// SignedURL returns a URL clients can download the object from directly for ttl
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+	// List calls fn for every object whose key starts with prefix; an error returned by
+	// fn stops the listing and is returned
+	List(ctx context.Context, prefix string, fn func(*Object) error) error
+}
+
+// Default is the storage used for media files
+var Default Storage = NewLocalStorage("./uploads")
+
+// Init configures the default storage from the application configuration
+func Init() error {
+	backend, err := New(config.AppConfig.Storage)
+	if err != nil {
+		return err
+	}
+
+	Default = backend
+	return nil
+}
+
+// New creates the storage backend described by cfg
+func New(cfg config.StorageConfig) (Storage, error) {
+	switch strings.ToLower(cfg.Driver) {
+	case "", "local":
+		log.Printf("Storage: storing media files in %s", cfg.LocalPath)
+		return NewLocalStorage(cfg.LocalPath), nil
+	case "s3":
+		if cfg.S3Bucket == "" || cfg.S3AccessKey == "" || cfg.S3SecretKey == "" {
+			return nil, fmt.Errorf("STORAGE_S3_BUCKET, STORAGE_S3_ACCESS_KEY and STORAGE_S3_SECRET_KEY are required for the s3 storage driver")
+		}
+		backend, err := NewS3Storage(S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PathStyle: cfg.S3PathStyle,
+		})
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Storage: storing media files in bucket %s at %s", cfg.S3Bucket, backend.endpoint.Host)
+		return backend, nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", cfg.Driver)
+	}
+}
+
+// MediaKey returns the key of a media file stored under its own name (uploads before
+// content addressing, see BlobKey)
+func MediaKey(filename string) string {
+	return path.Join("media", path.Base(filename))
+}
+
+// BlobKey returns the content address a file with the given SHA-256 (hex) is stored
+// under; the first two digits spread the keys over directories
+func BlobKey(checksum string) string {
+	return path.Join("blobs", checksum[:2], checksum)
+}
+
+// PosterKey returns the key of the poster frame of the video stored in the blob with the
+// given checksum; media sharing the blob share the poster
+func PosterKey(checksum string) string {
+	return path.Join("posters", checksum[:2], checksum+".jpg")
+}
+
+// MediaPosterKey returns the key of the poster frame of a video stored on its own
+// (uploads before content addressing)
+func MediaPosterKey(mediaUUID string) string {
+	return path.Join("posters", "media", mediaUUID+".jpg")
+}
+
+// cleanKey rejects keys that are empty, absolute or climb out of the storage root
+func cleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != key {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
@@ -37,7 +37,14 @@ func VerifyPassword(hashedPassword, password string) error {
 
 // GenerateJWT generates a JWT token for a user
 func GenerateJWT(user *models.User) (string, error) {
-	claims := JWTClaims{
+	token, _, err := GenerateJWTWithClaims(user)
+	return token, err
+}
+
+// GenerateJWTWithClaims generates a JWT token for a user and returns its claims, whose
+// ID identifies the login session
+func GenerateJWTWithClaims(user *models.User) (string, *JWTClaims, error) {
+	claims := &JWTClaims{
 		UserID:   user.ID,
 		UserUUID: user.UUID,
 		Email:    user.Email,
@@ -52,18 +59,16 @@ func GenerateJWT(user *models.User) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(config.AppConfig.JWT.Secret))
+	signed, err := signJWT(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
 }
 
-// VerifyJWT verifies and parses a JWT token
+// VerifyJWT verifies and parses a JWT token signed with the shared secret or a key from the key directory
 func VerifyJWT(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte(config.AppConfig.JWT.Secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, jwtVerificationKey)
 
 	if err != nil {
 		return nil, err
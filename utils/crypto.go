@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"map-memories-api/config"
+)
+
+// ErrUnknownKeyVersion is returned for values encrypted with a key that is no longer
+// configured
+var ErrUnknownKeyVersion = errors.New("unknown encryption key version")
+
+// EncryptSecret encrypts a value stored at rest (such as a TOTP secret) with AES-GCM
+// using the current ENCRYPTION_KEY. The result has the form v<version>$<base64>, so
+// it can still be decrypted after the key is rotated.
+func EncryptSecret(plaintext string) (string, error) {
+	keys := config.AppConfig.Keys
+	gcm, err := secretCipher(keys.EncryptionKeys[keys.EncryptionKeyVersion])
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return fmt.Sprintf("v%d$%s", keys.EncryptionKeyVersion, base64.RawStdEncoding.EncodeToString(sealed)), nil
+}
+
+// DecryptSecret reverses EncryptSecret with the key version the value was encrypted
+// with. Values without a version predate ENCRYPTION_KEY and use the key derived from
+// JWT_SECRET.
+func DecryptSecret(ciphertext string) (string, error) {
+	version, encoded, versioned := splitCiphertext(ciphertext)
+
+	key := config.AppConfig.JWT.Secret
+	if versioned {
+		var found bool
+		if key, found = config.AppConfig.Keys.EncryptionKeys[version]; !found {
+			return "", ErrUnknownKeyVersion
+		}
+	}
+	gcm, err := secretCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// SecretNeedsRotation reports whether a value was not encrypted with the current key
+func SecretNeedsRotation(ciphertext string) bool {
+	version, _, versioned := splitCiphertext(ciphertext)
+	return !versioned || version != config.AppConfig.Keys.EncryptionKeyVersion
+}
+
+// splitCiphertext separates the key version from an encrypted value
+func splitCiphertext(ciphertext string) (int, string, bool) {
+	prefix, encoded, found := strings.Cut(ciphertext, "$")
+	if !found || !strings.HasPrefix(prefix, "v") {
+		return 0, ciphertext, false
+	}
+	version, err := strconv.Atoi(prefix[1:])
+	if err != nil {
+		return 0, ciphertext, false
+	}
+	return version, encoded, true
+}
+
+// secretCipher builds the AES-GCM cipher for secrets at rest from a configured key
+func secretCipher(key string) (cipher.AEAD, error) {
+	derived := sha256.Sum256([]byte("map-memories:secrets:" + key))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -1,6 +1,9 @@
 package utils
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -11,25 +14,40 @@ import (
 	"time"
 
 	"map-memories-api/config"
+	"map-memories-api/mediainfo"
+	"map-memories-api/models"
+	"map-memories-api/storage"
 
 	"github.com/google/uuid"
 )
 
+// Upload validation errors; the wrapped message says what exactly was wrong
+var (
+	ErrFileTooLarge        = errors.New("file size exceeds maximum allowed size")
+	ErrUnsupportedFileType = errors.New("file type is not allowed")
+	ErrInvalidMediaFile    = errors.New("invalid media file")
+)
+
 // FileInfo represents uploaded file information
 type FileInfo struct {
 	OriginalFilename string
 	Filename         string
-	FilePath         string
+	StorageKey       string
+	BlobID           *uint
 	FileSize         int64
 	MimeType         string
 	MediaType        string
+	Checksum         string // SHA-256 of the content, hex encoded
+	Width            int    // images only
+	Height           int
 }
 
 // IsAllowedFileType checks if the file type is allowed
 func IsAllowedFileType(mimeType string) bool {
+	mimeType = mediainfo.Normalize(mimeType)
 	allowedTypes := config.AppConfig.Upload.AllowedTypes
 	for _, allowedType := range allowedTypes {
-		if strings.EqualFold(mimeType, allowedType) {
+		if mediainfo.Normalize(allowedType) == mimeType {
 			return true
 		}
 	}
@@ -54,115 +72,186 @@ func GenerateUniqueFilename(originalFilename string) string {
 	return fmt.Sprintf("%s_%s%s", timestamp, uniqueID, ext)
 }
 
-// SaveUploadedFile saves an uploaded file to the configured upload directory
-func SaveUploadedFile(file *multipart.FileHeader) (*FileInfo, error) {
-	// Check file size
-	if file.Size > config.AppConfig.Upload.MaxFileSizeInt {
-		return nil, fmt.Errorf("file size exceeds maximum allowed size of %s", config.AppConfig.Upload.MaxFileSize)
-	}
+// PreparedUpload is an uploaded file that was received in full and validated, but not
+// stored yet
+type PreparedUpload struct {
+	FileInfo
+	file      *os.File
+	temporary bool      // file is a spooled copy removed by Close
+	source    io.Closer // closed by Close when the upload opened it
+}
 
+// PrepareUploadedFile reads and validates a file of a multipart upload. The caller must
+// Close the result.
+func PrepareUploadedFile(file *multipart.FileHeader) (*PreparedUpload, error) {
 	// Open the uploaded file
 	src, err := file.Open()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
 	}
-	defer src.Close()
 
-	// Detect MIME type
-	buffer := make([]byte, 512)
-	_, err = src.Read(buffer)
+	upload, err := PrepareUpload(src, file.Filename, config.AppConfig.Upload.MaxFileSizeInt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read file for MIME type detection: %w", err)
+		src.Close()
+		return nil, err
 	}
-	
-	// Reset file pointer
-	_, err = src.Seek(0, io.SeekStart)
+	upload.source = src
+	return upload, nil
+}
+
+// PrepareUpload reads a media file from src and validates it. The size is counted while
+// copying rather than trusted from the client, the format is identified from the
+// content (see mediainfo.Inspect) and a SHA-256 is computed on the way. Sources that
+// are not files are spooled to the staging directory. The caller must Close the result.
+func PrepareUpload(src io.Reader, originalFilename string, maxSize int64) (*PreparedUpload, error) {
+	upload := &PreparedUpload{FileInfo: FileInfo{OriginalFilename: originalFilename}}
+
+	var dst io.Writer = io.Discard
+	if file, ok := src.(*os.File); ok {
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+		}
+		upload.file = file
+	} else {
+		stagingPath := config.AppConfig.Upload.StagingPath
+		if err := os.MkdirAll(stagingPath, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create staging directory: %w", err)
+		}
+		spool, err := os.CreateTemp(stagingPath, "upload-*")
+		if err != nil {
+			return nil, fmt.Errorf("failed to spool uploaded file: %w", err)
+		}
+		upload.file = spool
+		upload.temporary = true
+		dst = spool
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(dst, hasher), io.LimitReader(src, maxSize+1))
 	if err != nil {
-		return nil, fmt.Errorf("failed to reset file pointer: %w", err)
+		upload.Close()
+		return nil, fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	if size > maxSize {
+		upload.Close()
+		return nil, fmt.Errorf("%w of %d bytes", ErrFileTooLarge, maxSize)
 	}
 
-	mimeType := DetectMimeType(buffer, file.Filename)
-	
-	// Check if file type is allowed
-	if !IsAllowedFileType(mimeType) {
-		return nil, fmt.Errorf("file type %s is not allowed", mimeType)
+	info, err := mediainfo.Inspect(upload.file, size, originalFilename)
+	if err != nil {
+		upload.Close()
+		if errors.Is(err, mediainfo.ErrUnknownFormat) {
+			return nil, fmt.Errorf("%w: %v", ErrUnsupportedFileType, err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMediaFile, err)
+	}
+	if !IsAllowedFileType(info.MimeType) {
+		upload.Close()
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFileType, info.MimeType)
+	}
+	if maxPixels := config.AppConfig.Upload.MaxImagePixels; maxPixels > 0 && int64(info.Width)*int64(info.Height) > maxPixels {
+		upload.Close()
+		return nil, fmt.Errorf("%w: image of %dx%d pixels is too large", ErrInvalidMediaFile, info.Width, info.Height)
 	}
 
-	// Generate unique filename
-	filename := GenerateUniqueFilename(file.Filename)
-	
-	// Create upload directory if it doesn't exist
-	uploadPath := config.AppConfig.Upload.Path
-	if err := os.MkdirAll(uploadPath, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	upload.FileSize = size
+	upload.MimeType = info.MimeType
+	upload.MediaType = GetMediaType(info.MimeType)
+	upload.Checksum = hex.EncodeToString(hasher.Sum(nil))
+	upload.Width = info.Width
+	upload.Height = info.Height
+	return upload, nil
+}
+
+// Content returns the validated file from its start
+func (u *PreparedUpload) Content() (io.Reader, error) {
+	if _, err := u.file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to reset file pointer: %w", err)
 	}
+	return io.LimitReader(u.file, u.FileSize), nil
+}
 
-	// Full file path
-	filePath := filepath.Join(uploadPath, filename)
+// Close removes the spooled copy of the upload; files passed in by the caller are left
+// for the caller to close
+func (u *PreparedUpload) Close() error {
+	if u.source != nil {
+		u.source.Close()
+	}
+	if !u.temporary {
+		return nil
+	}
+	u.file.Close()
+	return os.Remove(u.file.Name())
+}
 
-	// Create destination file
-	dst, err := os.Create(filePath)
+// OpenMediaFile opens the stored file of a media record. Records that predate the
+// storage backend are read from their legacy file path.
+func OpenMediaFile(ctx context.Context, media *models.Media) (io.ReadCloser, *storage.Object, error) {
+	if media.StorageKey != "" {
+		return storage.Default.Get(ctx, media.StorageKey)
+	}
+	file, object, err := openLegacyMediaFile(media)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create destination file: %w", err)
+		return nil, nil, err
 	}
-	defer dst.Close()
+	return file, object, nil
+}
 
-	// Copy file content
-	_, err = io.Copy(dst, src)
+// OpenMediaContent opens the stored file of a media record for random access, whatever
+// the storage backend; parts are only read from the storage once they are read
+func OpenMediaContent(ctx context.Context, media *models.Media) (io.ReadSeekCloser, *storage.Object, error) {
+	if media.StorageKey != "" {
+		content, object, err := storage.Open(ctx, storage.Default, media.StorageKey)
+		if err != nil {
+			return nil, nil, err
+		}
+		return content, object, nil
+	}
+	file, object, err := openLegacyMediaFile(media)
 	if err != nil {
-		return nil, fmt.Errorf("failed to save file: %w", err)
+		return nil, nil, err
 	}
+	return file, object, nil
+}
 
-	return &FileInfo{
-		OriginalFilename: file.Filename,
-		Filename:         filename,
-		FilePath:         filePath,
-		FileSize:         file.Size,
-		MimeType:         mimeType,
-		MediaType:        GetMediaType(mimeType),
+// openLegacyMediaFile opens the file of a record stored before the storage backend
+func openLegacyMediaFile(media *models.Media) (*os.File, *storage.Object, error) {
+	file, err := os.Open(media.FilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, storage.ErrNotFound
+		}
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	return file, &storage.Object{
+		Key:         media.FilePath,
+		Size:        info.Size(),
+		ContentType: media.MimeType,
+		ModTime:     info.ModTime(),
 	}, nil
 }
 
-// DetectMimeType detects MIME type from file content and extension
-func DetectMimeType(buffer []byte, filename string) string {
-	// Basic MIME type detection based on file signature
-	if len(buffer) >= 4 {
-		// JPEG
-		if buffer[0] == 0xFF && buffer[1] == 0xD8 && buffer[2] == 0xFF {
-			return "image/jpeg"
-		}
-		// PNG
-		if buffer[0] == 0x89 && buffer[1] == 0x50 && buffer[2] == 0x4E && buffer[3] == 0x47 {
-			return "image/png"
-		}
-		// GIF
-		if string(buffer[0:3]) == "GIF" {
-			return "image/gif"
-		}
-		// MP4
-		if len(buffer) >= 8 && string(buffer[4:8]) == "ftyp" {
-			return "video/mp4"
+// DeleteMediaFile removes the stored file and poster frame of a media record that does not
+// share a blob (see blobs.ReleaseMedia); missing files are ignored
+func DeleteMediaFile(ctx context.Context, media *models.Media) error {
+	if media.PosterKey != "" {
+		if err := storage.Default.Delete(ctx, media.PosterKey); err != nil {
+			return err
 		}
 	}
-
-	// Fallback to extension-based detection
-	ext := strings.ToLower(filepath.Ext(filename))
-	switch ext {
-	case ".jpg", ".jpeg":
-		return "image/jpeg"
-	case ".png":
-		return "image/png"
-	case ".gif":
-		return "image/gif"
-	case ".mp4":
-		return "video/mp4"
-	case ".avi":
-		return "video/avi"
-	case ".mov":
-		return "video/mov"
-	default:
-		return "application/octet-stream"
+	if media.StorageKey != "" {
+		return storage.Default.Delete(ctx, media.StorageKey)
 	}
+	if media.FilePath != "" {
+		return DeleteFile(media.FilePath)
+	}
+	return nil
 }
 
 // DeleteFile deletes a file from the filesystem
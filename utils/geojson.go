@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// geoJSONObject covers the GeoJSON members needed to validate area geometries
+type geoJSONObject struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates,omitempty"`
+	Geometry    *geoJSONObject  `json:"geometry,omitempty"`
+}
+
+// NormalizeBoundary validates a GeoJSON Polygon or MultiPolygon (optionally wrapped in a
+// Feature) and returns the bare geometry re-encoded as compact JSON
+func NormalizeBoundary(raw []byte) ([]byte, error) {
+	var object geoJSONObject
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %w", err)
+	}
+
+	if object.Type == "Feature" {
+		if object.Geometry == nil {
+			return nil, errors.New("GeoJSON feature has no geometry")
+		}
+		object = *object.Geometry
+	}
+
+	switch object.Type {
+	case "Polygon":
+		var polygon [][][]float64
+		if err := json.Unmarshal(object.Coordinates, &polygon); err != nil {
+			return nil, fmt.Errorf("invalid polygon coordinates: %w", err)
+		}
+		if err := validatePolygon(polygon); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]interface{}{"type": "Polygon", "coordinates": polygon})
+	case "MultiPolygon":
+		var multiPolygon [][][][]float64
+		if err := json.Unmarshal(object.Coordinates, &multiPolygon); err != nil {
+			return nil, fmt.Errorf("invalid multipolygon coordinates: %w", err)
+		}
+		if len(multiPolygon) == 0 {
+			return nil, errors.New("multipolygon must contain at least one polygon")
+		}
+		for i, polygon := range multiPolygon {
+			if err := validatePolygon(polygon); err != nil {
+				return nil, fmt.Errorf("polygon %d: %w", i, err)
+			}
+		}
+		return json.Marshal(map[string]interface{}{"type": "MultiPolygon", "coordinates": multiPolygon})
+	default:
+		return nil, fmt.Errorf("unsupported geometry type %q (expected Polygon or MultiPolygon)", object.Type)
+	}
+}
+
+// validatePolygon checks that every ring is closed, has at least four positions and
+// uses valid WGS84 longitude/latitude pairs
+func validatePolygon(rings [][][]float64) error {
+	if len(rings) == 0 {
+		return errors.New("polygon must contain at least one ring")
+	}
+
+	for i, ring := range rings {
+		if len(ring) < 4 {
+			return fmt.Errorf("ring %d must have at least 4 positions", i)
+		}
+		for j, position := range ring {
+			if len(position) < 2 {
+				return fmt.Errorf("ring %d position %d must have longitude and latitude", i, j)
+			}
+			if !IsValidLongitude(position[0]) || !IsValidLatitude(position[1]) {
+				return fmt.Errorf("ring %d position %d has invalid coordinates", i, j)
+			}
+		}
+		first, last := ring[0], ring[len(ring)-1]
+		if first[0] != last[0] || first[1] != last[1] {
+			return fmt.Errorf("ring %d is not closed", i)
+		}
+	}
+
+	return nil
+}
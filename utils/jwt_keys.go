@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"map-memories-api/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSAKeyBits is the smallest RSA key accepted for signing tokens
+const minRSAKeyBits = 2048
+
+// jwtKey is a key tokens are signed or verified with
+type jwtKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   crypto.Signer // nil for verification-only keys
+	PublicKey crypto.PublicKey
+}
+
+// jwtKeySet holds every key tokens are accepted from and the one new tokens are signed with
+type jwtKeySet struct {
+	keys    map[string]*jwtKey
+	signing *jwtKey
+}
+
+// jwtKeys is nil when tokens are signed with the shared HS256 secret
+var jwtKeys *jwtKeySet
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// InitJWTKeys loads the signing and verification keys from JWT_KEY_DIR. Every <kid>.pem
+// file is a key: private keys (PKCS#8, or PKCS#1 for RSA) can sign and verify, public
+// keys (PKIX) of retired signing keys only verify tokens that are still in circulation.
+// Without a key directory tokens are signed with the shared JWT_SECRET (HS256).
+func InitJWTKeys() error {
+	cfg := config.AppConfig.JWT
+	if cfg.KeyDir == "" {
+		jwtKeys = nil
+		return nil
+	}
+
+	keySet, err := loadJWTKeys(cfg.KeyDir, cfg.SigningKeyID)
+	if err != nil {
+		return err
+	}
+
+	jwtKeys = keySet
+	return nil
+}
+
+// JWKS returns the public keys tokens may be verified with; empty when tokens are
+// signed with the shared secret, which must never be published
+func JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if jwtKeys == nil {
+		return set
+	}
+
+	ids := make([]string, 0, len(jwtKeys.keys))
+	for id := range jwtKeys.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		set.Keys = append(set.Keys, jwtKeys.keys[id].jwk())
+	}
+	return set
+}
+
+// signJWT signs claims with the current signing key, or the shared secret
+func signJWT(claims jwt.Claims) (string, error) {
+	if jwtKeys == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(config.AppConfig.JWT.Secret))
+	}
+
+	token := jwt.NewWithClaims(jwtKeys.signing.Method, claims)
+	token.Header["kid"] = jwtKeys.signing.ID
+	return token.SignedString(jwtKeys.signing.Private)
+}
+
+// jwtVerificationKey resolves the key a token was signed with from its kid header
+func jwtVerificationKey(token *jwt.Token) (interface{}, error) {
+	if jwtKeys == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("unexpected signing method")
+		}
+		return []byte(config.AppConfig.JWT.Secret), nil
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	key, ok := jwtKeys.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return key.PublicKey, nil
+}
+
+// loadJWTKeys reads every .pem file in dir and picks the signing key
+func loadJWTKeys(dir, signingKeyID string) (*jwtKeySet, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keySet := &jwtKeySet{keys: make(map[string]*jwtKey)}
+	for _, path := range paths {
+		key, err := loadJWTKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("load JWT key %s: %w", path, err)
+		}
+		if _, exists := keySet.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate JWT key ID %q in %s", key.ID, dir)
+		}
+		keySet.keys[key.ID] = key
+
+		// Keys are sorted by name, so the last private key wins unless one is configured
+		if key.Private != nil && signingKeyID == "" {
+			keySet.signing = key
+		}
+	}
+
+	if signingKeyID != "" {
+		keySet.signing = keySet.keys[signingKeyID]
+		if keySet.signing == nil || keySet.signing.Private == nil {
+			return nil, fmt.Errorf("JWT signing key %q has no private key in %s", signingKeyID, dir)
+		}
+	}
+	if keySet.signing == nil {
+		return nil, fmt.Errorf("no private JWT key found in %s", dir)
+	}
+
+	return keySet, nil
+}
+
+// loadJWTKey parses one PEM key file; the key ID is the file name without .pem
+// (and without .pub for public keys)
+func loadJWTKey(path string) (*jwtKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	id := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".pem"), ".pub")
+	key := &jwtKey{ID: id}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Private, key.PublicKey = k, &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Private, key.PublicKey = k, k.Public()
+	case *rsa.PublicKey, ed25519.PublicKey:
+		key.PublicKey = k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T; use RSA or Ed25519", parsed)
+	}
+
+	switch pub := key.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA key must be at least %d bits", minRSAKeyBits)
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	}
+
+	return key, nil
+}
+
+// jwk converts the public half of a key to its JWK representation
+func (k *jwtKey) jwk() JWK {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+	switch pub := k.PublicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+	}
+	return jwk
+}
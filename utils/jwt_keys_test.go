@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"map-memories-api/config"
+	"map-memories-api/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const testJWTSecret = "test-secret-that-is-long-enough-for-hs256"
+
+// useJWTKeyDir configures tokens to be signed with the keys in dir ("" for the shared
+// secret) for the duration of a test
+func useJWTKeyDir(t *testing.T, dir string) {
+	t.Helper()
+
+	previousConfig, previousKeys := config.AppConfig, jwtKeys
+	t.Cleanup(func() { config.AppConfig, jwtKeys = previousConfig, previousKeys })
+
+	config.AppConfig = &config.Config{JWT: config.JWTConfig{Secret: testJWTSecret, Expiry: time.Hour, KeyDir: dir}}
+	if err := InitJWTKeys(); err != nil {
+		t.Fatalf("InitJWTKeys: %v", err)
+	}
+}
+
+// writeEd25519Key generates an Ed25519 key, writes its private half to dir/<kid>.pem and
+// its public half to publicDir/<kid>.pub.pem when publicDir is set
+func writeEd25519Key(t *testing.T, dir, kid, publicDir string) ed25519.PrivateKey {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatalf("marshal private key: %v", err)
+	}
+	writePEM(t, filepath.Join(dir, kid+".pem"), "PRIVATE KEY", privateDER)
+
+	if publicDir != "" {
+		publicDER, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			t.Fatalf("marshal public key: %v", err)
+		}
+		writePEM(t, filepath.Join(publicDir, kid+".pub.pem"), "PUBLIC KEY", publicDER)
+	}
+	return private
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func testUser() *models.User {
+	return &models.User{ID: 42, UUID: uuid.New(), Email: "ada@example.com", Username: "ada"}
+}
+
+// signTestToken signs claims for user with method and key, setting the kid header when
+// it is not empty
+func signTestToken(t *testing.T, method jwt.SigningMethod, key interface{}, kid string) string {
+	t.Helper()
+
+	user := testUser()
+	token := jwt.NewWithClaims(method, &JWTClaims{
+		UserID:   user.ID,
+		UserUUID: user.UUID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			Subject:   user.UUID.String(),
+		},
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+	return signed
+}
+
+func TestJWTVerifiesWithRetiredPublicKey(t *testing.T) {
+	// Tokens are signed with the "2024" key ...
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	writeEd25519Key(t, oldDir, "2024", newDir)
+	useJWTKeyDir(t, oldDir)
+
+	user := testUser()
+	token, _, err := GenerateJWTWithClaims(user)
+	if err != nil {
+		t.Fatalf("GenerateJWTWithClaims: %v", err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &JWTClaims{})
+	if err != nil {
+		t.Fatalf("parse token: %v", err)
+	}
+	if parsed.Method != jwt.SigningMethodEdDSA || parsed.Header["kid"] != "2024" {
+		t.Fatalf("token is signed with %s by key %v, want EdDSA by key 2024", parsed.Method.Alg(), parsed.Header["kid"])
+	}
+
+	// ... which is then retired: only its public half is kept next to the new key
+	writeEd25519Key(t, newDir, "2025", "")
+	useJWTKeyDir(t, newDir)
+
+	claims, err := VerifyJWT(token)
+	if err != nil {
+		t.Fatalf("VerifyJWT with the retired key: %v", err)
+	}
+	if claims.UserUUID != user.UUID || claims.UserID != user.ID {
+		t.Errorf("claims = %+v, want user %d (%s)", claims, user.ID, user.UUID)
+	}
+
+	next, _, err := GenerateJWTWithClaims(user)
+	if err != nil {
+		t.Fatalf("GenerateJWTWithClaims: %v", err)
+	}
+	if _, err := VerifyJWT(next); err != nil {
+		t.Errorf("VerifyJWT with the new key: %v", err)
+	}
+
+	jwks := JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != "2024" || jwks.Keys[1].Kid != "2025" {
+		t.Errorf("JWKS = %+v, want keys 2024 and 2025", jwks.Keys)
+	}
+	for _, key := range jwks.Keys {
+		if key.Kty != "OKP" || key.Crv != "Ed25519" || key.Alg != "EdDSA" || key.X == "" {
+			t.Errorf("JWK %s = %+v, want an Ed25519 public key", key.Kid, key)
+		}
+	}
+}
+
+func TestJWTRejectsTokensNotSignedByAConfiguredKey(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, dir, "current", "")
+	useJWTKeyDir(t, dir)
+
+	_, unknown, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"HS256 with the shared secret", signTestToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), "")},
+		{"HS256 with the shared secret and a known kid", signTestToken(t, jwt.SigningMethodHS256, []byte(testJWTSecret), "current")},
+		{"unknown kid", signTestToken(t, jwt.SigningMethodEdDSA, unknown, "retired")},
+		{"known kid with another key", signTestToken(t, jwt.SigningMethodEdDSA, unknown, "current")},
+		{"no kid", signTestToken(t, jwt.SigningMethodEdDSA, unknown, "")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if claims, err := VerifyJWT(test.token); err == nil {
+				t.Errorf("VerifyJWT accepted the token: %+v", claims)
+			}
+		})
+	}
+
+	// The same HS256 token is valid where no keys are configured
+	useJWTKeyDir(t, "")
+	if _, err := VerifyJWT(tests[0].token); err != nil {
+		t.Errorf("VerifyJWT with the shared secret: %v", err)
+	}
+}
+
+func TestJWTKeyDirNeedsAPrivateKey(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, t.TempDir(), "retired", dir)
+
+	if _, err := loadJWTKeys(dir, ""); err == nil {
+		t.Error("loadJWTKeys accepted a directory with only public keys")
+	}
+	if _, err := loadJWTKeys(dir, "retired"); err == nil {
+		t.Error("loadJWTKeys accepted a public key as the signing key")
+	}
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"map-memories-api/config"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed or carry a bad signature
+var ErrInvalidToken = errors.New("invalid token")
+
+// GenerateSignedToken creates a random token signed for the given purpose and returns it
+// with the hash to store. Tokens have the form <random>.<signature>.
+func GenerateSignedToken(purpose string) (string, string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+
+	keys := config.AppConfig.Keys
+	payload := base64.RawURLEncoding.EncodeToString(random)
+	token := payload + "." + signToken(keys.TokenSigningKeys[keys.TokenSigningKeyVersion], purpose, payload)
+
+	return token, HashToken(token), nil
+}
+
+// VerifySignedToken checks the signature of a token for the given purpose and returns
+// the hash to look it up by. Tokens signed with a previous TOKEN_SIGNING_KEY are still
+// accepted while that key is configured.
+func VerifySignedToken(purpose, token string) (string, error) {
+	payload, signature, found := strings.Cut(strings.TrimSpace(token), ".")
+	if !found || payload == "" || signature == "" {
+		return "", ErrInvalidToken
+	}
+
+	for _, key := range config.AppConfig.Keys.TokenSigningKeys {
+		if hmac.Equal([]byte(signature), []byte(signToken(key, purpose, payload))) {
+			return HashToken(strings.TrimSpace(token)), nil
+		}
+	}
+	return "", ErrInvalidToken
+}
+
+// HashToken returns the hex SHA-256 of a token, as stored in the database
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// signToken computes the HMAC-SHA256 signature of a token payload for a purpose
+func signToken(key, purpose, payload string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(purpose + ":" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// APITokenPrefix marks personal API tokens so they can be told apart from JWTs
+const APITokenPrefix = "mmpat_"
+
+// apiTokenDisplayLength is how much of an API token is kept to recognize it in lists
+const apiTokenDisplayLength = len(APITokenPrefix) + 6
+
+// GenerateAPIToken creates a personal API token and returns it with its display prefix
+// and the hash to store
+func GenerateAPIToken() (token, prefix, hash string, err error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", "", err
+	}
+
+	token = APITokenPrefix + base64.RawURLEncoding.EncodeToString(random)
+	return token, token[:apiTokenDisplayLength], HashToken(token), nil
+}
+
+// IsAPIToken reports whether a bearer token is a personal API token rather than a JWT
+func IsAPIToken(token string) bool {
+	return strings.HasPrefix(token, APITokenPrefix)
+}
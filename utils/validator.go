@@ -63,6 +63,25 @@ func ValidateAndBindJSON(c *gin.Context, obj interface{}) error {
 	return nil
 }
 
+// ValidateAndBind validates and binds a JSON or form body, depending on its content type
+func ValidateAndBind(c *gin.Context, obj interface{}) error {
+	if err := c.ShouldBind(obj); err != nil {
+		return err
+	}
+
+	validationErrors := ValidateStruct(obj)
+	if len(validationErrors) > 0 {
+		c.JSON(400, models.ErrorResponseWithCode(
+			"Validation failed",
+			"VALIDATION_ERROR",
+			validationErrors,
+		))
+		return fmt.Errorf("validation failed")
+	}
+
+	return nil
+}
+
 // ValidateAndBindQuery validates and binds query parameters
 func ValidateAndBindQuery(c *gin.Context, obj interface{}) error {
 	if err := c.ShouldBindQuery(obj); err != nil {
@@ -0,0 +1,61 @@
+package videoproc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"map-memories-api/config"
+	"map-memories-api/storage"
+)
+
+// storePoster grabs the frame at offset from a video file with ffmpeg and stores it as
+// JPEG under key. ffmpeg applies the rotation of the video and the frame is scaled down
+// to the configured width.
+func storePoster(ctx context.Context, videoPath string, at time.Duration, key string) error {
+	videoConfig := config.AppConfig.Video
+	ctx, cancel := context.WithTimeout(ctx, videoConfig.PosterTimeout)
+	defer cancel()
+
+	poster, err := os.CreateTemp(config.AppConfig.Upload.StagingPath, "poster-*.jpg")
+	if err != nil {
+		return err
+	}
+	poster.Close()
+	defer os.Remove(poster.Name())
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-nostdin", "-hide_banner", "-loglevel", "error",
+		"-ss", strconv.FormatFloat(at.Seconds(), 'f', 3, 64),
+		"-i", videoPath,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale='min(%d,iw)':-2", videoConfig.PosterWidth),
+		"-q:v", "3",
+		"-y", poster.Name(),
+	)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	file, err := os.Open(poster.Name())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return fmt.Errorf("ffmpeg produced no frame")
+	}
+
+	return storage.Default.Put(ctx, key, file, info.Size(), "image/jpeg")
+}
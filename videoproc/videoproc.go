@@ -0,0 +1,217 @@
+package videoproc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"time"
+
+	"map-memories-api/config"
+	"map-memories-api/database"
+	"map-memories-api/jobs"
+	"map-memories-api/mediainfo"
+	"map-memories-api/models"
+	"map-memories-api/storage"
+	"map-memories-api/utils"
+
+	"gorm.io/gorm"
+)
+
+// ProcessJob is the job type that processes one video
+const ProcessJob = "video.process"
+
+// errUnprocessable marks videos retrying cannot help: their file is gone or neither
+// metadata nor a poster frame can be read from it
+var errUnprocessable = errors.New("video cannot be processed")
+
+var ffmpegPath string
+
+type processPayload struct {
+	MediaID uint `json:"media_id"`
+}
+
+// Init registers the processing job and queues videos uploaded before processing
+// existed
+func Init(cfg config.VideoConfig) {
+	if cfg.FFmpegPath != "" {
+		path, err := exec.LookPath(cfg.FFmpegPath)
+		if err != nil {
+			log.Printf("Video processing: %s not found, poster frames are disabled", cfg.FFmpegPath)
+		}
+		ffmpegPath = path
+	}
+
+	jobs.Register(ProcessJob, func(ctx context.Context, payload json.RawMessage) error {
+		var p processPayload
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return jobs.Permanent(err)
+		}
+		return Process(ctx, p.MediaID)
+	})
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var ids []uint
+		if err := tx.Model(&models.Media{}).
+			Where("media_type = ? AND processing_status = ?", "video", "").
+			Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+			return err
+		}
+		for _, id := range ids {
+			if err := Enqueue(tx, id); err != nil {
+				return err
+			}
+		}
+		log.Printf("Video processing: queued %d earlier videos", len(ids))
+		return tx.Model(&models.Media{}).Where("id IN ?", ids).
+			Update("processing_status", models.ProcessingPending).Error
+	})
+	if err != nil {
+		log.Printf("Video processing: failed to queue earlier videos: %v", err)
+	}
+}
+
+// Enqueue queues the processing of a video, in the transaction tx
+func Enqueue(tx *gorm.DB, mediaID uint) error {
+	_, err := jobs.Enqueue(tx, ProcessJob, processPayload{MediaID: mediaID})
+	return err
+}
+
+// Process reads the metadata of a video and stores its poster frame. Errors that may
+// pass (storage, database) are returned for the job to be retried; videos that cannot
+// be processed are marked failed.
+func Process(ctx context.Context, mediaID uint) error {
+	var media models.Media
+	if err := database.DB.First(&media, mediaID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Deleted before it was processed
+			return nil
+		}
+		return err
+	}
+	if media.ProcessingStatus == models.ProcessingReady {
+		return nil
+	}
+	if err := database.DB.Model(&media).Update("processing_status", models.ProcessingProcessing).Error; err != nil {
+		return err
+	}
+
+	result, err := process(ctx, &media)
+	if err != nil && !errors.Is(err, errUnprocessable) {
+		database.DB.Model(&media).Update("processing_status", models.ProcessingPending)
+		return err
+	}
+
+	updates := map[string]interface{}{"processing_status": models.ProcessingReady}
+	if err != nil {
+		log.Printf("Video processing of media %d failed: %v", media.ID, err)
+		updates["processing_status"] = models.ProcessingFailed
+	}
+	if result != nil {
+		updates["duration_ms"] = result.DurationMs
+		updates["video_codec"] = result.VideoCodec
+		updates["poster_key"] = result.PosterKey
+		if result.Width > 0 && result.Height > 0 {
+			updates["width"] = result.Width
+			updates["height"] = result.Height
+		}
+	}
+	return database.DB.Model(&media).Updates(updates).Error
+}
+
+// process extracts what it can; the returned media holds the results even when err
+// reports that the video is unprocessable
+func process(ctx context.Context, media *models.Media) (*models.Media, error) {
+	// Media sharing content with a processed video share its results and poster
+	if media.BlobID != nil {
+		var processed models.Media
+		err := database.DB.Where("blob_id = ? AND id <> ? AND processing_status = ?",
+			*media.BlobID, media.ID, models.ProcessingReady).First(&processed).Error
+		if err == nil {
+			return &processed, nil
+		}
+	}
+
+	file, err := spool(ctx, media)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, fmt.Errorf("%w: file not found in storage", errUnprocessable)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		file.Close()
+		os.Remove(file.Name())
+	}()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.Media{}
+	video, metadataErr := mediainfo.ParseVideo(file, info.Size(), media.MimeType)
+	if metadataErr == nil {
+		result.DurationMs = video.Duration.Milliseconds()
+		result.VideoCodec = video.Codec
+		result.Width, result.Height = video.Width, video.Height
+	} else if !errors.Is(metadataErr, mediainfo.ErrNoVideoMetadata) {
+		log.Printf("Video processing: unreadable metadata of media %d: %v", media.ID, metadataErr)
+	}
+
+	if ffmpegPath == "" {
+		if metadataErr != nil && !errors.Is(metadataErr, mediainfo.ErrNoVideoMetadata) {
+			return result, fmt.Errorf("%w: %v", errUnprocessable, metadataErr)
+		}
+		return result, nil
+	}
+
+	var at time.Duration
+	if video != nil {
+		at = min(time.Second, video.Duration/2)
+	}
+	posterKey := storage.MediaPosterKey(media.UUID.String())
+	if media.BlobID != nil {
+		posterKey = storage.PosterKey(media.Checksum)
+	}
+	if err := storePoster(ctx, file.Name(), at, posterKey); err != nil {
+		if ctx.Err() != nil {
+			return result, err
+		}
+		if metadataErr != nil {
+			return result, fmt.Errorf("%w: no metadata (%v) and no poster frame (%v)", errUnprocessable, metadataErr, err)
+		}
+		log.Printf("Video processing: no poster frame for media %d: %v", media.ID, err)
+		return result, nil
+	}
+	result.PosterKey = posterKey
+	return result, nil
+}
+
+// spool copies a stored video to a temporary file, since both the metadata parser and
+// ffmpeg need random access
+func spool(ctx context.Context, media *models.Media) (*os.File, error) {
+	content, _, err := utils.OpenMediaFile(ctx, media)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	stagingPath := config.AppConfig.Upload.StagingPath
+	if err := os.MkdirAll(stagingPath, 0755); err != nil {
+		return nil, err
+	}
+	file, err := os.CreateTemp(stagingPath, "video-*")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(file, content); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}